@@ -1,6 +1,8 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"log/slog"
 	"os"
 	"strconv"
@@ -14,68 +16,314 @@ import (
 type Config struct {
 	// Config for DiceDBAdmin instance. This instance holds internal keys
 	// and is separate from DiceDB hosting global key pool i.e. user facing.
-	DiceDBAdmin struct {
-		Addr     string // Field for the Dice address
-		Username string // Field for the username
-		Password string // Field for the password
-	}
+	DiceDBAdmin DiceDBConfig
 	// Config for DiceDB User instance. This instance holds internal keys
 	// and is separate from DiceDB hosting global key pool i.e. user facing.
-	DiceDB struct {
-		Addr     string // Field for the Dice address
-		Username string // Field for the username
-		Password string // Field for the password
-	}
-	Server struct {
+	DiceDB      DiceDBConfig
+	Tracing     TracingConfig
+	Logging     LoggingConfig
+	Audit       AuditConfig
+	SlowLog     SlowLogConfig
+	Analytics   AnalyticsConfig
+	Presence    PresenceConfig
+	Response    ResponseConfig
+	Compression CompressionConfig
+	Session     SessionConfig
+	Admin       AdminConfig
+	Debug       DebugConfig
+	Server      struct {
 		Port                 string // Field for the server port
 		Environment          string
 		RequestLimitPerMin   int64         // Field for the request limit
 		RequestWindowSec     float64       // Field for the time window in float64
 		AllowedOrigins       []string      // Field for the allowed origins
+		TrustedProxies       []string      // Field for the proxies whose X-Forwarded-For header names the client
 		CronCleanupFrequency time.Duration // Field for configuring key cleanup cron
+		ShutdownTimeout      time.Duration // Field for the deadline to drain in-flight requests on shutdown
+		HealthCheckTimeout   time.Duration // Field for the timeout of each dependency ping in readiness checks
+		HealthCacheTTL       time.Duration // Field for how long readiness results are cached
+		// Default timeout of a user command, with per-command overrides keyed by upper-case command name
+		CommandTimeout          time.Duration
+		CommandTimeoutOverrides map[string]time.Duration
+		StartupConnectTimeout   time.Duration // Field for how long startup waits for DiceDB before serving in degraded state
+		ConnectionCheckInterval time.Duration // Field for how often the DiceDB connections are checked
+		ReconnectMaxBackoff     time.Duration // Field for the maximum delay between reconnection attempts
+		ReadRetryMax            int           // Field for how often reads and idempotent writes are retried
+		RetryInitialBackoff     time.Duration // Field for the delay before the first command retry
+		RetryMaxBackoff         time.Duration // Field for the maximum delay between command retries
+		IdempotencyTTL          time.Duration // Field for how long results of requests with an Idempotency-Key are kept
 	}
 }
 
-// LoadConfig loads the application configuration from environment variables or defaults
+// DiceDBConfig holds the connection settings for a single DiceDB instance
+type DiceDBConfig struct {
+	Addr     string    // Field for the Dice address
+	Username string    // Field for the username
+	Password string    // Field for the password
+	TLS      TLSConfig // Field for the TLS settings of the connection
+}
+
+// TracingConfig holds the OpenTelemetry tracing settings. Spans are only
+// exported when OTLPEndpoint is set.
+type TracingConfig struct {
+	OTLPEndpoint string  // Field for the OTLP/HTTP traces endpoint, e.g. http://localhost:4318/v1/traces
+	ServiceName  string  // Field for the service name reported with every span
+	SampleRatio  float64 // Field for the fraction of new traces that are sampled, between 0 and 1
+}
+
+// LoggingConfig holds the logging settings. Levels are one of debug, info, warn
+// or error, components without an override log at Level.
+type LoggingConfig struct {
+	Format          string            // Field for the log output format, "json" or "text"
+	Level           string            // Field for the default log level
+	ComponentLevels map[string]string // Field for per-component level overrides keyed by component name
+	RedactArgs      bool              // Field for redacting command argument values from logs
+}
+
+// AuditConfig holds the command audit settings. Events are written to every
+// configured sink: "stdout", "file" and "dicedb" (a capped list in the admin instance).
+type AuditConfig struct {
+	Sinks            []string      // Field for the enabled audit sinks, auditing is disabled when empty
+	SampleRate       float64       // Field for the fraction of successful commands audited, failures are always audited
+	QueueSize        int           // Field for the number of events buffered before new events are dropped
+	BatchSize        int           // Field for the maximum number of events written to the sinks at once
+	FlushInterval    time.Duration // Field for how long events are buffered before being written
+	FilePath         string        // Field for the path of the JSONL audit file
+	FileMaxSizeBytes int64         // Field for the size at which the audit file is rotated
+	FileMaxBackups   int           // Field for the number of rotated audit files kept
+	DiceDBMaxEntries int64         // Field for the number of events kept in the admin DiceDB
+}
+
+// SlowLogConfig holds the slow command log settings. Commands taking longer
+// than Threshold are kept in a capped list in the admin instance.
+type SlowLogConfig struct {
+	Threshold  time.Duration // Field for the duration above which a command is logged, 0 disables the slow log
+	MaxEntries int64         // Field for the number of slow commands kept
+}
+
+// AnalyticsConfig holds the usage analytics settings. Counters are kept per
+// hour in the admin instance and rolled up into daily aggregates.
+type AnalyticsConfig struct {
+	Enabled        bool          // Field for enabling usage analytics
+	FlushInterval  time.Duration // Field for how often buffered counters are written to DiceDB
+	RollupInterval time.Duration // Field for how often completed hours are rolled up into daily aggregates
+	Retention      time.Duration // Field for how long daily aggregates are kept
+}
+
+// PresenceConfig holds the settings of the active client tracker. Clients are
+// active if they sent a request within the last Window.
+type PresenceConfig struct {
+	Window   time.Duration // Field for how long a client stays active after its last request
+	History  time.Duration // Field for how far back the per-minute active counts are reported
+	CacheTTL time.Duration // Field for how long presence counts are cached
+}
+
+// ResponseConfig holds the response size budget of JSON replies. Larger replies
+// are truncated and the rest is kept in the admin instance to be fetched with a
+// cursor. Replies in the other media types are sent whole.
+type ResponseConfig struct {
+	MaxBytes  int           // Field for the maximum size of a reply in a single response, 0 disables truncation
+	CursorTTL time.Duration // Field for how long the remainder of a truncated reply can be fetched
+}
+
+// SessionConfig holds the settings of session-pinned connections. A session
+// can pin a dedicated DiceDB connection so that connection state such as the
+// selected database persists across its requests.
+type SessionConfig struct {
+	MaxPinnedConns          int           // Field for the maximum number of pinned connections, 0 disables pinning
+	MaxPinnedConnsPerClient int           // Field for the maximum number of pinned connections of a single client IP
+	IdleTimeout             time.Duration // Field for how long an unused pinned connection is kept
+}
+
+// CompressionConfig holds the response compression settings. JSON and text
+// responses of at least MinSize bytes are compressed with brotli or gzip.
+type CompressionConfig struct {
+	Enabled       bool     // Field for enabling response compression
+	MinSize       int      // Field for the size below which responses are sent uncompressed
+	GzipLevel     int      // Field for the gzip level, between 1 (fastest) and 9 (smallest)
+	BrotliLevel   int      // Field for the brotli level, between 0 (fastest) and 11 (smallest)
+	ExcludedPaths []string // Field for path prefixes never compressed, e.g. streaming routes
+}
+
+// AdminConfig holds the settings of the /admin endpoints, which are disabled
+// unless a token is set
+type AdminConfig struct {
+	Token string // Field for the bearer token required by the admin endpoints
+}
+
+// DebugConfig holds the settings of the /debug endpoints (pprof, runtime stats
+// and state dumps). They also require the admin token.
+type DebugConfig struct {
+	Enabled bool // Field for mounting the debug endpoints
+}
+
+// TLSConfig holds the TLS settings used when connecting to a DiceDB instance.
+// TLS is only used when Enabled is set; the remaining fields are optional.
+type TLSConfig struct {
+	Enabled            bool   // Field for enabling TLS on the connection
+	CAFile             string // Field for the CA bundle used to verify the server
+	CertFile           string // Field for the client certificate (mutual TLS)
+	KeyFile            string // Field for the client certificate key (mutual TLS)
+	ServerName         string // Field for overriding the server name used for verification
+	InsecureSkipVerify bool   // Field for skipping server verification, only meant for development
+}
+
+// LoadConfig loads the application configuration from environment variables or
+// defaults. Secrets whose file cannot be read are left empty, Load reports them.
 func LoadConfig() *Config {
+	configValue, _ := Load()
+	return configValue
+}
+
+// Load loads the application configuration like LoadConfig. It fails if a secret
+// file named by a <key>_FILE environment variable cannot be read, rather than
+// starting with an empty or default secret.
+func Load() (*Config, error) {
 	err := godotenv.Load()
 	if err != nil {
 		slog.Debug("Warning: .env file not found, falling back to system environment variables.")
 	}
 
-	return &Config{
-		DiceDBAdmin: struct {
-			Addr     string
-			Username string
-			Password string
-		}{
-			Addr:     getEnv("DICEDB_METADATA_ADDR", "localhost:7379"), // Default DiceDB Admin address
-			Username: getEnv("DICEDB_METADATA_USERNAME", "diceadmin"),  // Default DiceDB Admin username
-			Password: getEnv("DICEDB_METADATA_PASSWORD", ""),           // Default DiceDB Admin password
+	secrets := &secretLoader{}
+	configValue := &Config{
+		DiceDBAdmin: loadDiceDBConfig(secrets, "DICEDB_METADATA", "localhost:7379", "diceadmin"), // Default DiceDB Admin address and username
+		DiceDB:      loadDiceDBConfig(secrets, "DICEDB", "localhost:7380", "dice"),               // Default DiceDB address and username
+		Tracing: TracingConfig{
+			OTLPEndpoint: getEnv("TRACING_OTLP_ENDPOINT", ""), // Tracing is disabled by default
+			ServiceName:  getEnv("TRACING_SERVICE_NAME", "playground-mono"),
+			SampleRatio:  getEnvFloat64("TRACING_SAMPLE_RATIO", 1),
 		},
-		DiceDB: struct {
-			Addr     string
-			Username string
-			Password string
-		}{
-			Addr:     getEnv("DICEDB_ADDR", "localhost:7380"), // Default DiceDB address
-			Username: getEnv("DICEDB_USERNAME", "dice"),       // Default username
-			Password: getEnv("DICEDB_PASSWORD", ""),           // Default password
+		Logging: LoggingConfig{
+			Format:          getEnv("LOG_FORMAT", "json"),
+			Level:           getEnv("LOG_LEVEL", "info"),
+			ComponentLevels: getEnvStringMap("LOG_COMPONENT_LEVELS", ""), // e.g. "db=debug,ratelimiter=warn"
+			RedactArgs:      getEnvBool("LOG_REDACT_ARGS", true),
+		},
+		Audit: AuditConfig{
+			Sinks:            getEnvArray("AUDIT_SINKS", []string{"dicedb"}),
+			SampleRate:       getEnvFloat64("AUDIT_SAMPLE_RATE", 1),
+			QueueSize:        int(getEnvInt("AUDIT_QUEUE_SIZE", 4096)),
+			BatchSize:        int(getEnvInt("AUDIT_BATCH_SIZE", 100)),
+			FlushInterval:    time.Duration(getEnvInt("AUDIT_FLUSH_INTERVAL_MS", 1000)) * time.Millisecond,
+			FilePath:         getEnv("AUDIT_FILE_PATH", "audit.jsonl"),
+			FileMaxSizeBytes: getEnvInt("AUDIT_FILE_MAX_SIZE_MB", 100) * 1024 * 1024,
+			FileMaxBackups:   int(getEnvInt("AUDIT_FILE_MAX_BACKUPS", 5)),
+			DiceDBMaxEntries: getEnvInt("AUDIT_DICEDB_MAX_ENTRIES", 10000),
+		},
+		SlowLog: SlowLogConfig{
+			Threshold:  time.Duration(getEnvInt("SLOW_LOG_THRESHOLD_MS", 100)) * time.Millisecond,
+			MaxEntries: getEnvInt("SLOW_LOG_MAX_ENTRIES", 128),
+		},
+		Analytics: AnalyticsConfig{
+			Enabled:        getEnvBool("ANALYTICS_ENABLED", true),
+			FlushInterval:  time.Duration(getEnvInt("ANALYTICS_FLUSH_INTERVAL_SEC", 10)) * time.Second,
+			RollupInterval: time.Duration(getEnvInt("ANALYTICS_ROLLUP_INTERVAL_MIN", 15)) * time.Minute,
+			Retention:      time.Duration(getEnvInt("ANALYTICS_RETENTION_DAYS", 90)) * 24 * time.Hour,
+		},
+		Presence: PresenceConfig{
+			Window:   time.Duration(getEnvInt("PRESENCE_WINDOW_MIN", 5)) * time.Minute,
+			History:  time.Duration(getEnvInt("PRESENCE_HISTORY_MIN", 30)) * time.Minute,
+			CacheTTL: time.Duration(getEnvInt("PRESENCE_CACHE_TTL_MS", 2000)) * time.Millisecond,
+		},
+		Response: ResponseConfig{
+			MaxBytes:  int(getEnvInt("RESPONSE_MAX_BYTES", 65536)),
+			CursorTTL: time.Duration(getEnvInt("RESPONSE_CURSOR_TTL_SEC", 300)) * time.Second,
+		},
+		Compression: CompressionConfig{
+			Enabled:       getEnvBool("COMPRESSION_ENABLED", true),
+			MinSize:       int(getEnvInt("COMPRESSION_MIN_BYTES", 1024)),
+			GzipLevel:     int(getEnvInt("COMPRESSION_GZIP_LEVEL", 6)),
+			BrotliLevel:   int(getEnvInt("COMPRESSION_BROTLI_LEVEL", 5)),
+			ExcludedPaths: getEnvArray("COMPRESSION_EXCLUDED_PATHS", nil),
+		},
+		Session: SessionConfig{
+			MaxPinnedConns:          int(getEnvInt("SESSION_MAX_PINNED_CONNS", 8)),
+			MaxPinnedConnsPerClient: int(getEnvInt("SESSION_MAX_PINNED_CONNS_PER_CLIENT", 2)),
+			IdleTimeout:             time.Duration(getEnvInt("SESSION_IDLE_TIMEOUT_SEC", 300)) * time.Second,
+		},
+		Admin: AdminConfig{
+			Token: secrets.get("ADMIN_TOKEN", ""), // Admin endpoints are disabled by default
+		},
+		Debug: DebugConfig{
+			Enabled: getEnvBool("DEBUG_ENDPOINTS_ENABLED", false),
 		},
 		Server: struct {
-			Port                 string
-			Environment          string
-			RequestLimitPerMin   int64
-			RequestWindowSec     float64
-			AllowedOrigins       []string
-			CronCleanupFrequency time.Duration
+			Port                    string
+			Environment             string
+			RequestLimitPerMin      int64
+			RequestWindowSec        float64
+			AllowedOrigins          []string
+			TrustedProxies          []string
+			CronCleanupFrequency    time.Duration
+			ShutdownTimeout         time.Duration
+			HealthCheckTimeout      time.Duration
+			HealthCacheTTL          time.Duration
+			CommandTimeout          time.Duration
+			CommandTimeoutOverrides map[string]time.Duration
+			StartupConnectTimeout   time.Duration
+			ConnectionCheckInterval time.Duration
+			ReconnectMaxBackoff     time.Duration
+			ReadRetryMax            int
+			RetryInitialBackoff     time.Duration
+			RetryMaxBackoff         time.Duration
+			IdempotencyTTL          time.Duration
 		}{
-			Port:                 getEnv("PORT", ":8080"),
-			Environment:          getEnv("ENVIRONMENT", "local"),
-			RequestLimitPerMin:   getEnvInt("REQUEST_LIMIT_PER_MIN", 1000),                                  // Default request limit
-			RequestWindowSec:     getEnvFloat64("REQUEST_WINDOW_SEC", 60),                                   // Default request window in float64
-			AllowedOrigins:       getEnvArray("ALLOWED_ORIGINS", []string{"http://localhost:3000"}),         // Default allowed origins
-			CronCleanupFrequency: time.Duration(getEnvInt("CRON_CLEANUP_FREQUENCY_MINS", 15)) * time.Minute, // Default cron cleanup frequency
+			Port:                    getEnv("PORT", ":8080"),
+			Environment:             getEnv("ENVIRONMENT", "local"),
+			RequestLimitPerMin:      getEnvInt("REQUEST_LIMIT_PER_MIN", 1000),                                     // Default request limit
+			RequestWindowSec:        getEnvFloat64("REQUEST_WINDOW_SEC", 60),                                      // Default request window in float64
+			AllowedOrigins:          getEnvArray("ALLOWED_ORIGINS", []string{"http://localhost:3000"}),            // Default allowed origins
+			TrustedProxies:          getEnvArray("TRUSTED_PROXIES", nil),                                          // Default trusts no proxy, clients are identified by their address
+			CronCleanupFrequency:    time.Duration(getEnvInt("CRON_CLEANUP_FREQUENCY_MINS", 15)) * time.Minute,    // Default cron cleanup frequency
+			ShutdownTimeout:         time.Duration(getEnvInt("SHUTDOWN_TIMEOUT_SEC", 15)) * time.Second,           // Default shutdown drain deadline
+			HealthCheckTimeout:      time.Duration(getEnvInt("HEALTH_CHECK_TIMEOUT_MS", 1000)) * time.Millisecond, // Default readiness ping timeout
+			HealthCacheTTL:          time.Duration(getEnvInt("HEALTH_CACHE_TTL_MS", 2000)) * time.Millisecond,     // Default readiness cache TTL
+			CommandTimeout:          time.Duration(getEnvInt("COMMAND_TIMEOUT_MS", 3000)) * time.Millisecond,
+			CommandTimeoutOverrides: getEnvDurationMap("COMMAND_TIMEOUT_OVERRIDES_MS", "PFMERGE=10000,GET=1000", time.Millisecond),
+			StartupConnectTimeout:   time.Duration(getEnvInt("STARTUP_CONNECT_TIMEOUT_SEC", 30)) * time.Second,
+			ConnectionCheckInterval: time.Duration(getEnvInt("CONNECTION_CHECK_INTERVAL_SEC", 5)) * time.Second,
+			ReconnectMaxBackoff:     time.Duration(getEnvInt("RECONNECT_MAX_BACKOFF_SEC", 10)) * time.Second,
+			ReadRetryMax:            int(getEnvInt("READ_RETRY_MAX", 3)),
+			RetryInitialBackoff:     time.Duration(getEnvInt("RETRY_INITIAL_BACKOFF_MS", 50)) * time.Millisecond,
+			RetryMaxBackoff:         time.Duration(getEnvInt("RETRY_MAX_BACKOFF_MS", 1000)) * time.Millisecond,
+			IdempotencyTTL:          time.Duration(getEnvInt("IDEMPOTENCY_TTL_SEC", 300)) * time.Second,
+		},
+	}
+	return configValue, errors.Join(secrets.errs...)
+}
+
+// Redacted returns a copy of the configuration safe to expose, with every
+// secret replaced by a placeholder
+func (c *Config) Redacted() Config {
+	redacted := *c
+	for _, diceDB := range []*DiceDBConfig{&redacted.DiceDBAdmin, &redacted.DiceDB} {
+		diceDB.Password = redactSecret(diceDB.Password)
+	}
+	redacted.Admin.Token = redactSecret(redacted.Admin.Token)
+	return redacted
+}
+
+func redactSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "[REDACTED]"
+}
+
+// loadDiceDBConfig loads the connection settings of a DiceDB instance from the
+// environment variables sharing the given prefix e.g. DICEDB_METADATA_ADDR.
+func loadDiceDBConfig(secrets *secretLoader, prefix, defaultAddr, defaultUsername string) DiceDBConfig {
+	return DiceDBConfig{
+		Addr:     getEnv(prefix+"_ADDR", defaultAddr),
+		Username: secrets.get(prefix+"_USERNAME", defaultUsername),
+		Password: secrets.get(prefix+"_PASSWORD", ""),
+		TLS: TLSConfig{
+			Enabled:            getEnvBool(prefix+"_TLS_ENABLED", false),
+			CAFile:             getEnv(prefix+"_TLS_CA_FILE", ""),
+			CertFile:           getEnv(prefix+"_TLS_CERT_FILE", ""),
+			KeyFile:            getEnv(prefix+"_TLS_KEY_FILE", ""),
+			ServerName:         getEnv(prefix+"_TLS_SERVER_NAME", ""),
+			InsecureSkipVerify: getEnvBool(prefix+"_TLS_INSECURE_SKIP_VERIFY", false),
 		},
 	}
 }
@@ -88,6 +336,37 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
+// secretLoader reads secrets, collecting the secret files that cannot be read
+type secretLoader struct {
+	errs []error
+}
+
+// get retrieves a secret from the file named by the <key>_FILE environment
+// variable if set, otherwise it falls back to the <key> environment variable or
+// the default value. This keeps secrets such as passwords out of the process
+// environment. A secret file that cannot be read leaves the secret empty.
+func (l *secretLoader) get(key, fallback string) string {
+	if path, exists := os.LookupEnv(key + "_FILE"); exists && path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			l.errs = append(l.errs, fmt.Errorf("failed to read secret file %s: %w", key+"_FILE", err))
+			return ""
+		}
+		return strings.TrimRight(string(content), "\r\n")
+	}
+	return getEnv(key, fallback)
+}
+
+// getEnvBool retrieves an environment variable as a boolean or returns a default value
+func getEnvBool(key string, fallback bool) bool {
+	if value, exists := os.LookupEnv(key); exists {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return fallback
+}
+
 // getEnvInt retrieves an environment variable as an integer or returns a default value
 func getEnvInt(key string, fallback int) int64 {
 	if value, exists := os.LookupEnv(key); exists {
@@ -117,6 +396,40 @@ func getEnvArray(key string, fallback []string) []string {
 	return fallback
 }
 
+// getEnvDurationMap retrieves a comma separated list of NAME=value pairs, e.g. "GET=1000,PFMERGE=10000",
+// as a map of upper-cased names to durations expressed in the given unit. Malformed pairs are skipped.
+func getEnvDurationMap(key, fallback string, unit time.Duration) map[string]time.Duration {
+	durations := make(map[string]time.Duration)
+	for _, pair := range splitString(getEnv(key, fallback)) {
+		name, value, found := strings.Cut(pair, "=")
+		if !found {
+			continue
+		}
+
+		intValue, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			slog.Warn("Skipping malformed duration", slog.String("key", key), slog.String("pair", pair))
+			continue
+		}
+		durations[strings.ToUpper(strings.TrimSpace(name))] = time.Duration(intValue) * unit
+	}
+	return durations
+}
+
+// getEnvStringMap retrieves a comma separated list of name=value pairs, e.g. "db=debug,http=warn",
+// as a map of lower-cased names to values. Malformed pairs are skipped.
+func getEnvStringMap(key, fallback string) map[string]string {
+	values := make(map[string]string)
+	for _, pair := range splitString(getEnv(key, fallback)) {
+		name, value, found := strings.Cut(pair, "=")
+		if !found {
+			continue
+		}
+		values[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(value)
+	}
+	return values
+}
+
 // splitString splits a string by comma and returns a slice of strings
 func splitString(s string) []string {
 	var array []string
@@ -0,0 +1,226 @@
+// Package analytics keeps usage rollups in the admin DiceDB: per-command
+// success and error counters and the number of unique visitors and sessions
+// per day. Counters are buffered in memory and written periodically so that
+// recording never blocks a request. They are kept per hour first and rolled
+// up into daily aggregates once the hour is over.
+package analytics
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"server/internal/db"
+	"server/internal/logging"
+	"server/internal/metrics"
+	"server/internal/server/utils"
+	"sync"
+	"time"
+
+	"github.com/dicedb/dicedb-go"
+)
+
+// SessionIDHeader identifies a playground session, requests without it are
+// not counted towards unique sessions
+const SessionIDHeader = "X-Session-ID"
+
+const (
+	hourLayout = "2006010215"
+	dayLayout  = "20060102"
+
+	// hourlyTTL is how long hourly counters are kept if they are never rolled up
+	hourlyTTL = 48 * time.Hour
+	// rollupGrace is how long after the end of an hour its counters may still be
+	// flushed, the hour is only rolled up after it
+	rollupGrace = 5 * time.Minute
+
+	outcomeSuccess = "success"
+	outcomeError   = "error"
+)
+
+// Visit is a single command execution counted by the analytics
+type Visit struct {
+	Time      time.Time
+	Command   string
+	Failed    bool
+	ClientIP  string // Hashed before being stored, never written as is
+	SessionID string
+}
+
+// Recorder buffers visits and writes them to the admin DiceDB. A nil Recorder
+// is valid and discards every visit.
+type Recorder struct {
+	client         *db.DiceDB
+	flushInterval  time.Duration
+	rollupInterval time.Duration
+	retention      time.Duration
+
+	mu      sync.Mutex
+	pending *batch
+}
+
+// batch holds the counters recorded since the last flush
+type batch struct {
+	counters map[string]map[string]int64    // hour -> command:outcome -> count
+	visitors map[string]map[string]struct{} // day -> hashed client IPs
+	sessions map[string]map[string]struct{} // day -> session IDs
+}
+
+func newBatch() *batch {
+	return &batch{
+		counters: make(map[string]map[string]int64),
+		visitors: make(map[string]map[string]struct{}),
+		sessions: make(map[string]map[string]struct{}),
+	}
+}
+
+// New creates a recorder writing to the admin DiceDB. Run must be called for
+// the buffered counters to be written and rolled up.
+func New(client *db.DiceDB, flushInterval, rollupInterval, retention time.Duration) *Recorder {
+	return &Recorder{
+		client:         client,
+		flushInterval:  flushInterval,
+		rollupInterval: rollupInterval,
+		retention:      retention,
+		pending:        newBatch(),
+	}
+}
+
+// Record buffers a visit, it is written with the next flush
+func (r *Recorder) Record(visit Visit) {
+	if r == nil {
+		return
+	}
+
+	hour := visit.Time.UTC().Format(hourLayout)
+	day := visit.Time.UTC().Format(dayLayout)
+	field := counterField(metrics.CommandLabel(visit.Command), visit.Failed)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.pending.counters[hour] == nil {
+		r.pending.counters[hour] = make(map[string]int64)
+	}
+	r.pending.counters[hour][field]++
+	if visit.ClientIP != "" {
+		addToSet(r.pending.visitors, day, visitorID(visit.ClientIP))
+	}
+	if visit.SessionID != "" {
+		addToSet(r.pending.sessions, day, visit.SessionID)
+	}
+}
+
+// Run periodically flushes the buffered counters and rolls up completed hours
+// until ctx is done, flushing one last time before returning
+func (r *Recorder) Run(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	logger := logging.FromContext(ctx, logging.ComponentAnalytics)
+
+	flushTicker := time.NewTicker(r.flushInterval)
+	defer flushTicker.Stop()
+	rollupTicker := time.NewTicker(r.rollupInterval)
+	defer rollupTicker.Stop()
+
+	for {
+		select {
+		case <-flushTicker.C:
+			if err := r.Flush(ctx); err != nil {
+				logger.Error("Failed to flush analytics", slog.Any("err", err))
+			}
+		case <-rollupTicker.C:
+			if err := r.Rollup(ctx, time.Now()); err != nil {
+				logger.Error("Failed to roll up analytics", slog.Any("err", err))
+			}
+		case <-ctx.Done():
+			// The last flush must not be cancelled along with the recorder
+			flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), r.flushInterval)
+			if err := r.Flush(flushCtx); err != nil {
+				logger.Error("Failed to flush analytics", slog.Any("err", err))
+			}
+			cancel()
+			logger.Info("Shutting down analytics recorder")
+			return
+		}
+	}
+}
+
+// Flush writes the buffered counters. Counters that fail to be written are
+// dropped, analytics are best effort.
+func (r *Recorder) Flush(ctx context.Context) error {
+	r.mu.Lock()
+	pending := r.pending
+	r.pending = newBatch()
+	r.mu.Unlock()
+
+	if len(pending.counters) == 0 && len(pending.visitors) == 0 && len(pending.sessions) == 0 {
+		return nil
+	}
+
+	_, err := r.client.Client.Pipelined(ctx, func(pipe dicedb.Pipeliner) error {
+		for hour, counters := range pending.counters {
+			key := hourKey(hour)
+			for field, count := range counters {
+				pipe.HIncrBy(ctx, key, field, count)
+			}
+			pipe.Expire(ctx, key, hourlyTTL)
+		}
+		for day, ids := range pending.visitors {
+			addUnique(ctx, pipe, visitorsKey(day), ids, r.retention)
+		}
+		for day, ids := range pending.sessions {
+			addUnique(ctx, pipe, sessionsKey(day), ids, r.retention)
+		}
+		return nil
+	})
+	return err
+}
+
+func addUnique(ctx context.Context, pipe dicedb.Pipeliner, key string, ids map[string]struct{}, ttl time.Duration) {
+	values := make([]interface{}, 0, len(ids))
+	for id := range ids {
+		values = append(values, id)
+	}
+	pipe.PFAdd(ctx, key, values...)
+	pipe.Expire(ctx, key, ttl)
+}
+
+func addToSet(sets map[string]map[string]struct{}, key, value string) {
+	if sets[key] == nil {
+		sets[key] = make(map[string]struct{})
+	}
+	sets[key][value] = struct{}{}
+}
+
+// visitorID pseudonymizes a client IP
+func visitorID(ip string) string {
+	sum := sha256.Sum256([]byte("playground_mono:visitor:" + ip))
+	return hex.EncodeToString(sum[:16])
+}
+
+func counterField(command string, failed bool) string {
+	if failed {
+		return command + ":" + outcomeError
+	}
+	return command + ":" + outcomeSuccess
+}
+
+func hourKey(hour string) string {
+	return utils.AnalyticsKeyPrefix + "commands:hour:" + hour
+}
+
+func dayKey(day string) string {
+	return utils.AnalyticsKeyPrefix + "commands:day:" + day
+}
+
+func rollupKey(hour string) string {
+	return utils.AnalyticsKeyPrefix + "rollup:" + hour
+}
+
+func visitorsKey(day string) string {
+	return utils.AnalyticsKeyPrefix + "visitors:" + day
+}
+
+func sessionsKey(day string) string {
+	return utils.AnalyticsKeyPrefix + "sessions:" + day
+}
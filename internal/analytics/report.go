@@ -0,0 +1,168 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dicedb/dicedb-go"
+)
+
+// DateLayout is the format of the dates of a report
+const DateLayout = "2006-01-02"
+
+// MaxReportDays bounds the number of days covered by a single report
+const MaxReportDays = 366
+
+// ErrInvalidRange is returned for reports whose date range is reversed or too long
+var ErrInvalidRange = errors.New("invalid date range")
+
+// Counts are the number of successful and failed executions of a command
+type Counts struct {
+	Success int64 `json:"success"`
+	Error   int64 `json:"error"`
+}
+
+// DayReport holds the usage of a single day
+type DayReport struct {
+	Date           string            `json:"date"`
+	Commands       map[string]Counts `json:"commands"`
+	UniqueVisitors int64             `json:"unique_visitors"`
+	UniqueSessions int64             `json:"unique_sessions"`
+}
+
+// Report holds the usage of every day of a date range and its totals. Unique
+// visitors and sessions are counted once over the whole range.
+type Report struct {
+	From   string      `json:"from"`
+	To     string      `json:"to"`
+	Days   []DayReport `json:"days"`
+	Totals DayReport   `json:"totals"`
+}
+
+// Report returns the usage between from and to, both inclusive, in UTC days.
+// Counters of the hours that are not rolled up yet are included.
+func (r *Recorder) Report(ctx context.Context, from, to time.Time) (*Report, error) {
+	if r == nil {
+		return nil, errors.New("analytics are disabled")
+	}
+
+	from, to = truncateDay(from), truncateDay(to)
+	if to.Before(from) {
+		return nil, fmt.Errorf("%w: from must not be after to", ErrInvalidRange)
+	}
+	if days := int(to.Sub(from).Hours()/24) + 1; days > MaxReportDays {
+		return nil, fmt.Errorf("%w: the range must not exceed %d days", ErrInvalidRange, MaxReportDays)
+	}
+
+	report := &Report{
+		From:   from.Format(DateLayout),
+		To:     to.Format(DateLayout),
+		Days:   []DayReport{},
+		Totals: DayReport{Commands: map[string]Counts{}},
+	}
+
+	var visitorKeys, sessionKeys []string
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		dayReport, err := r.dayReport(ctx, day)
+		if err != nil {
+			return nil, err
+		}
+		report.Days = append(report.Days, *dayReport)
+		mergeCounts(report.Totals.Commands, dayReport.Commands)
+		visitorKeys = append(visitorKeys, visitorsKey(day.Format(dayLayout)))
+		sessionKeys = append(sessionKeys, sessionsKey(day.Format(dayLayout)))
+	}
+
+	var err error
+	if report.Totals.UniqueVisitors, err = r.client.Client.PFCount(ctx, visitorKeys...).Result(); err != nil {
+		return nil, fmt.Errorf("failed to count unique visitors: %w", err)
+	}
+	if report.Totals.UniqueSessions, err = r.client.Client.PFCount(ctx, sessionKeys...).Result(); err != nil {
+		return nil, fmt.Errorf("failed to count unique sessions: %w", err)
+	}
+	return report, nil
+}
+
+// dayReport reads the daily aggregate of a day along with the counters of its
+// hours that are not rolled up yet
+func (r *Recorder) dayReport(ctx context.Context, day time.Time) (*DayReport, error) {
+	dayName := day.Format(dayLayout)
+
+	// Only the hours still within the hourly retention may not be rolled up
+	var hours []string
+	oldest := time.Now().UTC().Add(-hourlyTTL).Truncate(time.Hour)
+	for hour := day; hour.Before(day.AddDate(0, 0, 1)); hour = hour.Add(time.Hour) {
+		if !hour.Before(oldest) && !hour.After(time.Now()) {
+			hours = append(hours, hour.Format(hourLayout))
+		}
+	}
+
+	var daily *dicedb.MapStringStringCmd
+	var visitors, sessions *dicedb.IntCmd
+	hourly := make([]*dicedb.MapStringStringCmd, len(hours))
+	rolledUp := make([]*dicedb.IntCmd, len(hours))
+	_, err := r.client.Client.Pipelined(ctx, func(pipe dicedb.Pipeliner) error {
+		daily = pipe.HGetAll(ctx, dayKey(dayName))
+		visitors = pipe.PFCount(ctx, visitorsKey(dayName))
+		sessions = pipe.PFCount(ctx, sessionsKey(dayName))
+		for i, hour := range hours {
+			rolledUp[i] = pipe.Exists(ctx, rollupKey(hour))
+			hourly[i] = pipe.HGetAll(ctx, hourKey(hour))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read analytics for %s: %w", day.Format(DateLayout), err)
+	}
+
+	report := &DayReport{
+		Date:           day.Format(DateLayout),
+		Commands:       map[string]Counts{},
+		UniqueVisitors: visitors.Val(),
+		UniqueSessions: sessions.Val(),
+	}
+	addCounters(report.Commands, daily.Val())
+	for i := range hours {
+		if rolledUp[i].Val() == 0 {
+			addCounters(report.Commands, hourly[i].Val())
+		}
+	}
+	return report, nil
+}
+
+// addCounters adds the command:outcome counters of a hash to counts
+func addCounters(counts map[string]Counts, counters map[string]string) {
+	for field, value := range counters {
+		command, outcome, ok := strings.Cut(field, ":")
+		count, err := strconv.ParseInt(value, 10, 64)
+		if !ok || err != nil {
+			continue
+		}
+
+		c := counts[command]
+		if outcome == outcomeError {
+			c.Error += count
+		} else {
+			c.Success += count
+		}
+		counts[command] = c
+	}
+}
+
+func mergeCounts(dst, src map[string]Counts) {
+	for command, counts := range src {
+		c := dst[command]
+		c.Success += counts.Success
+		c.Error += counts.Error
+		dst[command] = c
+	}
+}
+
+func truncateDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
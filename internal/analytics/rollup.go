@@ -0,0 +1,84 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"server/internal/logging"
+	"strconv"
+	"time"
+
+	"github.com/dicedb/dicedb-go"
+)
+
+// Rollup merges the counters of every completed hour into the aggregate of its
+// day and deletes them. Each hour is claimed with a marker key first, so that
+// several servers rolling up concurrently never count an hour twice.
+func (r *Recorder) Rollup(ctx context.Context, now time.Time) error {
+	logger := logging.FromContext(ctx, logging.ComponentAnalytics)
+	now = now.UTC()
+
+	for hour := now.Add(-hourlyTTL).Truncate(time.Hour); !hour.Add(time.Hour + rollupGrace).After(now); hour = hour.Add(time.Hour) {
+		rolled, err := r.rollupHour(ctx, hour)
+		if err != nil {
+			return err
+		}
+		if rolled {
+			logger.Debug("Rolled up analytics", slog.String("hour", hour.Format(hourLayout)))
+		}
+	}
+	return nil
+}
+
+// rollupHour rolls up a single hour, returning whether it had any counters
+func (r *Recorder) rollupHour(ctx context.Context, hour time.Time) (bool, error) {
+	hourName := hour.Format(hourLayout)
+	marker := rollupKey(hourName)
+
+	// The marker outlives the hourly counters, so that an hour is never rolled up again
+	claimed, err := r.client.Client.SetNX(ctx, marker, 1, hourlyTTL+24*time.Hour).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim analytics hour %s: %w", hourName, err)
+	}
+	if !claimed {
+		return false, nil
+	}
+
+	counters, err := r.client.Client.HGetAll(ctx, hourKey(hourName)).Result()
+	if err != nil {
+		r.release(ctx, marker)
+		return false, fmt.Errorf("failed to read analytics hour %s: %w", hourName, err)
+	}
+	if len(counters) == 0 {
+		return false, nil
+	}
+
+	// Add the hour to the day and drop it atomically, a partly applied rollup
+	// would count the hour twice once it is retried
+	day := dayKey(hour.Format(dayLayout))
+	_, err = r.client.Client.TxPipelined(ctx, func(pipe dicedb.Pipeliner) error {
+		for field, value := range counters {
+			count, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				continue
+			}
+			pipe.HIncrBy(ctx, day, field, count)
+		}
+		pipe.Expire(ctx, day, r.retention)
+		pipe.Del(ctx, hourKey(hourName))
+		return nil
+	})
+	if err != nil {
+		r.release(ctx, marker)
+		return false, fmt.Errorf("failed to roll up analytics hour %s: %w", hourName, err)
+	}
+	return true, nil
+}
+
+// release drops the claim on an hour so that the next rollup retries it
+func (r *Recorder) release(ctx context.Context, marker string) {
+	if err := r.client.Client.Del(ctx, marker).Err(); err != nil {
+		logging.FromContext(ctx, logging.ComponentAnalytics).Error("Failed to release analytics rollup",
+			slog.String("key", marker), slog.Any("err", err))
+	}
+}
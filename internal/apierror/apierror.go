@@ -0,0 +1,161 @@
+// Package apierror defines the error object returned by every endpoint. Each
+// error carries a stable machine-readable code that determines the HTTP status,
+// the original message and a hint for humans.
+package apierror
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Code identifies the kind of error, clients should switch on it rather than
+// on the message
+type Code string
+
+const (
+	CodeWrongType          Code = "WRONGTYPE"
+	CodeSyntax             Code = "SYNTAX"
+	CodeUnknownCommand     Code = "UNKNOWN_COMMAND"
+	CodeBlockedCommand     Code = "BLOCKED_COMMAND"
+	CodeCommandError       Code = "COMMAND_ERROR"
+	CodeRateLimited        Code = "RATE_LIMITED"
+	CodeBackendUnavailable Code = "BACKEND_UNAVAILABLE"
+	CodeTimeout            Code = "TIMEOUT"
+	CodeInvalidRequest     Code = "INVALID_REQUEST"
+	CodeUnauthorized       Code = "UNAUTHORIZED"
+	CodeNotFound           Code = "NOT_FOUND"
+	CodeConflict           Code = "CONFLICT"
+	CodeKeyReused          Code = "IDEMPOTENCY_KEY_REUSED"
+	CodeCursorExpired      Code = "CURSOR_EXPIRED"
+	CodeSessionLimit       Code = "SESSION_LIMIT"
+	CodeSessionExpired     Code = "SESSION_EXPIRED"
+	CodeInternal           Code = "INTERNAL"
+)
+
+type codeInfo struct {
+	status int
+	hint   string
+}
+
+var codes = map[Code]codeInfo{
+	CodeWrongType:          {http.StatusConflict, "The key holds a value of another type, check it with TYPE <key>."},
+	CodeSyntax:             {http.StatusBadRequest, "Check the arguments against the command documentation."},
+	CodeUnknownCommand:     {http.StatusBadRequest, "Check the spelling of the command."},
+	CodeBlockedCommand:     {http.StatusForbidden, "This command is disabled on this server."},
+	CodeCommandError:       {http.StatusBadRequest, "DiceDB rejected the command."},
+	CodeRateLimited:        {http.StatusTooManyRequests, "Too many requests, retry once the rate limit window resets."},
+	CodeBackendUnavailable: {http.StatusServiceUnavailable, "DiceDB is unreachable at the moment, retry shortly."},
+	CodeTimeout:            {http.StatusGatewayTimeout, "The command took too long, narrow it down or retry later."},
+	CodeInvalidRequest:     {http.StatusBadRequest, "The request is malformed."},
+	CodeUnauthorized:       {http.StatusUnauthorized, "Provide a valid bearer token."},
+	CodeNotFound:           {http.StatusNotFound, ""},
+	CodeConflict:           {http.StatusConflict, ""},
+	CodeKeyReused:          {http.StatusUnprocessableEntity, "Use a new Idempotency-Key for every distinct request."},
+	CodeCursorExpired:      {http.StatusGone, "The rest of the reply is no longer kept, run the command again."},
+	CodeSessionLimit:       {http.StatusServiceUnavailable, "Every dedicated connection is in use, retry later or continue without one."},
+	CodeSessionExpired:     {http.StatusGone, "The dedicated connection and its state are gone, pin a new session with POST /shell/session."},
+	CodeInternal:           {http.StatusInternalServerError, "This is a bug on our side, retry later."},
+}
+
+// Error is the error object sent to clients. Errors of commands may be
+// enriched with suggestions, the command syntax and a link to its reference.
+type Error struct {
+	Code        Code                   `json:"code"`
+	Message     string                 `json:"message"`
+	Hint        string                 `json:"hint,omitempty"`
+	Suggestions []string               `json:"suggestions,omitempty"`
+	Syntax      string                 `json:"syntax,omitempty"`
+	DocsURL     string                 `json:"docs_url,omitempty"`
+	Details     map[string]interface{} `json:"details,omitempty"`
+}
+
+// Response is the body of every error response
+type Response struct {
+	Error *Error `json:"error"`
+}
+
+// New returns an error with the default hint of its code
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message, Hint: codes[code].hint}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Status returns the HTTP status the error is sent with
+func (e *Error) Status() int {
+	if info, ok := codes[e.Code]; ok {
+		return info.status
+	}
+	return http.StatusInternalServerError
+}
+
+// WithHint replaces the default hint
+func (e *Error) WithHint(hint string) *Error {
+	e.Hint = hint
+	return e
+}
+
+// WithDetail adds a machine-readable detail, e.g. the timeout of a command
+func (e *Error) WithDetail(key string, value interface{}) *Error {
+	if e.Details == nil {
+		e.Details = make(map[string]interface{})
+	}
+	e.Details[key] = value
+	return e
+}
+
+// Internal returns the error sent for unexpected failures, their cause is
+// logged rather than sent to the client
+func Internal() *Error {
+	return New(CodeInternal, "internal server error")
+}
+
+// FromReply classifies an error replied by DiceDB, e.g. "WRONGTYPE Operation
+// against a key holding the wrong kind of value". The "(error) " prefix added
+// by pretty rendering is stripped, the rest of the message is kept as is.
+func FromReply(message string) *Error {
+	message = strings.TrimPrefix(message, "(error) ")
+	lower := strings.ToLower(message)
+
+	switch {
+	case strings.HasPrefix(message, "WRONGTYPE"):
+		return New(CodeWrongType, message)
+	case strings.Contains(lower, "unknown command"):
+		return New(CodeUnknownCommand, message)
+	case strings.Contains(lower, "syntax error"),
+		strings.Contains(lower, "wrong number of arguments"),
+		strings.Contains(lower, "unsupported option"),
+		strings.Contains(lower, "unknown option"):
+		return New(CodeSyntax, message)
+	default:
+		return New(CodeCommandError, message)
+	}
+}
+
+// Write sends err as JSON with the status of its code
+func Write(w http.ResponseWriter, err *Error) {
+	body, marshalErr := json.Marshal(Response{Error: err})
+	if marshalErr != nil {
+		slog.Error("Error marshaling error response", slog.Any("err", marshalErr))
+		body = []byte(`{"error":{"code":"INTERNAL","message":"internal server error"}}`)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(err.Status())
+	if _, writeErr := w.Write(append(body, '\n')); writeErr != nil {
+		slog.Error("Failed to write error response", slog.Any("err", writeErr))
+	}
+}
+
+// Abort sends err and stops the middleware chain
+func Abort(c *gin.Context, err *Error) {
+	Write(c.Writer, err)
+	c.Abort()
+}
@@ -0,0 +1,152 @@
+// Package audit records which commands were run, by whom and with what result.
+// Events are recorded asynchronously: Record never blocks, events are dropped
+// when the queue is full and written to the sinks in batches by a single worker.
+package audit
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"server/internal/logging"
+	"server/internal/metrics"
+	"sync"
+	"time"
+)
+
+// Options tune how events are buffered and sampled
+type Options struct {
+	SampleRate    float64       // Fraction of successful commands recorded, failures are always recorded
+	QueueSize     int           // Events buffered before new events are dropped
+	BatchSize     int           // Maximum number of events written to the sinks at once
+	FlushInterval time.Duration // Maximum time an event is buffered before being written
+}
+
+// Auditor queues events and writes them to its sinks. A nil Auditor is valid
+// and discards every event.
+type Auditor struct {
+	sinks   []Sink
+	options Options
+	events  chan Event
+
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+}
+
+// New creates an auditor writing to the given sinks and starts its worker
+func New(sinks []Sink, options Options) *Auditor {
+	options.QueueSize = max(options.QueueSize, 1)
+	options.BatchSize = max(options.BatchSize, 1)
+	if options.FlushInterval <= 0 {
+		options.FlushInterval = time.Second
+	}
+
+	a := &Auditor{
+		sinks:   sinks,
+		options: options,
+		events:  make(chan Event, options.QueueSize),
+		done:    make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+// Record queues an event without blocking. Successful commands are sampled,
+// and the event is dropped if the queue is full.
+func (a *Auditor) Record(event Event) {
+	if a == nil {
+		return
+	}
+
+	if event.Outcome == OutcomeSuccess && a.options.SampleRate < 1 && rand.Float64() >= a.options.SampleRate {
+		metrics.ObserveAuditEvent(metrics.AuditSampledOut)
+		return
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		return
+	}
+
+	select {
+	case a.events <- event:
+		metrics.ObserveAuditEvent(metrics.AuditQueued)
+	default:
+		metrics.ObserveAuditEvent(metrics.AuditDropped)
+	}
+}
+
+// Close stops accepting events, writes the queued ones and closes the sinks.
+// It gives up waiting for the queue to drain once ctx is done.
+func (a *Auditor) Close(ctx context.Context) error {
+	if a == nil {
+		return nil
+	}
+
+	a.mu.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.events)
+	}
+	a.mu.Unlock()
+
+	select {
+	case <-a.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	var errs []error
+	for _, sink := range a.sinks {
+		if err := sink.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (a *Auditor) run() {
+	defer close(a.done)
+
+	ticker := time.NewTicker(a.options.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Event, 0, a.options.BatchSize)
+	for {
+		select {
+		case event, ok := <-a.events:
+			if !ok {
+				a.flush(batch)
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) >= a.options.BatchSize {
+				a.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			a.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// flush writes a batch to every sink, a failing sink does not prevent the
+// others from receiving the batch
+func (a *Auditor) flush(batch []Event) {
+	if len(batch) == 0 {
+		return
+	}
+
+	for _, sink := range a.sinks {
+		ctx, cancel := context.WithTimeout(context.Background(), sinkWriteTimeout)
+		if err := sink.Write(ctx, batch); err != nil {
+			metrics.ObserveAuditSinkError(sink.Name())
+			logging.Component(logging.ComponentAudit).Error("Failed to write audit events",
+				slog.String("sink", sink.Name()), slog.Int("events", len(batch)), slog.Any("err", err))
+		}
+		cancel()
+	}
+}
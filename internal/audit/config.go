@@ -0,0 +1,46 @@
+package audit
+
+import (
+	"fmt"
+	"os"
+	"server/config"
+	"server/internal/db"
+	"server/internal/server/utils"
+	"strings"
+)
+
+// NewFromConfig creates an auditor writing to the sinks enabled in the audit
+// configuration. It returns a nil Auditor, which discards events, when no sink
+// is enabled.
+func NewFromConfig(auditConfig *config.AuditConfig, adminClient *db.DiceDB) (*Auditor, error) {
+	var sinks []Sink
+	for _, name := range auditConfig.Sinks {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "":
+			continue
+		case "stdout":
+			sinks = append(sinks, NewWriterSink("stdout", os.Stdout))
+		case "file":
+			sink, err := NewFileSink(auditConfig.FilePath, auditConfig.FileMaxSizeBytes, auditConfig.FileMaxBackups)
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, sink)
+		case "dicedb":
+			sinks = append(sinks, NewDiceDBSink(adminClient, utils.AuditLogKey, auditConfig.DiceDBMaxEntries))
+		default:
+			return nil, fmt.Errorf("unknown audit sink %q, expected stdout, file or dicedb", name)
+		}
+	}
+
+	if len(sinks) == 0 {
+		return nil, nil
+	}
+
+	return New(sinks, Options{
+		SampleRate:    auditConfig.SampleRate,
+		QueueSize:     auditConfig.QueueSize,
+		BatchSize:     auditConfig.BatchSize,
+		FlushInterval: auditConfig.FlushInterval,
+	}), nil
+}
@@ -0,0 +1,51 @@
+package audit
+
+import (
+	"context"
+	"time"
+)
+
+// Outcomes of an audited command
+const (
+	OutcomeSuccess   = "success"
+	OutcomeError     = "error"
+	OutcomeTimeout   = "timeout"
+	OutcomeCancelled = "cancelled"
+	OutcomeRejected  = "rejected"
+)
+
+// Event is a single audited command. Argument values are never recorded, only
+// their number and the key names.
+type Event struct {
+	Timestamp time.Time `json:"timestamp"`
+	RequestID string    `json:"request_id,omitempty"`
+	ClientIP  string    `json:"client_ip,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	Command   string    `json:"command"`
+	ArgCount  int       `json:"arg_count"`
+	Keys      []string  `json:"keys,omitempty"`
+	Outcome   string    `json:"outcome"`
+	Error     string    `json:"error,omitempty"`
+	LatencyMs float64   `json:"latency_ms"`
+}
+
+// Client identifies who sent a request. It is attached to the request context
+// by the request logger middleware.
+type Client struct {
+	RequestID string
+	IP        string
+	UserAgent string
+}
+
+type clientContextKey struct{}
+
+// NewContext returns a copy of ctx carrying the client identity
+func NewContext(ctx context.Context, client Client) context.Context {
+	return context.WithValue(ctx, clientContextKey{}, client)
+}
+
+// ClientFromContext returns the client identity carried by ctx, if any
+func ClientFromContext(ctx context.Context) Client {
+	client, _ := ctx.Value(clientContextKey{}).(Client)
+	return client
+}
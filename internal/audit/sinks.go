@@ -0,0 +1,183 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"server/internal/db"
+	"sync"
+	"time"
+)
+
+// sinkWriteTimeout bounds the write of a single batch to a sink
+const sinkWriteTimeout = 5 * time.Second
+
+// Sink persists audit events. Write is only ever called by the auditor worker,
+// one batch at a time.
+type Sink interface {
+	Name() string
+	Write(ctx context.Context, events []Event) error
+	Close() error
+}
+
+// encodeJSONL encodes events as newline delimited JSON
+func encodeJSONL(events []Event) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for i := range events {
+		if err := encoder.Encode(&events[i]); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// WriterSink writes events as JSON lines to a writer, e.g. os.Stdout
+type WriterSink struct {
+	name string
+	w    io.Writer
+}
+
+// NewWriterSink creates a sink writing JSON lines to w
+func NewWriterSink(name string, w io.Writer) *WriterSink {
+	return &WriterSink{name: name, w: w}
+}
+
+func (s *WriterSink) Name() string { return s.name }
+
+func (s *WriterSink) Write(_ context.Context, events []Event) error {
+	data, err := encodeJSONL(events)
+	if err != nil {
+		return err
+	}
+	_, err = s.w.Write(data)
+	return err
+}
+
+func (s *WriterSink) Close() error { return nil }
+
+// FileSink appends events as JSON lines to a file, rotating it once it exceeds
+// maxSize. Rotated files are renamed to <path>.1, <path>.2, ... and only
+// maxBackups of them are kept.
+type FileSink struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// NewFileSink opens (or creates) the audit file at path
+func NewFileSink(path string, maxSize int64, maxBackups int) (*FileSink, error) {
+	s := &FileSink{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSink) Name() string { return "file" }
+
+func (s *FileSink) Write(_ context.Context, events []Event) error {
+	data, err := encodeJSONL(events)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.maxSize > 0 && s.size > 0 && s.size+int64(len(data)) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.file.Write(data)
+	s.size += int64(n)
+	return err
+}
+
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+func (s *FileSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat audit file: %w", err)
+	}
+
+	s.file, s.size = file, info.Size()
+	return nil
+}
+
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return fmt.Errorf("failed to close audit file: %w", err)
+	}
+
+	// Shift the backups, dropping the oldest one
+	if s.maxBackups > 0 {
+		_ = os.Remove(fmt.Sprintf("%s.%d", s.path, s.maxBackups))
+		for i := s.maxBackups - 1; i >= 1; i-- {
+			_ = os.Rename(fmt.Sprintf("%s.%d", s.path, i), fmt.Sprintf("%s.%d", s.path, i+1))
+		}
+		if err := os.Rename(s.path, s.path+".1"); err != nil {
+			return fmt.Errorf("failed to rotate audit file: %w", err)
+		}
+	} else if err := os.Remove(s.path); err != nil {
+		return fmt.Errorf("failed to rotate audit file: %w", err)
+	}
+
+	return s.open()
+}
+
+// DiceDBSink keeps the most recent events in a capped list in the admin DiceDB,
+// newest first
+type DiceDBSink struct {
+	client     *db.DiceDB
+	key        string
+	maxEntries int64
+}
+
+// NewDiceDBSink creates a sink pushing events to the list stored at key
+func NewDiceDBSink(client *db.DiceDB, key string, maxEntries int64) *DiceDBSink {
+	return &DiceDBSink{client: client, key: key, maxEntries: maxEntries}
+}
+
+func (s *DiceDBSink) Name() string { return "dicedb" }
+
+func (s *DiceDBSink) Write(ctx context.Context, events []Event) error {
+	values := make([]interface{}, 0, len(events))
+	for i := range events {
+		data, err := json.Marshal(&events[i])
+		if err != nil {
+			return err
+		}
+		values = append(values, string(data))
+	}
+
+	if err := s.client.Client.LPush(ctx, s.key, values...).Err(); err != nil {
+		return fmt.Errorf("failed to push audit events: %w", err)
+	}
+	if err := s.client.Client.LTrim(ctx, s.key, 0, s.maxEntries-1).Err(); err != nil {
+		return fmt.Errorf("failed to trim audit events: %w", err)
+	}
+	return nil
+}
+
+func (s *DiceDBSink) Close() error { return nil }
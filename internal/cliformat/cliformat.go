@@ -0,0 +1,110 @@
+// Package cliformat renders DiceDB replies the way redis-cli and dicedb-cli
+// print them on a terminal.
+package cliformat
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"server/internal/db"
+)
+
+// Format renders a reply returned by db.ExecuteCommandRaw, including the
+// trailing newline printed by the CLI
+func Format(reply interface{}) string {
+	var sb strings.Builder
+	format(&sb, reply, "")
+	return sb.String()
+}
+
+func format(sb *strings.Builder, reply interface{}, prefix string) {
+	switch v := reply.(type) {
+	case nil:
+		sb.WriteString("(nil)\n")
+	case db.StatusReply:
+		sb.WriteString(string(v))
+		sb.WriteString("\n")
+	case db.ErrorReply:
+		sb.WriteString("(error) ")
+		sb.WriteString(string(v))
+		sb.WriteString("\n")
+	case string:
+		sb.WriteString(quote(v))
+		sb.WriteString("\n")
+	case int64:
+		sb.WriteString("(integer) ")
+		sb.WriteString(strconv.FormatInt(v, 10))
+		sb.WriteString("\n")
+	case float64:
+		sb.WriteString("(double) ")
+		sb.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+		sb.WriteString("\n")
+	case bool:
+		if v {
+			sb.WriteString("(true)\n")
+		} else {
+			sb.WriteString("(false)\n")
+		}
+	case []interface{}:
+		formatArray(sb, v, prefix)
+	default:
+		sb.WriteString(quote(fmt.Sprint(v)))
+		sb.WriteString("\n")
+	}
+}
+
+// formatArray numbers the items of an array, aligning the numbers on the
+// widest one. Nested arrays are indented past the number of their parent.
+func formatArray(sb *strings.Builder, items []interface{}, prefix string) {
+	if len(items) == 0 {
+		sb.WriteString("(empty array)\n")
+		return
+	}
+
+	width := len(strconv.Itoa(len(items)))
+	nested := prefix + strings.Repeat(" ", width+2)
+	for i, item := range items {
+		// The first item continues the line of its parent, which already
+		// holds the indentation
+		if i > 0 {
+			sb.WriteString(prefix)
+		}
+		fmt.Fprintf(sb, "%*d) ", width, i+1)
+		format(sb, item, nested)
+	}
+}
+
+// quote returns s in double quotes, escaping non-printable bytes like
+// sdscatrepr does
+func quote(s string) string {
+	var sb strings.Builder
+	sb.Grow(len(s) + 2)
+	sb.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '\\', '"':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\a':
+			sb.WriteString(`\a`)
+		case '\b':
+			sb.WriteString(`\b`)
+		default:
+			if c >= 0x20 && c <= 0x7e {
+				sb.WriteByte(c)
+			} else {
+				fmt.Fprintf(&sb, `\x%02x`, c)
+			}
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
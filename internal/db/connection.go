@@ -0,0 +1,141 @@
+package db
+
+import (
+	"context"
+	"log/slog"
+	"server/internal/logging"
+	"time"
+)
+
+// ConnectionState is the state of the connection to a DiceDB instance as
+// observed by the connection monitor
+type ConnectionState int
+
+const (
+	// StateConnecting is the initial state, until the instance is reached for the first time
+	StateConnecting ConnectionState = iota
+	// StateConnected means the last ping succeeded
+	StateConnected
+	// StateDisconnected means the instance was reachable before but the last ping failed
+	StateDisconnected
+)
+
+const (
+	// monitorPingTimeout bounds each ping issued by the connection monitor
+	monitorPingTimeout = 2 * time.Second
+	// initialBackoff is the first delay between reconnection attempts
+	initialBackoff = 100 * time.Millisecond
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case StateConnecting:
+		return "connecting"
+	case StateConnected:
+		return "connected"
+	case StateDisconnected:
+		return "disconnected"
+	default:
+		return "unknown"
+	}
+}
+
+// State returns the current connection state
+func (db *DiceDB) State() ConnectionState {
+	db.stateMu.Lock()
+	defer db.stateMu.Unlock()
+	return db.state
+}
+
+// Role returns which of the two DiceDB instances the client talks to, "admin" or "user"
+func (db *DiceDB) Role() string {
+	return db.role
+}
+
+// WaitConnected blocks until the connection monitor reports the instance as
+// connected or the context is done.
+func (db *DiceDB) WaitConnected(ctx context.Context) error {
+	for {
+		db.stateMu.Lock()
+		state, changed := db.state, db.stateChanged
+		db.stateMu.Unlock()
+
+		if state == StateConnected {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Monitor keeps track of the connection to the DiceDB instance until the context
+// is cancelled. While connected it pings the instance every checkInterval, once a
+// ping fails it retries with exponential backoff capped at maxBackoff. The
+// underlying client reconnects on its own, the monitor makes the state visible
+// to startup and health checks and logs every transition.
+func (db *DiceDB) Monitor(ctx context.Context, checkInterval, maxBackoff time.Duration) {
+	attempt := 0
+	for {
+		pingCtx, cancel := context.WithTimeout(ctx, monitorPingTimeout)
+		err := db.Ping(pingCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+
+		wait := checkInterval
+		if err == nil {
+			attempt = 0
+			db.setState(StateConnected, nil)
+		} else {
+			wait = Backoff(attempt, initialBackoff, maxBackoff)
+			attempt++
+			if db.State() == StateConnected {
+				db.setState(StateDisconnected, err)
+			} else {
+				logging.FromContext(ctx, logging.ComponentDB).Debug("DiceDB still unreachable, retrying",
+					slog.String("client", db.role), slog.Duration("retry_in", wait), slog.Any("err", err))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (db *DiceDB) setState(state ConnectionState, err error) {
+	db.stateMu.Lock()
+	defer db.stateMu.Unlock()
+
+	if db.state == state {
+		return
+	}
+
+	logging.Component(logging.ComponentDB).Info("DiceDB connection state changed",
+		slog.String("client", db.role),
+		slog.String("from", db.state.String()),
+		slog.String("to", state.String()),
+		slog.Any("err", err))
+
+	db.state = state
+	// Wake up everyone waiting on a state change
+	close(db.stateChanged)
+	db.stateChanged = make(chan struct{})
+}
+
+// Backoff returns the exponential backoff delay for the given zero-based attempt,
+// doubling from initial and capped at maxDelay.
+func Backoff(attempt int, initial, maxDelay time.Duration) time.Duration {
+	delay := initial
+	for i := 0; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxDelay)
+}
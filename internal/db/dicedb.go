@@ -6,12 +6,17 @@ package db
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"server/config"
+	"server/internal/logging"
 	"server/util/cmds"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/dicedb/dicedb-go"
@@ -22,64 +27,200 @@ const RespNil = "(nil)"
 type DiceDB struct {
 	Client *dicedb.Client
 	Ctx    context.Context
+
+	// Timeouts applied by ExecuteCommand, commandTimeouts holds per-command
+	// overrides of the default commandTimeout keyed by upper-case command name
+	commandTimeout  time.Duration
+	commandTimeouts map[string]time.Duration
+
+	// Connection state maintained by Monitor, stateChanged is closed and
+	// replaced on every transition
+	role         string
+	stateMu      sync.Mutex
+	state        ConnectionState
+	stateChanged chan struct{}
+}
+
+// CommandTimeoutError is returned by ExecuteCommand when a command does not
+// complete within its configured timeout
+type CommandTimeoutError struct {
+	Cmd     string
+	Timeout time.Duration
+}
+
+func (e *CommandTimeoutError) Error() string {
+	return fmt.Sprintf("command %s timed out after %s", e.Cmd, e.Timeout)
+}
+
+// BackendUnavailableError is returned by ExecuteCommand when DiceDB could not
+// be reached, as opposed to an error replied by DiceDB
+type BackendUnavailableError struct {
+	Cmd string
+	Err error
+}
+
+func (e *BackendUnavailableError) Error() string {
+	return fmt.Sprintf("DiceDB is unavailable: %v", e.Err)
+}
+
+func (e *BackendUnavailableError) Unwrap() error {
+	return e.Err
 }
 
 func (db *DiceDB) CloseDiceDB() {
 	err := db.Client.Close()
 	if err != nil {
-		slog.Error("error closing DiceDB connection",
+		logging.Component(logging.ComponentDB).Error("Error closing DiceDB connection", slog.String("client", db.role),
 			slog.Any("error", err))
-		os.Exit(1)
 	}
 }
 
+// InitDiceClient creates a DiceDB client and verifies the connection with a single
+// ping, failing if the instance is unreachable.
 func InitDiceClient(configValue *config.Config, isAdmin bool) (*DiceDB, error) {
-	var diceClient *dicedb.Client
-	if isAdmin {
-		diceClient = dicedb.NewClient(&dicedb.Options{
-			Addr:                 configValue.DiceDBAdmin.Addr,
-			Username:             configValue.DiceDBAdmin.Username,
-			Password:             configValue.DiceDBAdmin.Password,
-			DialTimeout:          10 * time.Second,
-			MaxRetries:           10,
-			EnablePrettyResponse: true,
-		})
-	} else {
-		diceClient = dicedb.NewClient(&dicedb.Options{
-			Addr:                 configValue.DiceDB.Addr,
-			Username:             configValue.DiceDB.Username,
-			Password:             configValue.DiceDB.Password,
-			DialTimeout:          10 * time.Second,
-			MaxRetries:           10,
-			EnablePrettyResponse: true,
-		})
+	client, err := NewDiceClient(configValue, isAdmin)
+	if err != nil {
+		return nil, err
 	}
 
 	// Ping the dicedb client to verify the connection
-	err := diceClient.Ping(context.Background()).Err()
+	if err := client.Ping(context.Background()); err != nil {
+		return nil, fmt.Errorf("failed to connect DiceDB %s client to %s: %w", client.role, client.Client.Options().Addr, err)
+	}
+	client.setState(StateConnected, nil)
+
+	return client, nil
+}
+
+// NewDiceClient creates a DiceDB client without connecting to the instance, it
+// only fails on invalid configuration. Connections are established lazily, use
+// Monitor and WaitConnected to track when the instance becomes reachable.
+func NewDiceClient(configValue *config.Config, isAdmin bool) (*DiceDB, error) {
+	role, dbConfig := "user", configValue.DiceDB
+	if isAdmin {
+		role, dbConfig = "admin", configValue.DiceDBAdmin
+	}
+
+	tlsConfig, err := newTLSConfig(&dbConfig.TLS)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("invalid TLS configuration for DiceDB %s client: %w", role, err)
+	}
+
+	// Socket reads are bounded by the longest command timeout, shorter per-command
+	// timeouts are enforced through the context deadline
+	readTimeout := configValue.Server.CommandTimeout
+	for _, timeout := range configValue.Server.CommandTimeoutOverrides {
+		readTimeout = max(readTimeout, timeout)
 	}
 
+	diceClient := dicedb.NewClient(&dicedb.Options{
+		Addr:                  dbConfig.Addr,
+		Username:              dbConfig.Username,
+		Password:              dbConfig.Password,
+		TLSConfig:             tlsConfig,
+		DialTimeout:           10 * time.Second,
+		ReadTimeout:           readTimeout,
+		ContextTimeoutEnabled: true,
+		// Retries are handled by the retry hook according to the command class
+		MaxRetries:           -1,
+		EnablePrettyResponse: true,
+	})
+	// The tracing and metrics hooks wrap the retry hook so that spans and
+	// latencies include retries
+	diceClient.AddHook(tracingHook{role: role, addr: dbConfig.Addr})
+	diceClient.AddHook(metricsHook{role: role})
+	diceClient.AddHook(retryHook{policy: RetryPolicy{
+		MaxRetries:     configValue.Server.ReadRetryMax,
+		InitialBackoff: configValue.Server.RetryInitialBackoff,
+		MaxBackoff:     configValue.Server.RetryMaxBackoff,
+	}})
+
 	return &DiceDB{
-		Client: diceClient,
-		Ctx:    context.Background(),
+		Client:          diceClient,
+		Ctx:             context.Background(),
+		commandTimeout:  configValue.Server.CommandTimeout,
+		commandTimeouts: configValue.Server.CommandTimeoutOverrides,
+		role:            role,
+		state:           StateConnecting,
+		stateChanged:    make(chan struct{}),
 	}, nil
 }
 
-// ExecuteCommand executes a command based on the input
-func (db *DiceDB) ExecuteCommand(command *cmds.CommandRequest) (interface{}, error) {
-	args := make([]interface{}, 0, len(command.Args)+1)
-	args = append(args, command.Cmd)
-	for _, arg := range command.Args {
-		args = append(args, arg)
+// newTLSConfig builds the TLS configuration of a DiceDB connection, it returns
+// nil when TLS is disabled so that the client falls back to plain TCP.
+func newTLSConfig(tlsValue *config.TLSConfig) (*tls.Config, error) {
+	if !tlsValue.Enabled {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         tlsValue.ServerName,
+		InsecureSkipVerify: tlsValue.InsecureSkipVerify, //nolint:gosec // opt-in for development setups
+	}
+
+	if tlsValue.CAFile != "" {
+		caBundle, err := os.ReadFile(tlsValue.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(caBundle) {
+			return nil, fmt.Errorf("no valid certificates found in CA bundle %s", tlsValue.CAFile)
+		}
+		tlsConfig.RootCAs = certPool
 	}
 
-	res, err := db.Client.Do(db.Ctx, args...).Result()
+	if tlsValue.CertFile != "" || tlsValue.KeyFile != "" {
+		if tlsValue.CertFile == "" || tlsValue.KeyFile == "" {
+			return nil, errors.New("both client certificate and key files must be set")
+		}
+
+		cert, err := tls.LoadX509KeyPair(tlsValue.CertFile, tlsValue.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// Ping checks that the DiceDB instance is reachable
+func (db *DiceDB) Ping(ctx context.Context) error {
+	return db.Client.Ping(ctx).Err()
+}
+
+// CommandTimeout returns the timeout applied to the given command
+func (db *DiceDB) CommandTimeout(cmd string) time.Duration {
+	if timeout, ok := db.commandTimeouts[strings.ToUpper(cmd)]; ok {
+		return timeout
+	}
+	return db.commandTimeout
+}
+
+// ExecuteCommand executes a command based on the input. The command is bound to
+// ctx, usually the HTTP request context, and to the command timeout so that
+// disconnected clients and slow commands do not hold on to a connection.
+func (db *DiceDB) ExecuteCommand(ctx context.Context, command *cmds.CommandRequest) (interface{}, error) {
+	cmd, err := db.execute(ctx, command)
+	if err != nil {
+		return nil, err
+	}
+	return db.render(cmd)
+}
+
+// render returns the reply of a command the way ExecuteCommand does, with
+// error replies as the error
+func (db *DiceDB) render(cmd *dicedb.Cmd) (interface{}, error) {
+	if db.Client.Options().EnablePrettyResponse {
+		cmd.PrettyRender()
+	}
+	res, err := cmd.Result()
 	if errors.Is(err, dicedb.Nil) {
 		return RespNil, nil
 	}
-
 	if err != nil {
 		return nil, fmt.Errorf("%v", err)
 	}
@@ -100,3 +241,74 @@ func (db *DiceDB) ExecuteCommand(command *cmds.CommandRequest) (interface{}, err
 		return fmt.Sprintf("%v", v), nil
 	}
 }
+
+// execute processes the command, bound to ctx and the command timeout. It
+// returns a CommandTimeoutError if the command timed out, the context error if
+// the caller went away and a BackendUnavailableError if DiceDB could not be
+// reached. Otherwise the command holds the reply, which may be an error reply.
+func (db *DiceDB) execute(ctx context.Context, command *cmds.CommandRequest) (*dicedb.Cmd, error) {
+	args := commandArgs(command)
+
+	logging.FromContext(ctx, logging.ComponentDB).Debug("Executing command",
+		slog.String("client", db.role), slog.String("cmd", command.Cmd), logging.Args(command.Args))
+
+	timeout := db.CommandTimeout(command.Cmd)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// The client does not interrupt a blocked read when the context is cancelled,
+	// only once its deadline passes. Run the command asynchronously so that the
+	// caller returns as soon as the client disconnects; the connection itself is
+	// released at the latest when the command timeout expires.
+	process := db.Client.Process
+	pinned := db.pinnedConn(ctx)
+	if pinned != nil {
+		process = pinned.client.Process
+	}
+	cmdCh := make(chan *dicedb.Cmd, 1)
+	go func() {
+		cmd := dicedb.NewCmd(ctx, args...)
+		_ = process(ctx, cmd)
+		cmdCh <- cmd
+	}()
+
+	var cmd *dicedb.Cmd
+	var err error
+	select {
+	case cmd = <-cmdCh:
+		err = cmd.Err()
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	var replyErr dicedb.Error
+	if err == nil || errors.Is(err, dicedb.Nil) || errors.As(err, &replyErr) {
+		return cmd, nil
+	}
+	if pinned != nil {
+		pinned.broken.Store(true)
+	}
+	// The socket read deadline is the context deadline, a timed out read fails
+	// with a network error rather than the context error
+	if deadlineExceeded(ctx) {
+		return nil, &CommandTimeoutError{Cmd: command.Cmd, Timeout: timeout}
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return nil, &BackendUnavailableError{Cmd: command.Cmd, Err: err}
+}
+
+// deadlineExceeded reports whether the context deadline has passed. The socket read
+// deadline is set to the context deadline, so the read can fail before the context
+// itself is marked as done.
+func deadlineExceeded(ctx context.Context) bool {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return true
+	}
+	deadline, ok := ctx.Deadline()
+	return ok && !time.Now().Before(deadline)
+}
@@ -0,0 +1,47 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"server/internal/metrics"
+	"time"
+
+	"github.com/dicedb/dicedb-go"
+)
+
+// metricsHook records the latency and failures of every command processed by
+// a client, retries included. Commands sent in a pipeline or a transaction are
+// recorded with the latency of the whole pipeline.
+type metricsHook struct {
+	role string
+}
+
+var _ dicedb.Hook = metricsHook{}
+
+func (h metricsHook) DialHook(next dicedb.DialHook) dicedb.DialHook {
+	return next
+}
+
+func (h metricsHook) ProcessPipelineHook(next dicedb.ProcessPipelineHook) dicedb.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []dicedb.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		duration := time.Since(start)
+		for _, cmd := range cmds {
+			// An EXEC aborted by a changed WATCHed key is not a failure of the command
+			cmdErr := cmd.Err()
+			metrics.ObserveCommand(h.role, cmd.Name(), duration,
+				cmdErr != nil && !errors.Is(cmdErr, dicedb.Nil) && !errors.Is(cmdErr, dicedb.TxFailedErr))
+		}
+		return err
+	}
+}
+
+func (h metricsHook) ProcessHook(next dicedb.ProcessHook) dicedb.ProcessHook {
+	return func(ctx context.Context, cmd dicedb.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		metrics.ObserveCommand(h.role, cmd.Name(), time.Since(start), err != nil && !errors.Is(err, dicedb.Nil))
+		return err
+	}
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"github.com/dicedb/dicedb-go"
+)
+
+// errConnectionLost is returned once the pinned connection was re-established,
+// the state of the previous connection is gone
+var errConnectionLost = errors.New("pinned connection lost, its state is gone")
+
+// PinnedConn is a dedicated connection reused across requests, so that
+// connection-scoped state such as the selected database or the client name
+// persists between commands. It must not be used by concurrent requests.
+type PinnedConn struct {
+	db     *DiceDB
+	client *dicedb.Client
+	broken atomic.Bool
+}
+
+type pinnedConnKey struct{}
+
+// PinConn opens a dedicated connection outside of the pool, established on
+// first use. It is never handed to other requests and is closed rather than
+// returned to the pool, so that its state cannot leak. Commands are traced and
+// measured but neither retried nor reconnected, either would silently lose the
+// connection state.
+func (db *DiceDB) PinConn() *PinnedConn {
+	p := &PinnedConn{db: db}
+	opts := *db.Client.Options()
+	opts.PoolSize = 1
+	opts.MinIdleConns = 0
+	opts.MaxIdleConns = 1
+	opts.ConnMaxIdleTime = -1 // Idle pinned connections are evicted by their owner
+	opts.ConnMaxLifetime = 0
+	var connected atomic.Bool
+	opts.OnConnect = func(ctx context.Context, cn *dicedb.Conn) error {
+		if connected.Swap(true) {
+			p.broken.Store(true)
+			return errConnectionLost
+		}
+		return nil
+	}
+
+	p.client = dicedb.NewClient(&opts)
+	p.client.AddHook(tracingHook{role: db.role, addr: opts.Addr})
+	p.client.AddHook(metricsHook{role: db.role})
+	return p
+}
+
+// Broken reports whether a command failed without a reply, e.g. timed out or
+// was cancelled. The connection may still be in use, hold an unread reply or
+// have lost its state and must not be reused.
+func (p *PinnedConn) Broken() bool {
+	return p.broken.Load()
+}
+
+// Close closes the connection
+func (p *PinnedConn) Close() error {
+	return p.client.Close()
+}
+
+// WithPinnedConn returns a context executing the commands of the connection's
+// client on the pinned connection instead of a pooled one
+func WithPinnedConn(ctx context.Context, p *PinnedConn) context.Context {
+	return context.WithValue(ctx, pinnedConnKey{}, p)
+}
+
+// pinnedConn returns the connection pinned in ctx for this client, if any
+func (db *DiceDB) pinnedConn(ctx context.Context) *PinnedConn {
+	if p, ok := ctx.Value(pinnedConnKey{}).(*PinnedConn); ok && p.db == db {
+		return p
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"server/internal/logging"
+	"server/util/cmds"
+	"time"
+
+	"github.com/dicedb/dicedb-go"
+)
+
+// ExecutePipelineRaw sends the commands in a single round trip and returns a
+// reply per command the way ExecuteCommandRaw does. The commands are not
+// atomic, other clients' commands may run in between. The pipeline is bound to
+// the longest timeout of its commands, timeouts, cancellations and
+// unavailability are reported like ExecuteCommand.
+func (db *DiceDB) ExecutePipelineRaw(ctx context.Context, commands []*cmds.CommandRequest) ([]interface{}, error) {
+	if len(commands) == 0 {
+		return nil, nil
+	}
+	logging.FromContext(ctx, logging.ComponentDB).Debug("Executing pipeline",
+		slog.String("client", db.role), slog.Int("commands", len(commands)))
+
+	var timeout time.Duration
+	for _, command := range commands {
+		timeout = max(timeout, db.CommandTimeout(command.Cmd))
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	pipelined := db.Client.Pipelined
+	pinned := db.pinnedConn(ctx)
+	if pinned != nil {
+		pipelined = pinned.client.Pipelined
+	}
+	cmdsCh := make(chan []*dicedb.Cmd, 1)
+	go func() {
+		queued := make([]*dicedb.Cmd, len(commands))
+		_, _ = pipelined(ctx, func(pipe dicedb.Pipeliner) error {
+			for i, command := range commands {
+				queued[i] = pipe.Do(ctx, commandArgs(command)...)
+			}
+			return nil
+		})
+		cmdsCh <- queued
+	}()
+
+	var queued []*dicedb.Cmd
+	var err error
+	select {
+	case queued = <-cmdsCh:
+		// Error replies belong to a single command, any other error means the
+		// pipeline failed as a whole
+		for _, cmd := range queued {
+			var replyErr dicedb.Error
+			if cmdErr := cmd.Err(); cmdErr != nil && !errors.Is(cmdErr, dicedb.Nil) && !errors.As(cmdErr, &replyErr) {
+				err = cmdErr
+				break
+			}
+		}
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	if err != nil {
+		if pinned != nil {
+			pinned.broken.Store(true)
+		}
+		switch {
+		case deadlineExceeded(ctx):
+			return nil, &CommandTimeoutError{Cmd: commands[0].Cmd, Timeout: timeout}
+		case ctx.Err() != nil:
+			return nil, ctx.Err()
+		default:
+			return nil, &BackendUnavailableError{Cmd: commands[0].Cmd, Err: err}
+		}
+	}
+
+	replies := make([]interface{}, len(queued))
+	for i, cmd := range queued {
+		replies[i] = rawResult(cmd, commands[i])
+	}
+	return replies, nil
+}
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"server/util/cmds"
+
+	"github.com/dicedb/dicedb-go"
+)
+
+// StatusReply is a status (simple string) reply such as OK
+type StatusReply string
+
+// ErrorReply is an error reply sent by DiceDB, such as a wrong type error.
+// Unlike transport errors it is a regular reply to the command.
+type ErrorReply string
+
+func (e ErrorReply) Error() string {
+	return string(e)
+}
+
+// ExecuteCommandRaw executes a command like ExecuteCommand but returns the reply
+// as received instead of its pretty rendering. Replies are one of string (bulk
+// string), StatusReply, ErrorReply, int64, nil or []interface{} of these.
+// Errors sent by DiceDB are returned as an ErrorReply value, the error is only
+// set for timeouts, cancellations and when DiceDB is unavailable.
+func (db *DiceDB) ExecuteCommandRaw(ctx context.Context, command *cmds.CommandRequest) (interface{}, error) {
+	cmd, err := db.execute(ctx, command)
+	if err != nil {
+		return nil, err
+	}
+
+	return rawResult(cmd, command), nil
+}
+
+// rawResult returns the reply of a processed command the way
+// ExecuteCommandRaw does
+func rawResult(cmd *dicedb.Cmd, command *cmds.CommandRequest) interface{} {
+	res, err := cmd.Result()
+	var replyErr dicedb.Error
+	switch {
+	case errors.Is(err, dicedb.Nil):
+		return nil
+	case errors.As(err, &replyErr):
+		return ErrorReply(replyErr.Error())
+	}
+
+	if s, ok := res.(string); ok && cmds.RepliesWithStatus(command.Cmd, command.Args) {
+		return StatusReply(s)
+	}
+	return rawReply(res)
+}
+
+// rawReply normalizes the values read by the client
+func rawReply(res interface{}) interface{} {
+	switch v := res.(type) {
+	case []byte:
+		return string(v)
+	case error:
+		return ErrorReply(v.Error())
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = rawReply(item)
+		}
+		return items
+	default:
+		return v
+	}
+}
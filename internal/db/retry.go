@@ -0,0 +1,159 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"server/internal/logging"
+	"strings"
+	"time"
+
+	"github.com/dicedb/dicedb-go"
+)
+
+// CommandClass describes whether a command can safely be sent again after a failure
+type CommandClass int
+
+const (
+	// ClassNonIdempotentWrite commands may be applied twice when retried, e.g. INCR,
+	// LPUSH or PFADD. Unknown commands fall into this class.
+	ClassNonIdempotentWrite CommandClass = iota
+	// ClassRead commands never modify the keyspace
+	ClassRead
+	// ClassIdempotentWrite commands leave the keyspace and their reply unchanged
+	// when applied more than once, e.g. a plain SET
+	ClassIdempotentWrite
+)
+
+// readCommands never modify the keyspace and are safe to retry
+var readCommands = map[string]struct{}{
+	"BITCOUNT": {}, "BITPOS": {}, "COMMAND": {}, "DBSIZE": {}, "ECHO": {}, "EXISTS": {},
+	"EXPIRETIME": {}, "GEODIST": {}, "GEOHASH": {}, "GEOPOS": {}, "GET": {}, "GETBIT": {},
+	"GETRANGE": {}, "HEXISTS": {}, "HGET": {}, "HGETALL": {}, "HKEYS": {}, "HLEN": {},
+	"HMGET": {}, "HRANDFIELD": {}, "HSCAN": {}, "HSTRLEN": {}, "HVALS": {}, "JSON.GET": {},
+	"JSON.TYPE": {}, "KEYS": {}, "LINDEX": {}, "LLEN": {}, "LPOS": {}, "LRANGE": {},
+	"MGET": {}, "OBJECT": {}, "PEXPIRETIME": {}, "PFCOUNT": {}, "PING": {}, "PTTL": {},
+	"RANDOMKEY": {}, "SCAN": {}, "SCARD": {}, "SISMEMBER": {}, "SMEMBERS": {},
+	"SRANDMEMBER": {}, "SSCAN": {}, "STRLEN": {}, "TTL": {}, "TYPE": {}, "ZCARD": {},
+	"ZCOUNT": {}, "ZRANGE": {}, "ZRANGEBYSCORE": {}, "ZRANK": {}, "ZREVRANGE": {},
+	"ZREVRANK": {}, "ZSCAN": {}, "ZSCORE": {},
+}
+
+// idempotentWriteCommands produce the same keyspace and the same reply no matter
+// how many times they are applied
+var idempotentWriteCommands = map[string]struct{}{
+	"EXPIREAT": {}, "FLUSHDB": {}, "HMSET": {}, "LSET": {}, "MSET": {}, "PEXPIREAT": {},
+	"PSETEX": {}, "SET": {}, "SETEX": {},
+}
+
+// ClassifyCommand returns the retry class of a command given its name and arguments
+func ClassifyCommand(cmd string, args []string) CommandClass {
+	cmd = strings.ToUpper(cmd)
+	if _, ok := readCommands[cmd]; ok {
+		return ClassRead
+	}
+
+	if _, ok := idempotentWriteCommands[cmd]; ok {
+		// Conditional and GET variants of SET reply differently once the value is set
+		if cmd == "SET" {
+			for _, arg := range args {
+				switch strings.ToUpper(arg) {
+				case "NX", "XX", "GET":
+					return ClassNonIdempotentWrite
+				}
+			}
+		}
+		return ClassIdempotentWrite
+	}
+
+	return ClassNonIdempotentWrite
+}
+
+// RetryPolicy decides how often a failed command is retried. Only transport
+// failures are retried, errors replied by DiceDB are returned as is.
+type RetryPolicy struct {
+	MaxRetries     int           // Retries for reads and idempotent writes, non-idempotent writes are never retried
+	InitialBackoff time.Duration // Delay before the first retry, doubled on every further attempt
+	MaxBackoff     time.Duration // Upper bound of the delay between retries
+}
+
+// retriesFor returns how many times a command may be retried under the policy
+func (p RetryPolicy) retriesFor(cmd string, args []string) int {
+	if ClassifyCommand(cmd, args) == ClassNonIdempotentWrite {
+		return 0
+	}
+	return p.MaxRetries
+}
+
+// retryHook applies the retry policy to every command processed by a client.
+// Built-in client retries must be disabled for the policy to be effective.
+type retryHook struct {
+	policy RetryPolicy
+}
+
+var _ dicedb.Hook = retryHook{}
+
+func (h retryHook) DialHook(next dicedb.DialHook) dicedb.DialHook {
+	return next
+}
+
+func (h retryHook) ProcessPipelineHook(next dicedb.ProcessPipelineHook) dicedb.ProcessPipelineHook {
+	return next
+}
+
+func (h retryHook) ProcessHook(next dicedb.ProcessHook) dicedb.ProcessHook {
+	return func(ctx context.Context, cmd dicedb.Cmder) error {
+		name, args := commandNameAndArgs(cmd)
+		maxRetries := h.policy.retriesFor(name, args)
+
+		for attempt := 0; ; attempt++ {
+			err := next(ctx, cmd)
+			if err == nil || attempt >= maxRetries || !isRetryable(err) {
+				return err
+			}
+
+			backoff := Backoff(attempt, h.policy.InitialBackoff, h.policy.MaxBackoff)
+			logging.FromContext(ctx, logging.ComponentDB).Debug("Retrying DiceDB command", slog.String("cmd", name), slog.Int("attempt", attempt+1),
+				slog.Duration("backoff", backoff), slog.Any("err", err))
+
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(backoff):
+			}
+		}
+	}
+}
+
+// commandNameAndArgs extracts the command name and its string arguments
+func commandNameAndArgs(cmd dicedb.Cmder) (name string, args []string) {
+	cmdArgs := cmd.Args()
+	args = make([]string, 0, len(cmdArgs))
+	for _, arg := range cmdArgs[min(1, len(cmdArgs)):] {
+		if s, ok := arg.(string); ok {
+			args = append(args, s)
+		}
+	}
+	return cmd.Name(), args
+}
+
+// isRetryable reports whether err is a transport failure, as opposed to an error
+// replied by DiceDB or a cancelled request
+func isRetryable(err error) bool {
+	var diceErr dicedb.Error
+	switch {
+	case errors.As(err, &diceErr),
+		errors.Is(err, dicedb.Nil),
+		errors.Is(err, dicedb.ErrClosed),
+		errors.Is(err, context.Canceled),
+		errors.Is(err, context.DeadlineExceeded):
+		return false
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		strings.Contains(err.Error(), "connection reset")
+}
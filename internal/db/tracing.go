@@ -0,0 +1,84 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"server/internal/metrics"
+	"server/internal/tracing"
+
+	"github.com/dicedb/dicedb-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracingHook records a client span for every command issued as part of a
+// traced operation, and one for every pipeline or transaction listing the names
+// of its commands. Only command names are recorded, never keys or values.
+// Commands without a parent span (e.g. connection monitor pings) are not traced.
+type tracingHook struct {
+	role string
+	addr string
+}
+
+var _ dicedb.Hook = tracingHook{}
+
+func (h tracingHook) DialHook(next dicedb.DialHook) dicedb.DialHook {
+	return next
+}
+
+func (h tracingHook) ProcessPipelineHook(next dicedb.ProcessPipelineHook) dicedb.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []dicedb.Cmder) error {
+		if !trace.SpanContextFromContext(ctx).IsValid() {
+			return next(ctx, cmds)
+		}
+
+		names := make([]string, 0, len(cmds))
+		for _, cmd := range cmds {
+			names = append(names, metrics.CommandLabel(cmd.Name()))
+		}
+		ctx, span := h.start(ctx, "pipeline", attribute.StringSlice("dicedb.commands", names))
+		defer span.End()
+
+		// An EXEC aborted by a changed WATCHed key is not an error of the pipeline
+		err := next(ctx, cmds)
+		if err != nil && !errors.Is(err, dicedb.Nil) && !errors.Is(err, dicedb.TxFailedErr) {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return err
+	}
+}
+
+func (h tracingHook) ProcessHook(next dicedb.ProcessHook) dicedb.ProcessHook {
+	return func(ctx context.Context, cmd dicedb.Cmder) error {
+		if !trace.SpanContextFromContext(ctx).IsValid() {
+			return next(ctx, cmd)
+		}
+
+		// The label bounds the span name the same way as the command metrics
+		ctx, span := h.start(ctx, metrics.CommandLabel(cmd.Name()))
+		defer span.End()
+
+		err := next(ctx, cmd)
+		if err != nil && !errors.Is(err, dicedb.Nil) {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return err
+	}
+}
+
+// start starts the client span of the operation
+func (h tracingHook) start(ctx context.Context, operation string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, "dicedb "+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemKey.String("dicedb"),
+			semconv.DBOperationName(operation),
+			semconv.ServerAddress(h.addr),
+			attribute.String("dicedb.client", h.role),
+		),
+		trace.WithAttributes(attrs...))
+}
@@ -0,0 +1,117 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"server/internal/logging"
+	"server/util/cmds"
+	"strings"
+
+	"github.com/dicedb/dicedb-go"
+)
+
+// ErrTransactionAborted is returned when a watched key changed between WATCH
+// and EXEC, none of the queued commands were executed
+var ErrTransactionAborted = errors.New("transaction aborted because a watched key changed")
+
+// TransactionResult is the outcome of a queued command. Reply is rendered the
+// way ExecuteCommand does, Err holds the error reply of a failed command.
+type TransactionResult struct {
+	Reply interface{}
+	Err   error
+}
+
+// ExecuteTransaction watches the keys, then executes the commands between
+// MULTI and EXEC on a single connection, which is pinned for the duration of
+// the transaction. The pinned connection of a session in ctx is used if any. It returns a result per command, ErrTransactionAborted if a
+// watched key changed or an ErrorReply if DiceDB discarded the transaction.
+// Timeouts, cancellations and unavailability are reported like ExecuteCommand.
+func (db *DiceDB) ExecuteTransaction(ctx context.Context, watch []string,
+	commands []*cmds.CommandRequest) ([]TransactionResult, error) {
+	logging.FromContext(ctx, logging.ComponentDB).Debug("Executing transaction",
+		slog.String("client", db.role), slog.Int("commands", len(commands)), slog.Int("watched_keys", len(watch)))
+
+	timeout := db.CommandTimeout("EXEC")
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// Like single commands the transaction runs asynchronously, so that the
+	// caller returns as soon as the client disconnects
+	type outcome struct {
+		cmds []*dicedb.Cmd
+		err  error
+	}
+	watchKeys := db.Client.Watch
+	pinned := db.pinnedConn(ctx)
+	if pinned != nil {
+		watchKeys = pinned.client.Watch
+	}
+	outcomeCh := make(chan outcome, 1)
+	go func() {
+		var queued []*dicedb.Cmd
+		err := watchKeys(ctx, func(tx *dicedb.Tx) error {
+			_, err := tx.TxPipelined(ctx, func(pipe dicedb.Pipeliner) error {
+				for _, command := range commands {
+					queued = append(queued, pipe.Do(ctx, commandArgs(command)...))
+				}
+				return nil
+			})
+			return err
+		}, watch...)
+		outcomeCh <- outcome{cmds: queued, err: err}
+	}()
+
+	var result outcome
+	select {
+	case result = <-outcomeCh:
+	case <-ctx.Done():
+		result.err = ctx.Err()
+	}
+
+	var replyErr dicedb.Error
+	failed := result.err != nil && !errors.Is(result.err, dicedb.Nil) &&
+		!errors.Is(result.err, dicedb.TxFailedErr) && !errors.As(result.err, &replyErr)
+	if failed && pinned != nil {
+		// Failing without a reply leaves the pinned connection in an unknown state
+		pinned.broken.Store(true)
+	}
+	switch {
+	case errors.Is(result.err, dicedb.TxFailedErr):
+		return nil, ErrTransactionAborted
+	case result.err == nil, errors.Is(result.err, dicedb.Nil):
+	case errors.As(result.err, &replyErr):
+		// EXECABORT is set on every command when one of them was rejected while
+		// queueing, and WATCH or MULTI failing leaves no queued command.
+		// Otherwise the error belongs to one of the commands.
+		if strings.HasPrefix(replyErr.Error(), "EXECABORT") || len(result.cmds) != len(commands) {
+			return nil, ErrorReply(replyErr.Error())
+		}
+	case deadlineExceeded(ctx):
+		return nil, &CommandTimeoutError{Cmd: "EXEC", Timeout: timeout}
+	case ctx.Err() != nil:
+		return nil, ctx.Err()
+	default:
+		return nil, &BackendUnavailableError{Cmd: "EXEC", Err: result.err}
+	}
+
+	results := make([]TransactionResult, len(result.cmds))
+	for i, cmd := range result.cmds {
+		reply, err := db.render(cmd)
+		results[i] = TransactionResult{Reply: reply, Err: err}
+	}
+	return results, nil
+}
+
+// commandArgs returns the command name followed by its arguments
+func commandArgs(command *cmds.CommandRequest) []interface{} {
+	args := make([]interface{}, 0, len(command.Args)+1)
+	args = append(args, command.Cmd)
+	for _, arg := range command.Args {
+		args = append(args, arg)
+	}
+	return args
+}
@@ -0,0 +1,72 @@
+// Package explain enriches command errors with what users need to fix them:
+// the closest command names, the command syntax, a link to its reference and
+// an explanation of common errors.
+package explain
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"server/internal/apierror"
+	"server/internal/db"
+	"server/internal/logging"
+	"server/util/cmds"
+)
+
+// typeLookupTimeout bounds the TYPE command used to explain WRONGTYPE errors
+const typeLookupTimeout = 500 * time.Millisecond
+
+// Enrich adds suggestions, the syntax, the docs link and an explanation to the
+// error of a command. client is used to look up the actual type of the key on
+// WRONGTYPE errors, it may be nil to skip the lookup.
+func Enrich(ctx context.Context, client *db.DiceDB, command *cmds.CommandRequest, err *apierror.Error) *apierror.Error {
+	switch err.Code {
+	case apierror.CodeUnknownCommand:
+		err.Suggestions = cmds.Suggest(command.Cmd)
+		if len(err.Suggestions) > 0 {
+			err.Hint = fmt.Sprintf("Did you mean %s?", strings.Join(err.Suggestions, " or "))
+		}
+	case apierror.CodeSyntax:
+		addReference(err, command.Cmd)
+		if err.Syntax != "" {
+			err.Hint = "Usage: " + err.Syntax
+		}
+	case apierror.CodeWrongType:
+		addReference(err, command.Cmd)
+		explainWrongType(ctx, client, command, err)
+	case apierror.CodeCommandError:
+		addReference(err, command.Cmd)
+	}
+	return err
+}
+
+func addReference(err *apierror.Error, cmd string) {
+	err.Syntax, _ = cmds.Syntax(cmd)
+	err.DocsURL = cmds.DocsURL(cmd)
+}
+
+// explainWrongType looks up the type of the key the command failed on, which
+// is only unambiguous for commands taking a single key
+func explainWrongType(ctx context.Context, client *db.DiceDB, command *cmds.CommandRequest, err *apierror.Error) {
+	keys := cmds.KeyNames(command.Cmd, command.Args)
+	if client == nil || len(keys) != 1 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, typeLookupTimeout)
+	defer cancel()
+	reply, lookupErr := client.ExecuteCommandRaw(ctx, &cmds.CommandRequest{Cmd: "TYPE", Args: keys})
+	keyType, ok := reply.(db.StatusReply)
+	if lookupErr != nil || !ok || keyType == "none" {
+		logging.FromContext(ctx, logging.ComponentHTTP).Debug("Failed to look up the key type",
+			slog.String("cmd", command.Cmd), slog.Any("err", lookupErr))
+		return
+	}
+
+	err.Hint = fmt.Sprintf("The key %q holds a %s value, which %s cannot operate on. Use a command for %s values or another key.",
+		keys[0], keyType, strings.ToUpper(command.Cmd), keyType)
+	err.WithDetail("key", keys[0]).WithDetail("key_type", string(keyType))
+}
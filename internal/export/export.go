@@ -0,0 +1,145 @@
+// Package export renders replies as tables for NDJSON and CSV exports. Replies
+// of pairs such as HGETALL have a row per pair, other arrays a row per item and
+// scalars a single row.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+
+	"server/internal/db"
+	"server/util/cmds"
+)
+
+// valueColumn is the column of replies that are not pairs
+const valueColumn = "value"
+
+// Table is a reply laid out in rows. Columns is empty for arrays of arrays,
+// whose rows have as many cells as the inner arrays.
+type Table struct {
+	Columns []string
+	Rows    [][]interface{}
+}
+
+// TableOf lays out a reply returned by db.ExecuteCommandRaw for the command
+func TableOf(diceCmd *cmds.CommandRequest, reply interface{}) Table {
+	items, ok := reply.([]interface{})
+	if !ok {
+		return Table{Columns: []string{valueColumn}, Rows: [][]interface{}{{cell(reply)}}}
+	}
+
+	if columns, ok := cmds.PairColumns(diceCmd.Cmd, diceCmd.Args); ok && len(items)%2 == 0 {
+		table := Table{Columns: columns[:], Rows: make([][]interface{}, 0, len(items)/2)}
+		for i := 0; i < len(items); i += 2 {
+			table.Rows = append(table.Rows, []interface{}{cell(items[i]), cell(items[i+1])})
+		}
+		return table
+	}
+
+	if nested(items) {
+		table := Table{Rows: make([][]interface{}, 0, len(items))}
+		for _, item := range items {
+			inner, ok := item.([]interface{})
+			if !ok {
+				inner = []interface{}{item}
+			}
+			row := make([]interface{}, len(inner))
+			for i, value := range inner {
+				row[i] = cell(value)
+			}
+			table.Rows = append(table.Rows, row)
+		}
+		return table
+	}
+
+	table := Table{Columns: []string{valueColumn}, Rows: make([][]interface{}, 0, len(items))}
+	for _, item := range items {
+		table.Rows = append(table.Rows, []interface{}{cell(item)})
+	}
+	return table
+}
+
+// WriteNDJSON writes a JSON object keyed by the column names per row, or a
+// JSON array per row for tables without columns
+func WriteNDJSON(w io.Writer, table Table) error {
+	encoder := json.NewEncoder(w)
+	for _, row := range table.Rows {
+		var line interface{} = row
+		if len(table.Columns) > 0 {
+			object := make(map[string]interface{}, len(row))
+			for i, column := range table.Columns {
+				object[column] = row[i]
+			}
+			line = object
+		}
+		if err := encoder.Encode(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteCSV writes the column names as the header followed by the rows. Nil
+// values are written as empty cells, nested arrays as JSON.
+func WriteCSV(w io.Writer, table Table) error {
+	writer := csv.NewWriter(w)
+	if len(table.Columns) > 0 {
+		if err := writer.Write(table.Columns); err != nil {
+			return err
+		}
+	}
+
+	for _, row := range table.Rows {
+		record := make([]string, len(row))
+		for i, value := range row {
+			record[i] = csvCell(value)
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// cell converts a reply to the value of a cell, status replies are plain strings
+func cell(reply interface{}) interface{} {
+	switch v := reply.(type) {
+	case db.StatusReply:
+		return string(v)
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, item := range v {
+			values[i] = cell(item)
+		}
+		return values
+	default:
+		return v
+	}
+}
+
+func csvCell(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
+
+// nested reports whether any item of the array is an array itself
+func nested(items []interface{}) bool {
+	for _, item := range items {
+		if _, ok := item.([]interface{}); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,245 @@
+package keyspace
+
+import (
+	"context"
+	"reflect"
+	"server/internal/db"
+	"slices"
+	"sort"
+	"time"
+)
+
+// Changes of a key between two captures
+const (
+	ChangeCreated   = "created"
+	ChangeDeleted   = "deleted"
+	ChangeModified  = "modified"
+	ChangeUnchanged = "unchanged"
+)
+
+// Parts of a key that can change
+const (
+	FieldType  = "type"
+	FieldValue = "value"
+	FieldTTL   = "ttl"
+)
+
+// MaxDiffKeys is the maximum number of keys diffed for a command
+const MaxDiffKeys = 10
+
+// DiffLimits caps the values captured for a diff
+var DiffLimits = Limits{MaxElements: 50, MaxBytes: 1024}
+
+// Diff is the effect of a command on the keys it touches. Truncated is set
+// when the command touches more than MaxDiffKeys keys, the others are left out.
+type Diff struct {
+	Keys      []KeyDiff `json:"keys"`
+	Truncated bool      `json:"truncated,omitempty"`
+}
+
+// KeyDiff is the change of a key. Before and After are unset when the key did
+// not exist, Fields lists the parts of a modified key that changed.
+type KeyDiff struct {
+	Key      string       `json:"key"`
+	Change   string       `json:"change"`
+	Fields   []string     `json:"fields,omitempty"`
+	Before   *Value       `json:"before,omitempty"`
+	After    *Value       `json:"after,omitempty"`
+	Elements *ElementDiff `json:"elements,omitempty"`
+}
+
+// ElementDiff details the change of a hash, set or sorted set. Added holds the
+// added fields with their value, members, or members with their score. It is
+// only computed when neither capture of the value is truncated.
+type ElementDiff struct {
+	Added   interface{}       `json:"added,omitempty"`
+	Removed []string          `json:"removed,omitempty"`
+	Updated map[string]Update `json:"updated,omitempty"` // Fields whose value or members whose score changed
+}
+
+// Update is the change of a hash field value or of a sorted set member score
+type Update struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// Differ captures the keys touched by a command before it is executed and
+// diffs them against their state afterwards
+type Differ struct {
+	client     *db.DiceDB
+	names      []string
+	before     []*Value
+	capturedAt time.Time // When the capture of the keys before the command started
+	truncated  bool
+}
+
+// NewDiffer captures the keys, duplicates are captured once. At most
+// MaxDiffKeys keys are captured.
+func NewDiffer(ctx context.Context, client *db.DiceDB, names []string) (*Differ, error) {
+	d := &Differ{client: client}
+	seen := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		if len(d.names) == MaxDiffKeys {
+			d.truncated = true
+			break
+		}
+		d.names = append(d.names, name)
+	}
+
+	var err error
+	d.capturedAt = time.Now()
+	if d.before, err = Capture(ctx, client, d.names, DiffLimits); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Diff captures the keys again and returns their changes since NewDiffer.
+// Commands of other clients running in between are included in the diff.
+func (d *Differ) Diff(ctx context.Context) (*Diff, error) {
+	after, err := Capture(ctx, d.client, d.names, DiffLimits)
+	if err != nil {
+		return nil, err
+	}
+	elapsed := time.Since(d.capturedAt)
+
+	diff := &Diff{Keys: make([]KeyDiff, len(d.names)), Truncated: d.truncated}
+	for i, name := range d.names {
+		diff.Keys[i] = Compare(name, d.before[i], after[i], elapsed)
+	}
+	return diff, nil
+}
+
+// Compare returns the change of a key between two captures elapsed apart, nil
+// meaning that the key did not exist. The TTL of an expiring key decreasing by
+// no more than elapsed is not a change.
+func Compare(name string, before, after *Value, elapsed time.Duration) KeyDiff {
+	diff := KeyDiff{Key: name, Before: before, After: after}
+	switch {
+	case before == nil && after == nil:
+		diff.Change = ChangeUnchanged
+		return diff
+	case before == nil:
+		diff.Change = ChangeCreated
+		return diff
+	case after == nil:
+		diff.Change = ChangeDeleted
+		return diff
+	}
+
+	if before.Type != after.Type {
+		diff.Fields = append(diff.Fields, FieldType)
+	}
+	if before.Length != after.Length || before.Truncated != after.Truncated ||
+		!reflect.DeepEqual(before.Value, after.Value) {
+		diff.Fields = append(diff.Fields, FieldValue)
+	}
+	if ttlChanged(before.ttlMs, after.ttlMs, elapsed) {
+		diff.Fields = append(diff.Fields, FieldTTL)
+	}
+	diff.Change = ChangeUnchanged
+	if len(diff.Fields) > 0 {
+		diff.Change = ChangeModified
+	}
+	if before.Type == after.Type && !before.Truncated && !after.Truncated && slices.Contains(diff.Fields, FieldValue) {
+		diff.Elements = compareElements(before, after)
+	}
+	return diff
+}
+
+// ttlChanged reports whether a TTL in milliseconds changed other than by
+// counting down between two captures elapsed apart
+func ttlChanged(before, after int64, elapsed time.Duration) bool {
+	if before < 0 || after < 0 {
+		return before != after
+	}
+	return after > before || before-after > elapsed.Milliseconds()
+}
+
+// compareElements returns the element changes of hashes, sets and sorted sets
+// of the same type, nil for other types
+func compareElements(before, after *Value) *ElementDiff {
+	var elements ElementDiff
+	switch before.Type {
+	case TypeHash:
+		oldHash, newHash := before.Value.(map[string]string), after.Value.(map[string]string)
+		added := make(map[string]string)
+		for field, value := range newHash {
+			oldValue, ok := oldHash[field]
+			switch {
+			case !ok:
+				added[field] = value
+			case oldValue != value:
+				elements.update(field, oldValue, value)
+			}
+		}
+		elements.Removed = missing(oldHash, newHash)
+		if len(added) > 0 {
+			elements.Added = added
+		}
+	case TypeSet:
+		oldSet, newSet := setOf(before.Value.([]string)), setOf(after.Value.([]string))
+		if added := missing(newSet, oldSet); len(added) > 0 {
+			elements.Added = added
+		}
+		elements.Removed = missing(oldSet, newSet)
+	case TypeZSet:
+		oldScores, newScores := scoresOf(before.Value.([]Member)), scoresOf(after.Value.([]Member))
+		var added []Member
+		for _, member := range after.Value.([]Member) {
+			oldScore, ok := oldScores[member.Member]
+			switch {
+			case !ok:
+				added = append(added, member)
+			case oldScore != member.Score:
+				elements.update(member.Member, oldScore, member.Score)
+			}
+		}
+		elements.Removed = missing(oldScores, newScores)
+		if len(added) > 0 {
+			elements.Added = added
+		}
+	default:
+		return nil
+	}
+	return &elements
+}
+
+func (e *ElementDiff) update(name string, before, after interface{}) {
+	if e.Updated == nil {
+		e.Updated = make(map[string]Update)
+	}
+	e.Updated[name] = Update{Before: before, After: after}
+}
+
+// missing returns the keys of a that are not in b, sorted
+func missing[V any](a, b map[string]V) []string {
+	var names []string
+	for name := range a {
+		if _, ok := b[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func setOf(members []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(members))
+	for _, member := range members {
+		set[member] = struct{}{}
+	}
+	return set
+}
+
+func scoresOf(members []Member) map[string]float64 {
+	scores := make(map[string]float64, len(members))
+	for _, member := range members {
+		scores[member.Member] = member.Score
+	}
+	return scores
+}
@@ -0,0 +1,257 @@
+// Package keyspace browses the keys of the user DiceDB: it pages through keys
+// with SCAN and renders values according to their type.
+package keyspace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"server/internal/db"
+	"server/util/cmds"
+	"strconv"
+	"strings"
+)
+
+// Types of values, as reported by TYPE except for HyperLogLogs which DiceDB
+// reports as strings
+const (
+	TypeString      = "string"
+	TypeHash        = "hash"
+	TypeList        = "list"
+	TypeSet         = "set"
+	TypeZSet        = "zset"
+	TypeHyperLogLog = "hyperloglog"
+	TypeNone        = "none"
+)
+
+const (
+	// DefaultScanCount is the number of keys scanned per page by default
+	DefaultScanCount = 50
+	// MaxScanCount is the maximum number of keys scanned per page
+	MaxScanCount = 1000
+)
+
+var (
+	// ErrNotFound is returned for keys that do not exist
+	ErrNotFound = errors.New("key does not exist")
+	// ErrInvalidCursor is returned for cursors that are not SCAN cursors
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrInvalidType is returned when filtering on an unknown type
+	ErrInvalidType = errors.New("invalid type, use one of string, hash, list, set, zset or hyperloglog")
+)
+
+// Key describes a key without its value
+type Key struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	TTL         int64  `json:"ttl"`                    // Seconds until the key expires, -1 if it does not expire
+	MemoryBytes *int64 `json:"memory_bytes,omitempty"` // Approximate memory used by the key, unset if unknown
+
+	ttlMs int64 // Milliseconds until the key expires, -1 if it does not expire
+}
+
+// ScanOptions selects the keys of a page. Cursor is the cursor of the previous
+// page, empty or "0" for the first page.
+type ScanOptions struct {
+	Cursor  string
+	Pattern string
+	Type    string
+	Count   int
+}
+
+// Page is a page of keys. Pages may hold fewer keys than requested, even none,
+// until Done is set. Cursor fetches the next page.
+type Page struct {
+	Keys   []Key  `json:"keys"`
+	Cursor string `json:"cursor"`
+	Done   bool   `json:"done"`
+}
+
+// Scan returns a page of keys matching the options
+func Scan(ctx context.Context, client *db.DiceDB, opts ScanOptions) (Page, error) {
+	cursor := opts.Cursor
+	if cursor == "" {
+		cursor = "0"
+	}
+	if _, err := strconv.ParseUint(cursor, 10, 64); err != nil {
+		return Page{}, ErrInvalidCursor
+	}
+	keyType := strings.ToLower(opts.Type)
+	if keyType != "" && !knownType(keyType) {
+		return Page{}, ErrInvalidType
+	}
+	pattern := opts.Pattern
+	if pattern == "" {
+		pattern = "*"
+	}
+	count := opts.Count
+	if count <= 0 {
+		count = DefaultScanCount
+	}
+	count = min(count, MaxScanCount)
+
+	reply, err := client.ExecuteCommandRaw(ctx, &cmds.CommandRequest{
+		Cmd:  "SCAN",
+		Args: []string{cursor, "MATCH", pattern, "COUNT", strconv.Itoa(count)},
+	})
+	if err != nil {
+		return Page{}, err
+	}
+	next, names, err := scanReply(reply)
+	if err != nil {
+		return Page{}, err
+	}
+
+	described, err := Describe(ctx, client, names...)
+	if err != nil {
+		return Page{}, err
+	}
+	page := Page{Keys: make([]Key, 0, len(described)), Cursor: next, Done: next == "0"}
+	for _, key := range described {
+		// Keys deleted since the scan are skipped
+		if key.Type == TypeNone || keyType != "" && key.Type != keyType {
+			continue
+		}
+		page.Keys = append(page.Keys, key)
+	}
+	return page, nil
+}
+
+// ttlSeconds rounds a PTTL reply to seconds the way TTL does, keeping the
+// negative replies for keys without expiry or that do not exist
+func ttlSeconds(ttlMs int64) int64 {
+	if ttlMs < 0 {
+		return ttlMs
+	}
+	return (ttlMs + 500) / 1000
+}
+
+// Describe returns the type, TTL and memory usage of the keys. Keys that do
+// not exist have the type TypeNone.
+func Describe(ctx context.Context, client *db.DiceDB, names ...string) ([]Key, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	commands := make([]*cmds.CommandRequest, 0, 3*len(names))
+	for _, name := range names {
+		commands = append(commands,
+			&cmds.CommandRequest{Cmd: "TYPE", Args: []string{name}},
+			&cmds.CommandRequest{Cmd: "PTTL", Args: []string{name}},
+			&cmds.CommandRequest{Cmd: "MEMORY", Args: []string{"USAGE", name}},
+		)
+	}
+	replies, err := client.ExecutePipelineRaw(ctx, commands)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]Key, len(names))
+	var stringKeys []int
+	for i, name := range names {
+		keyType, err := statusReply(replies[3*i])
+		if err != nil {
+			return nil, err
+		}
+		ttlMs, err := integerReply(replies[3*i+1])
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = Key{Name: name, Type: strings.ToLower(keyType), TTL: ttlSeconds(ttlMs), ttlMs: ttlMs}
+		// MEMORY USAGE is optional, the memory is unknown if it fails
+		if memory, err := integerReply(replies[3*i+2]); err == nil {
+			keys[i].MemoryBytes = &memory
+		}
+		if keys[i].Type == TypeString {
+			stringKeys = append(stringKeys, i)
+		}
+	}
+
+	// HyperLogLogs are strings to TYPE, only they can be counted by PFCOUNT
+	if len(stringKeys) == 0 {
+		return keys, nil
+	}
+	commands = commands[:0]
+	for _, i := range stringKeys {
+		commands = append(commands, &cmds.CommandRequest{Cmd: "PFCOUNT", Args: []string{names[i]}})
+	}
+	if replies, err = client.ExecutePipelineRaw(ctx, commands); err != nil {
+		return nil, err
+	}
+	for j, i := range stringKeys {
+		if _, isError := replies[j].(db.ErrorReply); !isError {
+			keys[i].Type = TypeHyperLogLog
+		}
+	}
+	return keys, nil
+}
+
+func knownType(keyType string) bool {
+	switch keyType {
+	case TypeString, TypeHash, TypeList, TypeSet, TypeZSet, TypeHyperLogLog:
+		return true
+	}
+	return false
+}
+
+// scanReply returns the next cursor and the keys of a SCAN reply
+func scanReply(reply interface{}) (string, []string, error) {
+	if err, ok := reply.(db.ErrorReply); ok {
+		return "", nil, err
+	}
+	items, ok := reply.([]interface{})
+	if !ok || len(items) != 2 {
+		return "", nil, fmt.Errorf("unexpected SCAN reply %T", reply)
+	}
+	cursor, ok := items[0].(string)
+	if !ok {
+		return "", nil, fmt.Errorf("unexpected SCAN cursor %T", items[0])
+	}
+	names, err := stringsReply(items[1])
+	return cursor, names, err
+}
+
+// statusReply returns the value of a status or bulk string reply
+func statusReply(reply interface{}) (string, error) {
+	switch v := reply.(type) {
+	case db.StatusReply:
+		return string(v), nil
+	case string:
+		return v, nil
+	case db.ErrorReply:
+		return "", v
+	}
+	return "", fmt.Errorf("unexpected reply %T, expected a string", reply)
+}
+
+// integerReply returns the value of an integer reply
+func integerReply(reply interface{}) (int64, error) {
+	switch v := reply.(type) {
+	case int64:
+		return v, nil
+	case db.ErrorReply:
+		return 0, v
+	}
+	return 0, fmt.Errorf("unexpected reply %T, expected an integer", reply)
+}
+
+// stringsReply returns the values of an array of bulk strings
+func stringsReply(reply interface{}) ([]string, error) {
+	if err, ok := reply.(db.ErrorReply); ok {
+		return nil, err
+	}
+	if reply == nil {
+		return nil, nil
+	}
+	items, ok := reply.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected reply %T, expected an array", reply)
+	}
+	values := make([]string, len(items))
+	for i, item := range items {
+		if values[i], ok = item.(string); !ok {
+			return nil, fmt.Errorf("unexpected array item %T, expected a string", item)
+		}
+	}
+	return values, nil
+}
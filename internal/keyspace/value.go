@@ -0,0 +1,226 @@
+package keyspace
+
+import (
+	"context"
+	"fmt"
+	"server/internal/db"
+	"server/util/cmds"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Limits caps the part of a value that is rendered
+type Limits struct {
+	MaxElements int // Field for the maximum number of elements of hashes, lists, sets and sorted sets
+	MaxBytes    int // Field for the maximum number of bytes of strings
+}
+
+// DefaultLimits are the limits applied when rendering values by default
+var DefaultLimits = Limits{MaxElements: 100, MaxBytes: 4096}
+
+// Value is a key with its value rendered according to its type: a string, an
+// object for hashes, an array for lists and sets, an array of members with
+// their score for sorted sets and the estimated cardinality for HyperLogLogs.
+// Length is the number of elements, the length in bytes of strings.
+type Value struct {
+	Key
+	Length    int64       `json:"length"`
+	Truncated bool        `json:"truncated,omitempty"` // Set when Value holds only the first part of the value
+	Value     interface{} `json:"value"`
+}
+
+// Member is a member of a sorted set
+type Member struct {
+	Member string  `json:"member"`
+	Score  float64 `json:"score"`
+}
+
+// Get returns the value of the key, capped to the limits. Values of types
+// that cannot be rendered are nil.
+func Get(ctx context.Context, client *db.DiceDB, name string, limits Limits) (*Value, error) {
+	values, err := Capture(ctx, client, []string{name}, limits)
+	if err != nil {
+		return nil, err
+	}
+	if values[0] == nil {
+		return nil, ErrNotFound
+	}
+	return values[0], nil
+}
+
+// Capture returns the values of the keys like Get, nil for keys that do not
+// exist. The keys are read in two to three round trips whatever their number,
+// but not atomically.
+func Capture(ctx context.Context, client *db.DiceDB, names []string, limits Limits) ([]*Value, error) {
+	keys, err := Describe(ctx, client, names...)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]*Value, len(keys))
+	var commands []*cmds.CommandRequest
+	counts := make([]int, len(keys)) // Number of commands reading each value
+	for i, key := range keys {
+		if key.Type == TypeNone {
+			continue
+		}
+		values[i] = &Value{Key: key}
+		valueCommands := readCommands(key, limits)
+		commands = append(commands, valueCommands...)
+		counts[i] = len(valueCommands)
+	}
+
+	replies, err := client.ExecutePipelineRaw(ctx, commands)
+	if err != nil {
+		return nil, err
+	}
+	for i, value := range values {
+		if counts[i] == 0 {
+			continue
+		}
+		if err := value.render(replies[:counts[i]], limits); err != nil {
+			return nil, err
+		}
+		replies = replies[counts[i]:]
+	}
+	return values, nil
+}
+
+// readCommands returns the commands reading the value of the key, none for
+// types that cannot be rendered
+func readCommands(key Key, limits Limits) []*cmds.CommandRequest {
+	name := key.Name
+	maxIndex := "-1"
+	if limits.MaxElements > 0 {
+		maxIndex = strconv.Itoa(limits.MaxElements - 1)
+	}
+	switch key.Type {
+	case TypeString:
+		return []*cmds.CommandRequest{{Cmd: "GET", Args: []string{name}}}
+	case TypeHyperLogLog:
+		return []*cmds.CommandRequest{{Cmd: "PFCOUNT", Args: []string{name}}}
+	case TypeHash:
+		return []*cmds.CommandRequest{{Cmd: "HGETALL", Args: []string{name}}}
+	case TypeList:
+		return []*cmds.CommandRequest{
+			{Cmd: "LLEN", Args: []string{name}},
+			{Cmd: "LRANGE", Args: []string{name, "0", maxIndex}},
+		}
+	case TypeSet:
+		return []*cmds.CommandRequest{{Cmd: "SMEMBERS", Args: []string{name}}}
+	case TypeZSet:
+		return []*cmds.CommandRequest{
+			{Cmd: "ZCARD", Args: []string{name}},
+			{Cmd: "ZRANGE", Args: []string{name, "0", maxIndex, "WITHSCORES"}},
+		}
+	}
+	return nil
+}
+
+// render sets the value from the replies of the commands issued by Get
+func (v *Value) render(replies []interface{}, limits Limits) error {
+	switch v.Type {
+	case TypeString:
+		s, err := statusReply(replies[0])
+		if err != nil {
+			return err
+		}
+		v.Length = int64(len(s))
+		v.Value, v.Truncated = truncateString(s, limits.MaxBytes)
+	case TypeHyperLogLog:
+		count, err := integerReply(replies[0])
+		if err != nil {
+			return err
+		}
+		v.Length, v.Value = count, count
+	case TypeHash:
+		pairs, err := stringsReply(replies[0])
+		if err != nil {
+			return err
+		}
+		fields := make([]string, 0, len(pairs)/2)
+		values := make(map[string]string, len(pairs)/2)
+		for i := 0; i+1 < len(pairs); i += 2 {
+			fields = append(fields, pairs[i])
+			values[pairs[i]] = pairs[i+1]
+		}
+		// Fields are kept in name order, so that truncated hashes are stable
+		sort.Strings(fields)
+		v.Length = int64(len(fields))
+		fields, v.Truncated = truncateElements(fields, limits.MaxElements)
+		hash := make(map[string]string, len(fields))
+		for _, field := range fields {
+			hash[field] = values[field]
+		}
+		v.Value = hash
+	case TypeList:
+		length, err := integerReply(replies[0])
+		if err != nil {
+			return err
+		}
+		items, err := stringsReply(replies[1])
+		if err != nil {
+			return err
+		}
+		v.Length, v.Value, v.Truncated = length, nonNil(items), int64(len(items)) < length
+	case TypeSet:
+		members, err := stringsReply(replies[0])
+		if err != nil {
+			return err
+		}
+		sort.Strings(members)
+		v.Length = int64(len(members))
+		members, v.Truncated = truncateElements(members, limits.MaxElements)
+		v.Value = nonNil(members)
+	case TypeZSet:
+		length, err := integerReply(replies[0])
+		if err != nil {
+			return err
+		}
+		pairs, err := stringsReply(replies[1])
+		if err != nil {
+			return err
+		}
+		members := make([]Member, 0, len(pairs)/2)
+		for i := 0; i+1 < len(pairs); i += 2 {
+			score, err := strconv.ParseFloat(pairs[i+1], 64)
+			if err != nil {
+				return fmt.Errorf("invalid score %q of member %q", pairs[i+1], pairs[i])
+			}
+			members = append(members, Member{Member: pairs[i], Score: score})
+		}
+		v.Length, v.Value, v.Truncated = length, members, int64(len(members)) < length
+	}
+	return nil
+}
+
+// truncateString returns at most maxBytes of s without splitting a character,
+// and whether s was truncated. maxBytes of 0 or less keeps s whole.
+func truncateString(s string, maxBytes int) (string, bool) {
+	if maxBytes <= 0 || len(s) <= maxBytes {
+		return s, false
+	}
+	cut := maxBytes
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut], true
+}
+
+// truncateElements returns at most maxElements elements, and whether elements
+// were dropped. maxElements of 0 or less keeps every element.
+func truncateElements(elements []string, maxElements int) ([]string, bool) {
+	if maxElements <= 0 || len(elements) <= maxElements {
+		return elements, false
+	}
+	return elements[:maxElements], true
+}
+
+// nonNil returns an empty slice for nil, so that it is rendered as an array
+func nonNil(items []string) []string {
+	if items == nil {
+		return []string{}
+	}
+	return items
+}
@@ -0,0 +1,177 @@
+// Package logging configures the process wide slog logger and carries the
+// request-scoped logger through contexts. Every logger is tagged with the
+// component it belongs to so that levels can be overridden per component.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"server/config"
+	"strings"
+	"sync/atomic"
+)
+
+// Components used to tag loggers, LOG_COMPONENT_LEVELS overrides are keyed by these names
+const (
+	ComponentHTTP        = "http"
+	ComponentRateLimiter = "ratelimiter"
+	ComponentIdempotency = "idempotency"
+	ComponentDB          = "db"
+	ComponentCleanup     = "cleanup"
+	ComponentServer      = "server"
+	ComponentAudit       = "audit"
+	ComponentAnalytics   = "analytics"
+	ComponentSession     = "session"
+
+	componentKey = "component"
+	redacted     = "[REDACTED]"
+)
+
+type contextKey struct{}
+
+// redactArgs is read on every logged command, it defaults to redacting
+var redactArgs atomic.Bool
+
+func init() {
+	redactArgs.Store(true)
+}
+
+// Init replaces the default slog logger according to the logging configuration
+func Init(loggingConfig *config.LoggingConfig, w io.Writer) error {
+	logger, err := NewLogger(loggingConfig, w)
+	if err != nil {
+		return err
+	}
+
+	slog.SetDefault(logger)
+	redactArgs.Store(loggingConfig.RedactArgs)
+	return nil
+}
+
+// NewLogger builds a logger writing to w in the configured format, filtering
+// records by the level of the component they are logged for
+func NewLogger(loggingConfig *config.LoggingConfig, w io.Writer) (*slog.Logger, error) {
+	level, err := parseLevel(loggingConfig.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	// The inner handler accepts everything the most verbose component may log,
+	// filtering happens in the component handler
+	minLevel := level
+	overrides := make(map[string]slog.Level, len(loggingConfig.ComponentLevels))
+	for component, value := range loggingConfig.ComponentLevels {
+		componentLevel, err := parseLevel(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid level for component %q: %w", component, err)
+		}
+		overrides[component] = componentLevel
+		minLevel = min(minLevel, componentLevel)
+	}
+
+	options := &slog.HandlerOptions{Level: minLevel}
+	var handler slog.Handler
+	switch strings.ToLower(loggingConfig.Format) {
+	case "json", "":
+		handler = slog.NewJSONHandler(w, options)
+	case "text":
+		handler = slog.NewTextHandler(w, options)
+	default:
+		return nil, fmt.Errorf("invalid log format %q, expected json or text", loggingConfig.Format)
+	}
+
+	return slog.New(&componentHandler{
+		handler:   handler,
+		level:     level,
+		overrides: overrides,
+	}), nil
+}
+
+// NewContext returns a copy of ctx carrying the request-scoped logger
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the request-scoped logger carried by ctx, or the default
+// logger outside of requests, tagged with the given component
+func FromContext(ctx context.Context, component string) *slog.Logger {
+	logger, ok := ctx.Value(contextKey{}).(*slog.Logger)
+	if !ok {
+		logger = slog.Default()
+	}
+	if component == "" {
+		return logger
+	}
+	return logger.With(slog.String(componentKey, component))
+}
+
+// Component returns the default logger tagged with the given component, for
+// code running outside of requests
+func Component(component string) *slog.Logger {
+	return FromContext(context.Background(), component)
+}
+
+// Args returns the command arguments as a log attribute. Values are replaced by
+// a placeholder unless redaction was disabled, only their number is kept.
+func Args(args []string) slog.Attr {
+	if !redactArgs.Load() {
+		return slog.Any("args", args)
+	}
+
+	masked := make([]string, len(args))
+	for i := range masked {
+		masked[i] = redacted
+	}
+	return slog.Any("args", masked)
+}
+
+func parseLevel(value string) (slog.Level, error) {
+	var level slog.Level
+	if value == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		return level, fmt.Errorf("invalid log level %q: %w", value, err)
+	}
+	return level, nil
+}
+
+// componentHandler filters records by the level configured for the component
+// of the logger, as set through logger.With("component", name)
+type componentHandler struct {
+	handler   slog.Handler
+	level     slog.Level
+	overrides map[string]slog.Level
+	component string
+}
+
+func (h *componentHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	minLevel, ok := h.overrides[h.component]
+	if !ok {
+		minLevel = h.level
+	}
+	return level >= minLevel
+}
+
+func (h *componentHandler) Handle(ctx context.Context, record slog.Record) error {
+	return h.handler.Handle(ctx, record)
+}
+
+func (h *componentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.handler = h.handler.WithAttrs(attrs)
+	for _, attr := range attrs {
+		if attr.Key == componentKey {
+			clone.component = attr.Value.String()
+		}
+	}
+	return &clone
+}
+
+func (h *componentHandler) WithGroup(name string) slog.Handler {
+	clone := *h
+	clone.handler = h.handler.WithGroup(name)
+	return &clone
+}
@@ -0,0 +1,228 @@
+// Package metrics holds the Prometheus metrics exposed on /metrics. All metrics
+// are registered on a dedicated registry, label values derived from user input
+// are bounded so that a client can not blow up the number of series.
+package metrics
+
+import (
+	"net/http"
+	"server/util/cmds"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace = "playground"
+
+	// OtherCommand is the label value used for command names not known to DiceDB
+	OtherCommand = "OTHER"
+	// UnmatchedRoute is the label value used for requests that did not match any route
+	UnmatchedRoute = "unmatched"
+)
+
+// Results of recording an audit event
+const (
+	AuditQueued     = "queued"
+	AuditDropped    = "dropped"
+	AuditSampledOut = "sampled_out"
+)
+
+// Reasons for closing a pinned connection
+const (
+	PinnedConnEnded    = "ended"
+	PinnedConnIdle     = "idle"
+	PinnedConnBroken   = "broken"
+	PinnedConnShutdown = "shutdown"
+)
+
+// Outcomes of a rate limiter decision
+const (
+	RateLimitAllowed  = "allowed"
+	RateLimitRejected = "rejected"
+	RateLimitError    = "error"
+)
+
+var registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	commandDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "dicedb_command_duration_seconds",
+		Help:      "DiceDB command latency by client and command, including retries.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"client", "command"})
+
+	commandErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dicedb_command_errors_total",
+		Help:      "Failed DiceDB commands by client and command. Nil replies are not errors.",
+	}, []string{"client", "command"})
+
+	rateLimiterDecisions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limiter_requests_total",
+		Help:      "Rate limiter decisions by outcome (allowed, rejected, error).",
+	}, []string{"outcome"})
+
+	blockedCommands = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "blocked_commands_total",
+		Help:      "Commands rejected because they are blocklisted.",
+	}, []string{"command"})
+
+	cleanupDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "cleanup_run_duration_seconds",
+		Help:      "Duration of the cleanup cron runs.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	cleanupFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cleanup_failures_total",
+		Help:      "Failed cleanup cron runs.",
+	})
+
+	auditEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "audit_events_total",
+		Help:      "Audit events by result (queued, dropped because the queue was full, sampled_out).",
+	}, []string{"result"})
+
+	auditSinkErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "audit_sink_errors_total",
+		Help:      "Failed writes of audit event batches by sink.",
+	}, []string{"sink"})
+
+	pinnedConns = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "pinned_connections",
+		Help:      "DiceDB connections currently pinned to a session.",
+	})
+
+	pinnedConnsClosed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "pinned_connections_closed_total",
+		Help:      "Pinned connections closed by reason (ended, idle, broken, shutdown).",
+	}, []string{"reason"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpRequestDuration,
+		commandDuration,
+		commandErrors,
+		rateLimiterDecisions,
+		blockedCommands,
+		cleanupDuration,
+		cleanupFailures,
+		auditEvents,
+		auditSinkErrors,
+		pinnedConns,
+		pinnedConnsClosed,
+	)
+}
+
+// SetPinnedConnections records the number of connections pinned to sessions
+func SetPinnedConnections(n int) {
+	pinnedConns.Set(float64(n))
+}
+
+// ObservePinnedConnectionClosed records a pinned connection closed for the reason
+func ObservePinnedConnectionClosed(reason string) {
+	pinnedConnsClosed.WithLabelValues(reason).Inc()
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})
+}
+
+// Register adds a collector to the registry served by Handler. Registering a
+// collector that is already registered is not an error.
+func Register(collector prometheus.Collector) error {
+	if err := registry.Register(collector); err != nil {
+		if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
+			return err
+		}
+	}
+	return nil
+}
+
+// CommandLabel maps a command name to its label value, collapsing unknown
+// commands into OtherCommand
+func CommandLabel(cmd string) string {
+	cmd = strings.ToUpper(cmd)
+	if !cmds.IsKnown(cmd) {
+		return OtherCommand
+	}
+	return cmd
+}
+
+// ObserveHTTPRequest records a served HTTP request. route must be the route
+// template (e.g. /shell/exec/:cmd), never the raw path.
+func ObserveHTTPRequest(route, method string, status int, duration time.Duration) {
+	if route == "" {
+		route = UnmatchedRoute
+	}
+	httpRequests.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+	httpRequestDuration.WithLabelValues(route, method).Observe(duration.Seconds())
+}
+
+// ObserveCommand records a DiceDB command sent by the admin or user client
+func ObserveCommand(client, cmd string, duration time.Duration, failed bool) {
+	label := CommandLabel(cmd)
+	commandDuration.WithLabelValues(client, label).Observe(duration.Seconds())
+	if failed {
+		commandErrors.WithLabelValues(client, label).Inc()
+	}
+}
+
+// ObserveRateLimit records a rate limiter decision
+func ObserveRateLimit(outcome string) {
+	rateLimiterDecisions.WithLabelValues(outcome).Inc()
+}
+
+// ObserveBlockedCommand records a command rejected by the blocklist
+func ObserveBlockedCommand(cmd string) {
+	blockedCommands.WithLabelValues(CommandLabel(cmd)).Inc()
+}
+
+// ObserveCleanupRun records a run of the cleanup cron
+func ObserveCleanupRun(duration time.Duration, err error) {
+	cleanupDuration.Observe(duration.Seconds())
+	if err != nil {
+		cleanupFailures.Inc()
+	}
+}
+
+// ObserveAuditEvent records what happened to an audit event when it was recorded
+func ObserveAuditEvent(result string) {
+	auditEvents.WithLabelValues(result).Inc()
+}
+
+// ObserveAuditSinkError records a failed write to an audit sink
+func ObserveAuditSinkError(sink string) {
+	auditSinkErrors.WithLabelValues(sink).Inc()
+}
@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"github.com/dicedb/dicedb-go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PoolStatser is implemented by DiceDB clients exposing connection pool statistics
+type PoolStatser interface {
+	PoolStats() *dicedb.PoolStats
+}
+
+// poolCollector exports the connection pool statistics of a DiceDB client at
+// scrape time
+type poolCollector struct {
+	pool PoolStatser
+
+	hits       *prometheus.Desc
+	misses     *prometheus.Desc
+	timeouts   *prometheus.Desc
+	totalConns *prometheus.Desc
+	idleConns  *prometheus.Desc
+	staleConns *prometheus.Desc
+}
+
+// RegisterPool exports the connection pool statistics of a DiceDB client,
+// client is the role of the client ("admin" or "user")
+func RegisterPool(client string, pool PoolStatser) error {
+	labels := prometheus.Labels{"client": client}
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "dicedb_pool", name), help, nil, labels)
+	}
+
+	return Register(&poolCollector{
+		pool:       pool,
+		hits:       desc("hits_total", "Times a free connection was found in the pool."),
+		misses:     desc("misses_total", "Times a free connection was not found in the pool."),
+		timeouts:   desc("timeouts_total", "Times waiting for a connection timed out."),
+		totalConns: desc("connections", "Connections currently in the pool."),
+		idleConns:  desc("idle_connections", "Idle connections currently in the pool."),
+		staleConns: desc("stale_connections_total", "Stale connections removed from the pool."),
+	})
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.timeouts
+	ch <- c.totalConns
+	ch <- c.idleConns
+	ch <- c.staleConns
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.pool.PoolStats()
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(c.timeouts, prometheus.CounterValue, float64(stats.Timeouts))
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(stats.TotalConns))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(stats.IdleConns))
+	ch <- prometheus.MustNewConstMetric(c.staleConns, prometheus.CounterValue, float64(stats.StaleConns))
+}
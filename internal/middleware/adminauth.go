@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"server/internal/apierror"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NewAdminAuthMiddleware protects the admin endpoints with a bearer token.
+// When no token is configured the endpoints are disabled and respond with 404.
+func NewAdminAuthMiddleware(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			apierror.Abort(c, apierror.New(apierror.CodeNotFound, "not found"))
+			return
+		}
+
+		provided, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			c.Header("WWW-Authenticate", `Bearer realm="admin"`)
+			apierror.Abort(c, apierror.New(apierror.CodeUnauthorized, "unauthorized"))
+			return
+		}
+
+		c.Next()
+	}
+}
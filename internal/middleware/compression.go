@@ -0,0 +1,296 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"server/internal/logging"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	encodingGzip   = "gzip"
+	encodingBrotli = "br"
+)
+
+type (
+	// CompressionMiddleware compresses JSON and text responses of at least
+	// minSize bytes with brotli or gzip, whichever the client prefers. Streams
+	// (server-sent events and WebSocket upgrades) and excluded paths are sent
+	// as is.
+	CompressionMiddleware struct {
+		minSize       int
+		excludedPaths []string
+		gzipPool      sync.Pool
+		brotliPool    sync.Pool
+	}
+
+	// resettableWriter is implemented by the gzip and brotli writers, which are
+	// pooled as allocating them is expensive
+	resettableWriter interface {
+		io.WriteCloser
+		Flush() error
+		Reset(w io.Writer)
+	}
+
+	// compressWriter buffers the response until minSize bytes are written, then
+	// either compresses it or passes it through
+	compressWriter struct {
+		gin.ResponseWriter
+		cm         *CompressionMiddleware
+		encoding   string
+		buf        []byte
+		decided    bool
+		compressor resettableWriter
+	}
+)
+
+// NewCompressionMiddleware creates a compression middleware. Levels outside
+// the range of the algorithm fall back to its default level.
+func NewCompressionMiddleware(minSize, gzipLevel, brotliLevel int, excludedPaths []string) *CompressionMiddleware {
+	if gzipLevel < gzip.HuffmanOnly || gzipLevel > gzip.BestCompression {
+		gzipLevel = gzip.DefaultCompression
+	}
+	if brotliLevel < brotli.BestSpeed || brotliLevel > brotli.BestCompression {
+		brotliLevel = brotli.DefaultCompression
+	}
+
+	cm := &CompressionMiddleware{minSize: minSize}
+	for _, path := range excludedPaths {
+		if path != "" {
+			cm.excludedPaths = append(cm.excludedPaths, path)
+		}
+	}
+	cm.gzipPool.New = func() interface{} {
+		// The level is validated above, NewWriterLevel cannot fail
+		w, _ := gzip.NewWriterLevel(io.Discard, gzipLevel)
+		return w
+	}
+	cm.brotliPool.New = func() interface{} {
+		return brotli.NewWriterLevel(io.Discard, brotliLevel)
+	}
+	return cm
+}
+
+// Exec wraps the response writer of requests accepting a supported encoding
+func (cm *CompressionMiddleware) Exec(c *gin.Context) {
+	if cm.excluded(c.Request) {
+		c.Next()
+		return
+	}
+
+	// Responses vary by Accept-Encoding even when they end up uncompressed,
+	// caches must not serve them to clients accepting another encoding
+	c.Writer.Header().Add("Vary", "Accept-Encoding")
+	encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"))
+	if encoding == "" {
+		c.Next()
+		return
+	}
+
+	w := &compressWriter{ResponseWriter: c.Writer, cm: cm, encoding: encoding}
+	c.Writer = w
+	defer func() {
+		if err := w.close(); err != nil {
+			logging.FromContext(c.Request.Context(), logging.ComponentHTTP).Warn("Failed to finish compressed response",
+				slog.Any("err", err))
+		}
+		c.Writer = w.ResponseWriter
+	}()
+	c.Next()
+}
+
+func (cm *CompressionMiddleware) excluded(r *http.Request) bool {
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") ||
+		strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		return true
+	}
+	for _, path := range cm.excludedPaths {
+		if strings.HasPrefix(r.URL.Path, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateEncoding picks brotli or gzip from an Accept-Encoding header,
+// preferring brotli when both are equally acceptable. It returns an empty
+// string if neither is accepted.
+func negotiateEncoding(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+
+		var candidates []string
+		switch name {
+		case encodingBrotli, encodingGzip:
+			candidates = []string{name}
+		case "*":
+			candidates = []string{encodingBrotli, encodingGzip}
+		}
+		for _, candidate := range candidates {
+			if q > bestQ || (q == bestQ && q > 0 && candidate == encodingBrotli) {
+				best, bestQ = candidate, q
+			}
+		}
+	}
+	if bestQ <= 0 {
+		return ""
+	}
+	return best
+}
+
+// compressible reports whether a response of the content type benefits from
+// compression: JSON, NDJSON and text other than event streams
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case mediaType == "text/event-stream":
+		return false
+	case mediaType == "application/json", mediaType == "application/x-ndjson", strings.HasSuffix(mediaType, "+json"):
+		return true
+	default:
+		return strings.HasPrefix(mediaType, "text/")
+	}
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if w.decided {
+		if w.compressor != nil {
+			return w.compressor.Write(data)
+		}
+		return w.ResponseWriter.Write(data)
+	}
+
+	w.buf = append(w.buf, data...)
+	if len(w.buf) >= w.cm.minSize {
+		if err := w.decide(); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// WriteHeaderNow defers sending the headers until it is known whether the
+// response is compressed
+func (w *compressWriter) WriteHeaderNow() {
+	if w.decided {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+// Written reports whether the response was started, including responses that
+// are still buffered
+func (w *compressWriter) Written() bool {
+	return len(w.buf) > 0 || w.ResponseWriter.Written()
+}
+
+// Flush sends the buffered response, streaming responses are only compressed
+// when minSize bytes were written before the first flush
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if err := w.decide(); err != nil {
+			return
+		}
+	}
+	if w.compressor != nil {
+		if err := w.compressor.Flush(); err != nil {
+			return
+		}
+	}
+	w.ResponseWriter.Flush()
+}
+
+// decide compresses the response if it is large enough, of a compressible
+// type and not encoded already, then writes the buffered data
+func (w *compressWriter) decide() error {
+	w.decided = true
+	header := w.Header()
+	if header.Get("Content-Type") == "" && len(w.buf) > 0 {
+		header.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+
+	if len(w.buf) >= w.cm.minSize && header.Get("Content-Encoding") == "" && compressible(header.Get("Content-Type")) &&
+		bodyAllowed(w.Status()) {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		w.compressor = w.cm.acquire(w.encoding, w.ResponseWriter)
+	}
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+// close writes a response smaller than minSize as is, or finishes the
+// compressed stream
+func (w *compressWriter) close() error {
+	if !w.decided {
+		if len(w.buf) == 0 {
+			return nil
+		}
+		if err := w.decide(); err != nil {
+			return err
+		}
+	}
+	if w.compressor == nil {
+		return nil
+	}
+
+	err := w.compressor.Close()
+	w.cm.release(w.encoding, w.compressor)
+	w.compressor = nil
+	return err
+}
+
+func (cm *CompressionMiddleware) acquire(encoding string, dst io.Writer) resettableWriter {
+	var w resettableWriter
+	if encoding == encodingBrotli {
+		w = cm.brotliPool.Get().(*brotli.Writer)
+	} else {
+		w = cm.gzipPool.Get().(*gzip.Writer)
+	}
+	w.Reset(dst)
+	return w
+}
+
+func (cm *CompressionMiddleware) release(encoding string, w resettableWriter) {
+	w.Reset(io.Discard)
+	if encoding == encodingBrotli {
+		cm.brotliPool.Put(w)
+	} else {
+		cm.gzipPool.Put(w)
+	}
+}
+
+// bodyAllowed reports whether a response with the status may have a body
+func bodyAllowed(status int) bool {
+	return status >= http.StatusOK && status != http.StatusNoContent && status != http.StatusNotModified
+}
@@ -0,0 +1,230 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"server/internal/apierror"
+	"server/internal/db"
+	"server/internal/logging"
+	"server/internal/server/utils"
+	"server/util/cmds"
+	"strings"
+	"time"
+
+	"github.com/dicedb/dicedb-go"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+	idempotencyStoreTimeout  = 5 * time.Second
+)
+
+type (
+	// IdempotencyMiddleware lets clients safely retry a command by sending the same
+	// Idempotency-Key header. The first response for a key is stored in the admin
+	// DiceDB for ttl and replayed for every retry of the same request, so the
+	// command is executed only once.
+	IdempotencyMiddleware struct {
+		client *db.DiceDB
+		ttl    time.Duration
+	}
+
+	// idempotencyRecord is the value stored for each Idempotency-Key.
+	// OutcomeUnknown is set when the command may have been applied but no
+	// definitive response was sent, such as a lost reply or a timeout.
+	idempotencyRecord struct {
+		Fingerprint    string `json:"fingerprint"`
+		InProgress     bool   `json:"in_progress,omitempty"`
+		OutcomeUnknown bool   `json:"outcome_unknown,omitempty"`
+		Status         int    `json:"status,omitempty"`
+		ContentType    string `json:"content_type,omitempty"`
+		Body           string `json:"body,omitempty"`
+	}
+
+	// recordingWriter tees the response body so that it can be stored once the
+	// request completes
+	recordingWriter struct {
+		gin.ResponseWriter
+		body bytes.Buffer
+	}
+)
+
+func NewIdempotencyMiddleware(client *db.DiceDB, ttl time.Duration) *IdempotencyMiddleware {
+	return &IdempotencyMiddleware{
+		client: client,
+		ttl:    ttl,
+	}
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Exec replays stored responses for known Idempotency-Keys and records new ones
+func (im *IdempotencyMiddleware) Exec(c *gin.Context) {
+	idempotencyKey := c.GetHeader(IdempotencyKeyHeader)
+	if idempotencyKey == "" || !strings.Contains(c.Request.URL.Path, "/shell/exec/") {
+		c.Next()
+		return
+	}
+
+	if len(idempotencyKey) > maxIdempotencyKeyLength {
+		apierror.Abort(c, apierror.New(apierror.CodeInvalidRequest, "Idempotency-Key must not exceed 255 characters"))
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, cmds.MaxTransactionBodyBytes))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		apierror.Abort(c, apierror.New(apierror.CodeInvalidRequest, "the request body exceeds the size limit"))
+		return
+	}
+	if err != nil {
+		apierror.Abort(c, apierror.New(apierror.CodeInvalidRequest, "failed to read the request body"))
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), idempotencyStoreTimeout)
+	defer cancel()
+	logger := logging.FromContext(ctx, logging.ComponentIdempotency)
+
+	key := utils.IdempotencyKeyPrefix + hash(idempotencyKey)
+	// The query and Accept header select the encoding of the response, a retry
+	// asking for another one is a different request
+	fingerprint := hash(c.Request.Method + " " + c.Request.URL.Path + "?" + c.Request.URL.RawQuery + "\n" +
+		c.GetHeader("Accept") + "\n" + string(body))
+
+	// Claim the key so that concurrent retries do not execute the command twice
+	claim, err := json.Marshal(idempotencyRecord{Fingerprint: fingerprint, InProgress: true})
+	if err != nil {
+		logger.Error("Error marshaling idempotency record", slog.Any("err", err))
+		apierror.Abort(c, apierror.Internal())
+		return
+	}
+
+	claimed, err := im.client.Client.SetNX(ctx, key, claim, im.ttl).Result()
+	if err != nil {
+		logger.Error("Error claiming idempotency key", slog.Any("err", err))
+		apierror.Abort(c, apierror.Internal())
+		return
+	}
+
+	if !claimed {
+		im.replay(ctx, c, key, fingerprint)
+		return
+	}
+
+	recorder := &recordingWriter{ResponseWriter: c.Writer}
+	c.Writer = recorder
+	c.Next()
+
+	// The request context may be done by now, because the client went away or
+	// the command outlived the claim timeout. The claim is released or the
+	// response stored regardless, otherwise retries are rejected until it expires.
+	storeCtx, cancelStore := context.WithTimeout(context.WithoutCancel(c.Request.Context()), idempotencyStoreTimeout)
+	defer cancelStore()
+
+	// Rate limited requests never reached DiceDB, the client may retry them
+	// with the same key
+	status := recorder.Status()
+	if recorder.Written() && status == http.StatusTooManyRequests {
+		if err := im.client.Client.Del(storeCtx, key).Err(); err != nil {
+			logger.Error("Error releasing idempotency key", slog.Any("err", err))
+		}
+		return
+	}
+
+	// Server errors and requests that got no response (e.g. a lost reply or a
+	// timeout) may have applied the command, retrying it could apply it twice
+	record := idempotencyRecord{Fingerprint: fingerprint, OutcomeUnknown: true}
+	if recorder.Written() && status < http.StatusInternalServerError {
+		record = idempotencyRecord{
+			Fingerprint: fingerprint,
+			Status:      status,
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.String(),
+		}
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		logger.Error("Error marshaling idempotency record", slog.Any("err", err))
+		return
+	}
+
+	if err := im.client.Client.SetXX(storeCtx, key, data, im.ttl).Err(); err != nil {
+		logger.Error("Error storing idempotent response", slog.Any("err", err))
+	}
+}
+
+// replay writes the stored response of a request that was already executed
+func (im *IdempotencyMiddleware) replay(ctx context.Context, c *gin.Context, key, fingerprint string) {
+	defer c.Abort()
+	logger := logging.FromContext(ctx, logging.ComponentIdempotency)
+
+	raw, err := im.client.Client.Get(ctx, key).Result()
+	if errors.Is(err, dicedb.Nil) {
+		// The key expired between the claim and now, ask the client to retry
+		apierror.Write(c.Writer, apierror.New(apierror.CodeConflict, "Idempotency-Key expired, please retry"))
+		return
+	}
+	if err != nil {
+		logger.Error("Error fetching idempotent response", slog.Any("err", err))
+		apierror.Write(c.Writer, apierror.Internal())
+		return
+	}
+
+	var record idempotencyRecord
+	if err := json.Unmarshal([]byte(raw), &record); err != nil {
+		logger.Error("Error parsing idempotency record", slog.Any("err", err))
+		apierror.Write(c.Writer, apierror.Internal())
+		return
+	}
+
+	if record.Fingerprint != fingerprint {
+		apierror.Write(c.Writer, apierror.New(apierror.CodeKeyReused, "Idempotency-Key was already used for a different request"))
+		return
+	}
+
+	if record.InProgress {
+		apierror.Write(c.Writer, apierror.New(apierror.CodeConflict, "A request with this Idempotency-Key is still in progress"))
+		return
+	}
+
+	if record.OutcomeUnknown {
+		apierror.Write(c.Writer, apierror.New(apierror.CodeConflict,
+			"The request with this Idempotency-Key failed without a definitive outcome, the command may have been applied").
+			WithHint("Check the affected keys, then send the command again with a new Idempotency-Key if needed."))
+		return
+	}
+
+	if record.ContentType != "" {
+		c.Writer.Header().Set("Content-Type", record.ContentType)
+	}
+	c.Writer.Header().Set(IdempotentReplayedHeader, "true")
+	c.Writer.WriteHeader(record.Status)
+	if _, err := c.Writer.WriteString(record.Body); err != nil {
+		logger.Error("Failed to write replayed response", slog.Any("err", err))
+	}
+}
+
+func hash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
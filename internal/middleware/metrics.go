@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"server/internal/metrics"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MetricsMiddleware records the latency and status of every request. It must be
+// registered first so that responses written by other middlewares are counted.
+func MetricsMiddleware(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+	metrics.ObserveHTTPRequest(c.FullPath(), c.Request.Method, c.Writer.Status(), time.Since(start))
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"log/slog"
+	"server/internal/logging"
+	"server/internal/presence"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PresenceMiddleware struct {
+	tracker *presence.Tracker
+}
+
+func NewPresenceMiddleware(tracker *presence.Tracker) *PresenceMiddleware {
+	return &PresenceMiddleware{tracker: tracker}
+}
+
+// Exec records the client of every command request as active. Failing to
+// record presence never fails the request.
+func (p *PresenceMiddleware) Exec(c *gin.Context) {
+	if !strings.Contains(c.Request.URL.Path, "/shell/") {
+		c.Next()
+		return
+	}
+
+	if err := p.tracker.Touch(c.Request.Context(), presence.ClientID(c.Request), time.Now()); err != nil {
+		logging.FromContext(c.Request.Context(), logging.ComponentHTTP).Warn("Failed to record presence", slog.Any("err", err))
+	}
+	c.Next()
+}
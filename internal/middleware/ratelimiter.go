@@ -1,15 +1,24 @@
 package middleware
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"server/config"
+	"server/internal/apierror"
 	"server/internal/db"
+	"server/internal/logging"
+	"server/internal/metrics"
 	"server/internal/server/utils"
 	mock "server/internal/tests/dbmocks"
+	"server/internal/timing"
+	"server/internal/tracing"
+	"server/util/cmds"
 	"strconv"
 	"strings"
 	"time"
@@ -39,25 +48,30 @@ func NewRateLimiterMiddleware(client *db.DiceDB, limit int64, window float64) (r
 
 // RateLimiter middleware to limit requests based on a specified limit and duration
 func (rl *RateLimiterMiddleware) Exec(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
 
-	// Only apply rate limiting for specific paths (e.g., "/cli/")
-	if !strings.Contains(c.Request.URL.Path, "/shell/exec/") {
+	// Only apply rate limiting to the paths running commands
+	if !rateLimited(c.Request.URL.Path) {
 		c.Next()
 		return
 	}
 
+	start := time.Now()
+	cost := requestCost(c.Request)
+	logger := logging.FromContext(ctx, logging.ComponentRateLimiter)
+
 	// Generate the rate limiting key based on the current window
 	currentWindow := time.Now().Unix() / int64(rl.window)
 	key := fmt.Sprintf("request_count:%d", currentWindow)
-	slog.Debug("Created rate limiter key", slog.Any("key", key))
+	logger.Debug("Created rate limiter key", slog.Any("key", key))
 
 	// Get the current request count for this window
 	val, err := rl.client.Client.Get(ctx, key).Result()
 	if err != nil && !errors.Is(err, dicedb.Nil) {
-		slog.Error("Error fetching request count", "error", err)
-		http.Error(c.Writer, "Internal Server Error", http.StatusInternalServerError)
+		logger.Error("Error fetching request count", "error", err)
+		observeRateLimit(c, start, metrics.RateLimitError)
+		apierror.Abort(c, apierror.Internal())
 		return
 	}
 
@@ -66,47 +80,129 @@ func (rl *RateLimiterMiddleware) Exec(c *gin.Context) {
 	if val != "" {
 		requestCount, err = strconv.ParseInt(val, 10, 64)
 		if err != nil {
-			slog.Error("Error converting request count", "error", err)
-			http.Error(c.Writer, "Internal Server Error", http.StatusInternalServerError)
+			logger.Error("Error converting request count", "error", err)
+			observeRateLimit(c, start, metrics.RateLimitError)
+			apierror.Abort(c, apierror.Internal())
 			return
 		}
 	}
 
 	// Check if the request count exceeds the limit
-	if requestCount >= rl.limit {
-		slog.Warn("Request limit exceeded", "count", requestCount)
+	if requestCount+cost > rl.limit {
+		logger.Warn("Request limit exceeded", "count", requestCount)
+		observeRateLimit(c, start, metrics.RateLimitRejected)
 		addRateLimitHeaders(c.Writer, rl.limit, rl.limit-(requestCount+1), requestCount+1, currentWindow+int64(rl.window), 0)
-		http.Error(c.Writer, "429 - Too Many Requests", http.StatusTooManyRequests)
+		apierror.Abort(c, rateLimitedError(rl.limit, rl.window))
 		return
 	}
 
-	// Increment the request count
-	if requestCount, err = rl.client.Client.Incr(ctx, key).Result(); err != nil {
-		slog.Error("Error incrementing request count", "error", err)
-		http.Error(c.Writer, "Internal Server Error", http.StatusInternalServerError)
+	// Increment the request count, transactions count once per queued command
+	var incr *dicedb.IntCmd
+	if cost > 1 {
+		incr = rl.client.Client.IncrBy(ctx, key, cost)
+	} else {
+		incr = rl.client.Client.Incr(ctx, key)
+	}
+	if requestCount, err = incr.Result(); err != nil {
+		logger.Error("Error incrementing request count", "error", err)
+		observeRateLimit(c, start, metrics.RateLimitError)
+		apierror.Abort(c, apierror.Internal())
 		return
 	}
 
 	// Set the key expiry if it's newly created
-	if requestCount == 1 {
+	if requestCount == cost {
 		if err := rl.client.Client.Expire(ctx, key, time.Duration(rl.window)*time.Second).Err(); err != nil {
-			slog.Error("Error setting expiry for request count", "error", err)
+			logger.Error("Error setting expiry for request count", "error", err)
 		}
 	}
 
 	secondsDifference, err := calculateNextCleanupTime(ctx, rl.client, rl.cronFrequencyInterval)
 	if err != nil {
-		slog.Error("Error calculating next cleanup time", "error", err)
+		logger.Error("Error calculating next cleanup time", "error", err)
 	}
 
 	addRateLimitHeaders(c.Writer, rl.limit, rl.limit-(requestCount+1), requestCount+1, currentWindow+int64(rl.window),
 		secondsDifference)
 
-	slog.Info("Request processed", "count", requestCount+1)
+	logger.Debug("Request processed", "count", requestCount+1)
+	observeRateLimit(c, start, metrics.RateLimitAllowed)
 	c.Next()
 }
 
+// rateLimited reports whether requests to the path run commands or hold
+// DiceDB connections and count towards the rate limit: command execution,
+// transactions, the key browser and pinning connections to sessions
+func rateLimited(path string) bool {
+	return strings.Contains(path, "/shell/exec/") || strings.HasSuffix(path, "/shell/tx") ||
+		strings.HasSuffix(path, "/shell/session") || path == "/keys" || strings.HasPrefix(path, "/keys/")
+}
+
+// requestCost returns the number of requests a request counts for: one per
+// queued command for transactions and one otherwise. The body of transactions
+// is restored for the handler, which rejects bodies over the size limit.
+func requestCost(r *http.Request) int64 {
+	if !strings.HasSuffix(r.URL.Path, "/shell/tx") || r.Body == nil {
+		return 1
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, cmds.MaxTransactionBodyBytes+1))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	if err != nil || len(body) > cmds.MaxTransactionBodyBytes {
+		return 1
+	}
+	var tx cmds.TransactionRequest
+	if err := json.Unmarshal(body, &tx); err != nil || len(tx.Commands) == 0 {
+		// Malformed transactions are rejected by the handler
+		return 1
+	}
+	return int64(len(tx.Commands))
+}
+
+// RateLimiterState is a snapshot of the rate limiter for the current window
+type RateLimiterState struct {
+	Limit     int64     `json:"limit"`
+	WindowSec float64   `json:"window_sec"`
+	Key       string    `json:"key"`
+	Count     int64     `json:"count"`
+	Remaining int64     `json:"remaining"`
+	ResetAt   time.Time `json:"reset_at"`
+}
+
+// State reads the request count of the current window
+func (rl *RateLimiterMiddleware) State(ctx context.Context) (*RateLimiterState, error) {
+	currentWindow := time.Now().Unix() / int64(rl.window)
+	key := fmt.Sprintf("request_count:%d", currentWindow)
+
+	val, err := rl.client.Client.Get(ctx, key).Result()
+	if err != nil && !errors.Is(err, dicedb.Nil) {
+		return nil, fmt.Errorf("failed to fetch request count: %w", err)
+	}
+
+	var count int64
+	if val != "" {
+		if count, err = strconv.ParseInt(val, 10, 64); err != nil {
+			return nil, fmt.Errorf("failed to parse request count: %w", err)
+		}
+	}
+
+	return &RateLimiterState{
+		Limit:     rl.limit,
+		WindowSec: rl.window,
+		Key:       key,
+		Count:     count,
+		Remaining: max(rl.limit-count, 0),
+		ResetAt:   time.Unix((currentWindow+1)*int64(rl.window), 0).UTC(),
+	}, nil
+}
+
 func calculateNextCleanupTime(ctx context.Context, client *db.DiceDB, cronFrequencyInterval time.Duration) (int64, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "rate_limiter.next_cleanup_time")
+	defer span.End()
+
 	var lastCronCleanupTime int64
 	resp := client.Client.Get(ctx, utils.LastCronCleanupTimeUnixMs)
 	if resp.Err() != nil && !errors.Is(resp.Err(), dicedb.Nil) {
@@ -147,7 +243,7 @@ func MockRateLimiter(client *mock.DiceDBMock, next http.Handler, limit int64, wi
 		val, err := client.Get(ctx, key)
 		if err != nil {
 			slog.Error("Error fetching request count", "error", err)
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			apierror.Write(w, apierror.Internal())
 			return
 		}
 
@@ -157,7 +253,7 @@ func MockRateLimiter(client *mock.DiceDBMock, next http.Handler, limit int64, wi
 			requestCount, err = strconv.ParseInt(val, 10, 64)
 			if err != nil {
 				slog.Error("Error converting request count", "error", err)
-				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				apierror.Write(w, apierror.Internal())
 				return
 			}
 		}
@@ -166,7 +262,7 @@ func MockRateLimiter(client *mock.DiceDBMock, next http.Handler, limit int64, wi
 		if requestCount >= limit {
 			slog.Warn("Request limit exceeded", "count", requestCount)
 			addRateLimitHeaders(w, limit, limit-(requestCount+1), requestCount+1, currentWindow+int64(window), 0)
-			http.Error(w, "429 - Too Many Requests", http.StatusTooManyRequests)
+			apierror.Write(w, rateLimitedError(limit, window))
 			return
 		}
 
@@ -174,7 +270,7 @@ func MockRateLimiter(client *mock.DiceDBMock, next http.Handler, limit int64, wi
 		requestCount, err = client.Incr(ctx, key)
 		if err != nil {
 			slog.Error("Error incrementing request count", "error", err)
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			apierror.Write(w, apierror.Internal())
 			return
 		}
 
@@ -193,6 +289,24 @@ func MockRateLimiter(client *mock.DiceDBMock, next http.Handler, limit int64, wi
 	})
 }
 
+// rateLimitedError is sent once the limit of the current window is reached,
+// with the Retry-After delay in its details
+func rateLimitedError(limit int64, window float64) *apierror.Error {
+	windowSec := int64(window)
+	retryAfter := windowSec - time.Now().Unix()%windowSec
+	return apierror.New(apierror.CodeRateLimited, fmt.Sprintf("rate limit of %d requests per %d seconds exceeded", limit, windowSec)).
+		WithDetail("limit", limit).
+		WithDetail("retry_after_sec", retryAfter)
+}
+
+// observeRateLimit records the rate limiter decision for the metrics and the
+// request log, and the time it took for the Server-Timing header
+func observeRateLimit(c *gin.Context, start time.Time, outcome string) {
+	metrics.ObserveRateLimit(outcome)
+	timing.FromContext(c.Request.Context()).Add(timing.RateLimit, time.Since(start))
+	c.Set(RateLimitOutcomeKey, outcome)
+}
+
 func addRateLimitHeaders(w http.ResponseWriter, limit, remaining, used, resetTime, secondsLeftForCleanup int64) {
 	w.Header().Set("x-ratelimit-limit", strconv.FormatInt(limit, 10))
 	w.Header().Set("x-ratelimit-remaining", strconv.FormatInt(remaining, 10))
@@ -201,6 +315,6 @@ func addRateLimitHeaders(w http.ResponseWriter, limit, remaining, used, resetTim
 	w.Header().Set("x-next-cleanup-time", strconv.FormatInt(secondsLeftForCleanup, 10))
 
 	// Expose the rate limit headers to the client
-	w.Header().Set("Access-Control-Expose-Headers", "x-ratelimit-limit, x-ratelimit-remaining,"+
+	w.Header().Add("Access-Control-Expose-Headers", "x-ratelimit-limit, x-ratelimit-remaining,"+
 		"x-ratelimit-used, x-ratelimit-reset, x-next-cleanup-time")
 }
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"server/internal/audit"
+	"server/internal/logging"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+	// RateLimitOutcomeKey is the gin context key under which the rate limiter
+	// stores its decision for the request log
+	RateLimitOutcomeKey = "rate_limit_outcome"
+	maxRequestIDLength  = 128
+)
+
+// RequestLoggerMiddleware assigns every request an ID, either the X-Request-ID
+// sent by the client or a generated one, echoes it back and attaches a logger
+// carrying it, along with the client identity used by the audit log, to the
+// request context. Once the request completes it logs a
+// single access log line.
+func RequestLoggerMiddleware(c *gin.Context) {
+	start := time.Now()
+
+	requestID := c.GetHeader(RequestIDHeader)
+	if !validRequestID(requestID) {
+		requestID = newRequestID()
+	}
+	c.Writer.Header().Set(RequestIDHeader, requestID)
+
+	attrs := []any{slog.String("request_id", requestID)}
+	if spanContext := trace.SpanContextFromContext(c.Request.Context()); spanContext.IsValid() {
+		attrs = append(attrs, slog.String("trace_id", spanContext.TraceID().String()))
+	}
+	ctx := logging.NewContext(c.Request.Context(), slog.Default().With(attrs...))
+	ctx = audit.NewContext(ctx, audit.Client{
+		RequestID: requestID,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+	c.Request = c.Request.WithContext(ctx)
+
+	c.Next()
+
+	status := c.Writer.Status()
+	fields := []any{
+		slog.String("method", c.Request.Method),
+		slog.String("path", c.Request.URL.Path),
+		slog.String("route", c.FullPath()),
+		slog.Int("status", status),
+		slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+	}
+	if cmd := c.Param("cmd"); cmd != "" {
+		fields = append(fields, slog.String("command", strings.ToUpper(cmd)))
+	}
+	if outcome, ok := c.Get(RateLimitOutcomeKey); ok {
+		fields = append(fields, slog.Any("rate_limit", outcome))
+	}
+
+	level := slog.LevelInfo
+	switch {
+	case status >= http.StatusInternalServerError:
+		level = slog.LevelError
+	case status >= http.StatusBadRequest:
+		level = slog.LevelWarn
+	}
+	logging.FromContext(ctx, logging.ComponentHTTP).Log(ctx, level, "Request completed", fields...)
+}
+
+// validRequestID accepts client supplied IDs made of printable ASCII only, so
+// that they can not forge log lines or headers
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if r < '!' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"server/internal/timing"
+
+	"github.com/gin-gonic/gin"
+)
+
+const ServerTimingHeader = "Server-Timing"
+
+// ServerTimingMiddleware attaches a timing.Timings to the request context and
+// reports the phases recorded by later middlewares and handlers in the
+// Server-Timing header, which is added right before the response headers are sent.
+func ServerTimingMiddleware(c *gin.Context) {
+	ctx, timings := timing.NewContext(c.Request.Context())
+	c.Request = c.Request.WithContext(ctx)
+	c.Writer = &serverTimingWriter{ResponseWriter: c.Writer, timings: timings}
+	c.Next()
+}
+
+// serverTimingWriter sets the Server-Timing header the first time the response
+// is written to
+type serverTimingWriter struct {
+	gin.ResponseWriter
+	timings *timing.Timings
+}
+
+func (w *serverTimingWriter) setHeader() {
+	if !w.Written() {
+		w.Header().Set(ServerTimingHeader, w.timings.Header())
+	}
+}
+
+func (w *serverTimingWriter) WriteHeaderNow() {
+	w.setHeader()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *serverTimingWriter) Write(data []byte) (int, error) {
+	w.setHeader()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *serverTimingWriter) WriteString(s string) (int, error) {
+	w.setHeader()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *serverTimingWriter) Flush() {
+	w.setHeader()
+	w.ResponseWriter.Flush()
+}
@@ -0,0 +1,89 @@
+// Package msgpack encodes responses and decodes command arguments as
+// MessagePack, a compact binary-safe alternative to JSON for embedded clients.
+package msgpack
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"server/internal/db"
+
+	"github.com/ugorji/go/codec"
+)
+
+// MaxArgs is the maximum number of elements accepted in a command
+const MaxArgs = 1024 * 1024
+
+// ErrInvalidArgs is wrapped by the errors returned for malformed arguments
+var ErrInvalidArgs = errors.New("invalid MessagePack arguments")
+
+// handle uses the current MessagePack spec, so that strings and binary data
+// are told apart
+var handle = &codec.MsgpackHandle{WriteExt: true}
+
+// Encode returns the MessagePack encoding of v. Structs are encoded as maps
+// keyed by their JSON field names.
+func Encode(v interface{}) ([]byte, error) {
+	var buf []byte
+	if err := codec.NewEncoderBytes(&buf, handle).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// Reply converts a reply returned by db.ExecuteCommandRaw to the value
+// encoded in responses. Strings that are not valid UTF-8 are sent as binary
+// data, error replies never reach this point as they are sent as errors.
+func Reply(reply interface{}) interface{} {
+	switch v := reply.(type) {
+	case db.StatusReply:
+		return string(v)
+	case string:
+		if !utf8.ValidString(v) {
+			return []byte(v)
+		}
+		return v
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = Reply(item)
+		}
+		return items
+	default:
+		return v
+	}
+}
+
+// DecodeArgs decodes the arguments of a command sent as a MessagePack array of
+// strings or binary data. Empty input yields no arguments.
+func DecodeArgs(data []byte) ([]string, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var values []interface{}
+	decoder := codec.NewDecoderBytes(data, handle)
+	if err := decoder.Decode(&values); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArgs, err)
+	}
+	if len(values) > MaxArgs {
+		return nil, fmt.Errorf("%w: more than %d arguments", ErrInvalidArgs, MaxArgs)
+	}
+	if decoder.NumBytesRead() != len(data) {
+		return nil, fmt.Errorf("%w: unexpected data after the arguments", ErrInvalidArgs)
+	}
+
+	args := make([]string, 0, len(values))
+	for i, value := range values {
+		switch v := value.(type) {
+		case string:
+			args = append(args, v)
+		case []byte:
+			args = append(args, string(v))
+		default:
+			return nil, fmt.Errorf("%w: argument %d is a %T, not a string", ErrInvalidArgs, i, value)
+		}
+	}
+	return args, nil
+}
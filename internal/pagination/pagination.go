@@ -0,0 +1,181 @@
+// Package pagination splits replies exceeding the response budget into pages.
+// The full reply is cached briefly in the admin DiceDB and the remaining pages
+// are fetched with an opaque cursor.
+package pagination
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"server/internal/db"
+	"server/internal/logging"
+	"server/internal/server/utils"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dicedb/dicedb-go"
+)
+
+const (
+	// maxCachedBytes bounds the size of the replies kept for pagination, larger
+	// replies are truncated without a cursor
+	maxCachedBytes = 8 * 1024 * 1024
+	// storeTimeout bounds the reads and writes of cached replies
+	storeTimeout = 2 * time.Second
+)
+
+var (
+	// ErrInvalidCursor is returned for cursors that were not issued by the server
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrCursorExpired is returned once the cached reply of a cursor expired
+	ErrCursorExpired = errors.New("cursor expired, run the command again")
+)
+
+// Page is a part of a reply. Cursor is set when more of the reply can be
+// fetched, it may be empty for truncated replies that could not be cached.
+type Page struct {
+	Data        string
+	Truncated   bool
+	TotalLength int
+	Cursor      string
+}
+
+// Pager splits replies into pages of at most maxBytes. A nil Pager is valid and
+// never splits replies.
+type Pager struct {
+	client   *db.DiceDB
+	maxBytes int
+	ttl      time.Duration
+}
+
+// New creates a pager caching replies larger than maxBytes for ttl. It returns
+// nil if maxBytes is not positive.
+func New(client *db.DiceDB, maxBytes int, ttl time.Duration) *Pager {
+	if maxBytes <= 0 {
+		return nil
+	}
+	return &Pager{client: client, maxBytes: maxBytes, ttl: ttl}
+}
+
+// Paginate returns the first page of reply. Replies within the budget are
+// returned whole.
+func (p *Pager) Paginate(ctx context.Context, reply string) Page {
+	if p == nil || len(reply) <= p.maxBytes {
+		return Page{Data: reply, TotalLength: len(reply)}
+	}
+
+	id, err := p.store(ctx, reply)
+	if err != nil {
+		logging.FromContext(ctx, logging.ComponentHTTP).Warn("Failed to cache reply for pagination, truncating it",
+			slog.Int("bytes", len(reply)), slog.Any("err", err))
+		id = ""
+	}
+	return p.page(reply[:p.maxBytes+1], id, 0, len(reply))
+}
+
+// Next returns the page a cursor points to
+func (p *Pager) Next(ctx context.Context, cursor string) (Page, error) {
+	if p == nil {
+		return Page{}, ErrInvalidCursor
+	}
+	id, offset, err := decodeCursor(cursor)
+	if err != nil {
+		return Page{}, err
+	}
+
+	// Only the page is read, along with the byte following it to tell whether
+	// the page cuts a character
+	ctx, cancel := context.WithTimeout(ctx, storeTimeout)
+	defer cancel()
+	key := utils.ResponseCursorKeyPrefix + id
+	var length *dicedb.IntCmd
+	var chunk *dicedb.StringCmd
+	_, err = p.client.Client.Pipelined(ctx, func(pipe dicedb.Pipeliner) error {
+		length = pipe.StrLen(ctx, key)
+		chunk = pipe.GetRange(ctx, key, int64(offset), int64(offset+p.maxBytes))
+		return nil
+	})
+	if err != nil {
+		return Page{}, fmt.Errorf("failed to fetch cached reply: %w", err)
+	}
+	total := int(length.Val())
+	if total == 0 {
+		return Page{}, ErrCursorExpired
+	}
+	if offset >= total {
+		return Page{}, ErrInvalidCursor
+	}
+	return p.page(chunk.Val(), id, offset, total), nil
+}
+
+func (p *Pager) store(ctx context.Context, reply string) (string, error) {
+	if len(reply) > maxCachedBytes {
+		return "", fmt.Errorf("reply exceeds %d bytes", maxCachedBytes)
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(buf)
+
+	ctx, cancel := context.WithTimeout(ctx, storeTimeout)
+	defer cancel()
+	if err := p.client.Client.Set(ctx, utils.ResponseCursorKeyPrefix+id, reply, p.ttl).Err(); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// page cuts the page starting at offset out of chunk, the part of a reply of
+// total bytes following offset, up to one byte past the budget. Pages end on a
+// line break when possible so that array items are not split, and never within
+// a character.
+func (p *Pager) page(chunk, id string, offset, total int) Page {
+	if len(chunk) <= p.maxBytes {
+		return Page{Data: chunk, TotalLength: total}
+	}
+
+	end := p.maxBytes
+	if i := strings.LastIndexByte(chunk[:end], '\n'); i >= 0 {
+		end = i + 1
+	}
+	for end > 1 && !utf8.RuneStart(chunk[end]) {
+		end--
+	}
+
+	page := Page{Data: chunk[:end], Truncated: true, TotalLength: total}
+	if id != "" {
+		page.Cursor = encodeCursor(id, offset+end)
+	}
+	return page
+}
+
+func encodeCursor(id string, offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(id + ":" + strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (id string, offset int, err error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", 0, ErrInvalidCursor
+	}
+	id, offsetStr, ok := strings.Cut(string(raw), ":")
+	if !ok || len(id) != 32 {
+		return "", 0, ErrInvalidCursor
+	}
+	if _, err := hex.DecodeString(id); err != nil {
+		return "", 0, ErrInvalidCursor
+	}
+	offset, err = strconv.Atoi(offsetStr)
+	if err != nil || offset <= 0 {
+		return "", 0, ErrInvalidCursor
+	}
+	return id, offset, nil
+}
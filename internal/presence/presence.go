@@ -0,0 +1,172 @@
+// Package presence counts the clients currently using the playground. Every
+// client seen during a minute is added to a per-minute HyperLogLog in the admin
+// DiceDB, the active count is the union of the last minutes.
+package presence
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"server/internal/analytics"
+	"server/internal/audit"
+	"server/internal/db"
+	"server/internal/server/utils"
+	"sync"
+	"time"
+
+	"github.com/dicedb/dicedb-go"
+)
+
+const (
+	minuteLayout = "200601021504"
+
+	// maxSeenPerMinute bounds the clients remembered as already recorded in the
+	// current minute, clients beyond it are written on every request
+	maxSeenPerMinute = 100000
+)
+
+// Snapshot is the number of active clients and the per-minute history
+type Snapshot struct {
+	Active        int64      `json:"active"`
+	WindowSeconds int64      `json:"window_seconds"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	History       []Interval `json:"history"`
+}
+
+// Interval is the number of distinct clients seen during a minute
+type Interval struct {
+	Time   time.Time `json:"time"`
+	Active int64     `json:"active"`
+}
+
+// Tracker records client heartbeats and counts active clients. Recording a
+// client costs a single pipelined write the first time it is seen in a minute
+// and nothing afterwards.
+type Tracker struct {
+	client   *db.DiceDB
+	window   time.Duration
+	history  time.Duration
+	cacheTTL time.Duration
+
+	mu         sync.Mutex
+	seenMinute time.Time
+	seen       map[string]struct{}
+
+	snapshotMu sync.Mutex
+	cached     *Snapshot
+	cachedAt   time.Time
+}
+
+// NewTracker creates a tracker counting clients seen within window as active
+// and reporting the per-minute counts of the last history
+func NewTracker(client *db.DiceDB, window, history, cacheTTL time.Duration) *Tracker {
+	return &Tracker{
+		client:   client,
+		window:   max(window.Truncate(time.Minute), time.Minute),
+		history:  max(history.Truncate(time.Minute), time.Minute),
+		cacheTTL: cacheTTL,
+		seen:     make(map[string]struct{}),
+	}
+}
+
+// ClientID identifies the client of a request by its session ID if it sent
+// one, otherwise by its pseudonymized IP address
+func ClientID(r *http.Request) string {
+	if sessionID := r.Header.Get(analytics.SessionIDHeader); sessionID != "" {
+		return "session:" + sessionID
+	}
+
+	ip := audit.ClientFromContext(r.Context()).IP
+	if ip == "" {
+		ip, _, _ = net.SplitHostPort(r.RemoteAddr)
+	}
+	sum := sha256.Sum256([]byte("playground_mono:presence:" + ip))
+	return "ip:" + hex.EncodeToString(sum[:16])
+}
+
+// Touch records that the client was active at now
+func (t *Tracker) Touch(ctx context.Context, clientID string, now time.Time) error {
+	minute := now.UTC().Truncate(time.Minute)
+
+	t.mu.Lock()
+	if !minute.Equal(t.seenMinute) {
+		t.seenMinute, t.seen = minute, make(map[string]struct{})
+	}
+	if _, seen := t.seen[clientID]; seen {
+		t.mu.Unlock()
+		return nil
+	}
+	if len(t.seen) < maxSeenPerMinute {
+		t.seen[clientID] = struct{}{}
+	}
+	t.mu.Unlock()
+
+	key := minuteKey(minute)
+	_, err := t.client.Client.Pipelined(ctx, func(pipe dicedb.Pipeliner) error {
+		pipe.PFAdd(ctx, key, clientID)
+		pipe.Expire(ctx, key, t.window+t.history+time.Minute)
+		return nil
+	})
+	if err != nil {
+		// Forget the client so that its next request retries the write
+		t.mu.Lock()
+		if minute.Equal(t.seenMinute) {
+			delete(t.seen, clientID)
+		}
+		t.mu.Unlock()
+		return fmt.Errorf("failed to record presence: %w", err)
+	}
+	return nil
+}
+
+// Snapshot returns the active clients at now along with the history, cached for
+// the configured TTL
+func (t *Tracker) Snapshot(ctx context.Context, now time.Time) (*Snapshot, error) {
+	t.snapshotMu.Lock()
+	defer t.snapshotMu.Unlock()
+
+	if t.cached != nil && now.Sub(t.cachedAt) < t.cacheTTL {
+		return t.cached, nil
+	}
+
+	current := now.UTC().Truncate(time.Minute)
+	windowKeys := make([]string, 0, int(t.window/time.Minute))
+	for minute := current.Add(-t.window + time.Minute); !minute.After(current); minute = minute.Add(time.Minute) {
+		windowKeys = append(windowKeys, minuteKey(minute))
+	}
+
+	var active *dicedb.IntCmd
+	var minutes []time.Time
+	var counts []*dicedb.IntCmd
+	_, err := t.client.Client.Pipelined(ctx, func(pipe dicedb.Pipeliner) error {
+		active = pipe.PFCount(ctx, windowKeys...)
+		for minute := current.Add(-t.history + time.Minute); !minute.After(current); minute = minute.Add(time.Minute) {
+			minutes = append(minutes, minute)
+			counts = append(counts, pipe.PFCount(ctx, minuteKey(minute)))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count active clients: %w", err)
+	}
+
+	snapshot := &Snapshot{
+		Active:        active.Val(),
+		WindowSeconds: int64(t.window.Seconds()),
+		UpdatedAt:     now.UTC(),
+		History:       make([]Interval, len(minutes)),
+	}
+	for i, minute := range minutes {
+		snapshot.History[i] = Interval{Time: minute, Active: counts[i].Val()}
+	}
+
+	t.cached, t.cachedAt = snapshot, now
+	return snapshot, nil
+}
+
+func minuteKey(minute time.Time) string {
+	return utils.PresenceKeyPrefix + minute.Format(minuteLayout)
+}
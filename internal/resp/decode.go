@@ -0,0 +1,88 @@
+package resp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// MaxArgs is the maximum number of elements accepted in a command
+const MaxArgs = 1024 * 1024
+
+// ErrProtocol is wrapped by the errors returned for malformed input
+var ErrProtocol = errors.New("protocol error")
+
+// DecodeArgs decodes a single command encoded as a RESP array of bulk strings,
+// the way clients send commands, e.g. "*2\r\n$3\r\nGET\r\n$1\r\nk\r\n". Inline
+// commands such as "GET k\r\n" are accepted as well. Empty input yields no
+// arguments.
+func DecodeArgs(data []byte) ([]string, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+	if data[0] != '*' {
+		return decodeInline(data)
+	}
+
+	line, rest, err := readLine(data)
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(string(line[1:]))
+	if err != nil || count < 0 || count > MaxArgs {
+		return nil, fmt.Errorf("%w: invalid multibulk length %q", ErrProtocol, line[1:])
+	}
+
+	// The count is client controlled, size the slice by what the remaining
+	// data can hold rather than allocating for the announced count up front
+	args := make([]string, 0, min(count, len(rest)/4))
+	for i := 0; i < count; i++ {
+		line, rest, err = readLine(rest)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, fmt.Errorf("%w: expected '$', got %q", ErrProtocol, line)
+		}
+		length, err := strconv.Atoi(string(line[1:]))
+		if err != nil || length < 0 {
+			return nil, fmt.Errorf("%w: invalid bulk length %q", ErrProtocol, line[1:])
+		}
+		if length > len(rest)-2 || rest[length] != '\r' || rest[length+1] != '\n' {
+			return nil, fmt.Errorf("%w: bulk string of %d bytes is truncated", ErrProtocol, length)
+		}
+		args = append(args, string(rest[:length]))
+		rest = rest[length+2:]
+	}
+
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("%w: unexpected data after the command", ErrProtocol)
+	}
+	return args, nil
+}
+
+// decodeInline splits an inline command on whitespace. Quoting is not
+// supported, values containing spaces must use the array form.
+func decodeInline(data []byte) ([]string, error) {
+	line, rest, _ := bytes.Cut(data, []byte("\n"))
+	if len(bytes.TrimSpace(rest)) > 0 {
+		return nil, fmt.Errorf("%w: inline requests must be a single line", ErrProtocol)
+	}
+
+	fields := bytes.Fields(line)
+	args := make([]string, len(fields))
+	for i, field := range fields {
+		args[i] = string(field)
+	}
+	return args, nil
+}
+
+// readLine returns the line up to the next CRLF and the data following it
+func readLine(data []byte) (line, rest []byte, err error) {
+	i := bytes.Index(data, []byte("\r\n"))
+	if i < 0 {
+		return nil, nil, fmt.Errorf("%w: missing CRLF", ErrProtocol)
+	}
+	return data[:i], data[i+2:], nil
+}
@@ -0,0 +1,96 @@
+// Package resp encodes DiceDB replies and decodes commands in the RESP wire
+// protocol, so that clients can see what goes over the wire.
+package resp
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"server/internal/db"
+)
+
+// Protocol versions supported by the encoder
+const (
+	RESP2 = 2
+	RESP3 = 3
+)
+
+// Encode returns the RESP encoding of a reply returned by db.ExecuteCommandRaw
+// for the given protocol version. RESP3 only changes how nulls, doubles and
+// booleans are encoded, the other types are the same in both versions.
+func Encode(reply interface{}, version int) []byte {
+	return AppendReply(nil, reply, version)
+}
+
+// AppendReply appends the RESP encoding of reply to buf and returns the
+// extended buffer
+func AppendReply(buf []byte, reply interface{}, version int) []byte {
+	switch v := reply.(type) {
+	case nil:
+		if version >= RESP3 {
+			return append(buf, "_\r\n"...)
+		}
+		return append(buf, "$-1\r\n"...)
+	case db.StatusReply:
+		return appendLine(buf, '+', string(v))
+	case db.ErrorReply:
+		return appendLine(buf, '-', string(v))
+	case string:
+		return appendBulk(buf, v)
+	case int64:
+		return appendLine(buf, ':', strconv.FormatInt(v, 10))
+	case float64:
+		if version >= RESP3 {
+			return appendLine(buf, ',', formatDouble(v))
+		}
+		return appendBulk(buf, formatDouble(v))
+	case bool:
+		if version >= RESP3 {
+			if v {
+				return append(buf, "#t\r\n"...)
+			}
+			return append(buf, "#f\r\n"...)
+		}
+		if v {
+			return append(buf, ":1\r\n"...)
+		}
+		return append(buf, ":0\r\n"...)
+	case []interface{}:
+		buf = appendLine(buf, '*', strconv.Itoa(len(v)))
+		for _, item := range v {
+			buf = AppendReply(buf, item, version)
+		}
+		return buf
+	default:
+		return appendBulk(buf, fmt.Sprint(v))
+	}
+}
+
+// appendLine appends a single line type such as a status or an error. Line
+// breaks cannot be represented and are replaced with spaces, like DiceDB does.
+func appendLine(buf []byte, prefix byte, s string) []byte {
+	buf = append(buf, prefix)
+	buf = append(buf, strings.NewReplacer("\r", " ", "\n", " ").Replace(s)...)
+	return append(buf, "\r\n"...)
+}
+
+func appendBulk(buf []byte, s string) []byte {
+	buf = appendLine(buf, '$', strconv.Itoa(len(s)))
+	buf = append(buf, s...)
+	return append(buf, "\r\n"...)
+}
+
+func formatDouble(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
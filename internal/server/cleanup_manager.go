@@ -3,20 +3,38 @@ package server
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"server/internal/db"
+	"server/internal/logging"
+	"server/internal/metrics"
 	"server/internal/server/utils"
+	"server/internal/tracing"
 	"strconv"
 	"sync"
 	"time"
 
 	"github.com/dicedb/dicedb-go"
+	"go.opentelemetry.io/otel/codes"
 )
 
 type CleanupManager struct {
 	diceDBAdminClient *db.DiceDB
 	diceDBClient      *db.DiceDB
 	cronFrequency     time.Duration
+
+	// Run state exposed to health checks, guarded by mu
+	mu          sync.RWMutex
+	running     bool
+	lastSuccess time.Time
+	lastErr     error
+}
+
+// CleanupStatus is a snapshot of the cleanup cron state
+type CleanupStatus struct {
+	Running     bool
+	LastSuccess time.Time
+	LastErr     error
 }
 
 func NewCleanupManager(diceDBAdminClient *db.DiceDB,
@@ -30,12 +48,41 @@ func NewCleanupManager(diceDBAdminClient *db.DiceDB,
 
 func (c *CleanupManager) Run(ctx context.Context, wg *sync.WaitGroup) {
 	defer wg.Done()
+	c.setRunning(true)
+	defer c.setRunning(false)
 	c.start(ctx)
 }
 
+// Status returns whether the cleanup cron is running and the outcome of its last runs
+func (c *CleanupManager) Status() CleanupStatus {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return CleanupStatus{
+		Running:     c.running,
+		LastSuccess: c.lastSuccess,
+		LastErr:     c.lastErr,
+	}
+}
+
+func (c *CleanupManager) setRunning(running bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.running = running
+}
+
+func (c *CleanupManager) recordRun(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastErr = err
+	if err == nil {
+		c.lastSuccess = time.Now()
+	}
+}
+
 func (c *CleanupManager) start(ctx context.Context) {
 	ticker := time.NewTicker(c.cronFrequency)
 	defer ticker.Stop()
+	logger := logging.FromContext(ctx, logging.ComponentCleanup)
 
 	// Get the last cron run time
 	resp := c.diceDBAdminClient.Client.Get(ctx, utils.LastCronCleanupTimeUnixMs)
@@ -43,41 +90,63 @@ func (c *CleanupManager) start(ctx context.Context) {
 		if errors.Is(resp.Err(), dicedb.Nil) {
 			// Default to current time
 			cleanupTime := strconv.FormatInt(time.Now().UnixMilli(), 10)
-			slog.Debug("Defaulting last cron cleanup time key since not set", slog.Any("cleanupTime", cleanupTime))
+			logger.Debug("Defaulting last cron cleanup time key since not set", slog.Any("cleanupTime", cleanupTime))
 			resp := c.diceDBAdminClient.Client.Set(ctx, utils.LastCronCleanupTimeUnixMs, cleanupTime, -1)
 			if resp.Err() != nil {
-				slog.Error("Failed to set default value for last cron cleanup time key",
-					slog.Any("err", resp.Err().Error()))
+				logger.Error("Failed to set default value for last cron cleanup time key",
+					slog.Any("err", resp.Err()))
 			}
 		} else {
-			slog.Error("Failed to get last cron cleanup time", slog.Any("err", resp.Err().Error()))
+			logger.Error("Failed to get last cron cleanup time", slog.Any("err", resp.Err()))
 		}
 	}
 
 	for {
 		select {
 		case <-ticker.C:
-			c.runCronTasks()
+			c.recordRun(c.runTraced(ctx))
 		case <-ctx.Done():
-			slog.Info("Shutting down cleanup manager")
+			logger.Info("Shutting down cleanup manager")
 			return
 		}
 	}
 }
 
-func (c *CleanupManager) runCronTasks() {
+// runTraced runs the cron tasks within their own trace and records their metrics.
+// A run that has started is completed even if the manager is being shut down.
+func (c *CleanupManager) runTraced(ctx context.Context) error {
+	ctx, span := tracing.Tracer().Start(context.WithoutCancel(ctx), "cleanup.run")
+	defer span.End()
+
+	start := time.Now()
+	err := c.runCronTasks(ctx)
+	metrics.ObserveCleanupRun(time.Since(start), err)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+func (c *CleanupManager) runCronTasks(ctx context.Context) error {
+	logger := logging.FromContext(ctx, logging.ComponentCleanup)
+
 	// Flush the user DiceDB instance
-	resp := c.diceDBClient.Client.FlushDB(c.diceDBClient.Ctx)
+	resp := c.diceDBClient.Client.FlushDB(ctx)
 	if resp.Err() != nil {
-		slog.Error("Failed to flush keys from DiceDB user instance.")
+		logger.Error("Failed to flush keys from DiceDB user instance", slog.Any("err", resp.Err()))
+		return fmt.Errorf("failed to flush DiceDB user instance: %w", resp.Err())
 	}
 
 	// Update last cron run time on DiceDB instance
 	cleanupTime := strconv.FormatInt(time.Now().UnixMilli(), 10)
-	resp = c.diceDBAdminClient.Client.Set(c.diceDBClient.Ctx, utils.LastCronCleanupTimeUnixMs,
+	resp = c.diceDBAdminClient.Client.Set(ctx, utils.LastCronCleanupTimeUnixMs,
 		cleanupTime, -1)
-	slog.Debug("Updating last cron cleanup time key", slog.Any("cleanupTime", cleanupTime))
+	logger.Debug("Updating last cron cleanup time key", slog.Any("cleanupTime", cleanupTime))
 	if resp.Err() != nil {
-		slog.Error("Failed to set LastCronCleanupTimeUnixMs")
+		logger.Error("Failed to set LastCronCleanupTimeUnixMs", slog.Any("err", resp.Err()))
+		return fmt.Errorf("failed to set last cron cleanup time: %w", resp.Err())
 	}
+
+	return nil
 }
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"expvar"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sync"
+	"time"
+
+	"server/config"
+	"server/internal/apierror"
+	"server/internal/logging"
+	"server/internal/middleware"
+	util "server/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+var publishRuntimeVars sync.Once
+
+// RegisterDebugRoutes mounts pprof, the expvar runtime stats and dumps of the
+// configuration and rate limiter state on the given group, which is expected to
+// be protected by the admin authentication.
+func RegisterDebugRoutes(group *gin.RouterGroup, configValue *config.Config, rateLimiter *middleware.RateLimiterMiddleware) {
+	publishRuntimeVars.Do(func() {
+		expvar.Publish("goroutines", expvar.Func(func() any { return runtime.NumGoroutine() }))
+		expvar.Publish("gc", expvar.Func(gcStats))
+		expvar.Publish("heap", expvar.Func(heapStats))
+	})
+
+	// pprof.Index serves the named profiles (heap, goroutine, allocs...) from
+	// the path, the remaining handlers have to be mounted explicitly
+	group.GET("/pprof/", gin.WrapF(pprof.Index))
+	group.GET("/pprof/:profile", gin.WrapF(pprof.Index))
+	group.GET("/pprof/cmdline", gin.WrapF(pprof.Cmdline))
+	group.GET("/pprof/profile", gin.WrapF(pprof.Profile))
+	group.GET("/pprof/symbol", gin.WrapF(pprof.Symbol))
+	group.POST("/pprof/symbol", gin.WrapF(pprof.Symbol))
+	group.GET("/pprof/trace", gin.WrapF(pprof.Trace))
+	group.GET("/vars", gin.WrapH(expvar.Handler()))
+
+	group.GET("/config", func(c *gin.Context) {
+		util.JSONResponse(c.Writer, http.StatusOK, HTTPResponse{Data: configValue.Redacted()})
+	})
+	group.GET("/ratelimiter", func(c *gin.Context) {
+		state, err := rateLimiter.State(c.Request.Context())
+		if err != nil {
+			logging.FromContext(c.Request.Context(), logging.ComponentHTTP).Error("Failed to read rate limiter state",
+				slog.Any("err", err))
+			apierror.Write(c.Writer, apierror.Internal())
+			return
+		}
+		util.JSONResponse(c.Writer, http.StatusOK, HTTPResponse{Data: state})
+	})
+}
+
+func gcStats() any {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return map[string]any{
+		"num_gc":          stats.NumGC,
+		"pause_total_ns":  stats.PauseTotalNs,
+		"last_gc":         time.Unix(0, int64(stats.LastGC)).UTC(),
+		"gc_cpu_fraction": stats.GCCPUFraction,
+	}
+}
+
+func heapStats() any {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return map[string]any{
+		"alloc_bytes":    stats.HeapAlloc,
+		"sys_bytes":      stats.HeapSys,
+		"idle_bytes":     stats.HeapIdle,
+		"inuse_bytes":    stats.HeapInuse,
+		"released_bytes": stats.HeapReleased,
+		"objects":        stats.HeapObjects,
+	}
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"server/internal/db"
+	"server/internal/keyspace"
+	"server/internal/logging"
+	"server/internal/timing"
+	"server/util/cmds"
+)
+
+// diffRequested reports whether the client asked for the changes of the keys
+// touched by the command with ?diff=true
+func diffRequested(r *http.Request) bool {
+	requested, _ := strconv.ParseBool(r.URL.Query().Get("diff"))
+	return requested
+}
+
+// newDiffer captures the keys touched by the command when a diff is requested.
+// It returns nil for read-only commands, commands without keys and when the
+// keys cannot be captured, the command is executed without a diff.
+func (s *HTTPServer) newDiffer(r *http.Request, diceCmd *cmds.CommandRequest) *keyspace.Differ {
+	if !diffRequested(r) || db.ClassifyCommand(diceCmd.Cmd, diceCmd.Args) == db.ClassRead {
+		return nil
+	}
+	names := cmds.KeyNames(diceCmd.Cmd, diceCmd.Args)
+	if len(names) == 0 {
+		return nil
+	}
+
+	start := time.Now()
+	differ, err := keyspace.NewDiffer(r.Context(), s.DiceClient, names)
+	timing.FromContext(r.Context()).Add(timing.Diff, time.Since(start))
+	if err != nil {
+		logging.FromContext(r.Context(), logging.ComponentHTTP).Warn("Failed to capture keys before command",
+			slog.String("cmd", diceCmd.Cmd), slog.Any("err", err))
+		return nil
+	}
+	return differ
+}
+
+// keyspaceDiff returns the changes of the keys captured by differ, nil if
+// differ is nil or the keys cannot be captured again
+func (s *HTTPServer) keyspaceDiff(r *http.Request, differ *keyspace.Differ) *keyspace.Diff {
+	if differ == nil {
+		return nil
+	}
+
+	start := time.Now()
+	diff, err := differ.Diff(r.Context())
+	timing.FromContext(r.Context()).Add(timing.Diff, time.Since(start))
+	if err != nil {
+		logging.FromContext(r.Context(), logging.ComponentHTTP).Warn("Failed to capture keys after command",
+			slog.Any("err", err))
+		return nil
+	}
+	return diff
+}
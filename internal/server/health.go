@@ -0,0 +1,145 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"server/internal/db"
+	util "server/util"
+)
+
+const (
+	HealthStatusOK       = "ok"
+	HealthStatusDegraded = "degraded"
+	DependencyStatusUp   = "up"
+	DependencyStatusDown = "down"
+)
+
+// HealthChecker serves the liveness and readiness probes. Readiness results are
+// cached for cacheTTL so that frequent probes cannot overload DiceDB, and concurrent
+// probes arriving while a check is in progress wait for it instead of pinging again.
+type HealthChecker struct {
+	diceDBAdminClient *db.DiceDB
+	diceDBClient      *db.DiceDB
+	cleanupManager    *CleanupManager
+	pingTimeout       time.Duration
+	cacheTTL          time.Duration
+
+	mu        sync.Mutex
+	cached    *ReadinessReport
+	checkedAt time.Time
+}
+
+// ReadinessReport is the body returned by the readiness probe
+type ReadinessReport struct {
+	Status       string                      `json:"status"`
+	CheckedAt    time.Time                   `json:"checked_at"`
+	Dependencies map[string]DependencyStatus `json:"dependencies"`
+	CleanupCron  *CleanupCronStatus          `json:"cleanup_cron,omitempty"`
+}
+
+// DependencyStatus is the outcome of pinging a single dependency
+type DependencyStatus struct {
+	Status    string  `json:"status"`
+	State     string  `json:"state,omitempty"` // Connection state as tracked by the connection monitor
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// CleanupCronStatus reports the state of the cleanup cron
+type CleanupCronStatus struct {
+	Running     bool       `json:"running"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+func NewHealthChecker(diceDBAdminClient *db.DiceDB, diceDBClient *db.DiceDB, cleanupManager *CleanupManager,
+	pingTimeout, cacheTTL time.Duration) *HealthChecker {
+	return &HealthChecker{
+		diceDBAdminClient: diceDBAdminClient,
+		diceDBClient:      diceDBClient,
+		cleanupManager:    cleanupManager,
+		pingTimeout:       pingTimeout,
+		cacheTTL:          cacheTTL,
+	}
+}
+
+// Live reports that the process is up and able to serve requests, it never
+// touches any dependency.
+func (h *HealthChecker) Live(w http.ResponseWriter, r *http.Request) {
+	util.JSONResponse(w, http.StatusOK, map[string]string{"status": HealthStatusOK})
+}
+
+// Ready reports whether the server can serve playground requests, responding
+// with 503 when any DiceDB instance is unreachable.
+func (h *HealthChecker) Ready(w http.ResponseWriter, r *http.Request) {
+	report := h.Check(r.Context())
+
+	status := http.StatusOK
+	if report.Status != HealthStatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	util.JSONResponse(w, status, report)
+}
+
+// Check returns the readiness report, serving it from cache when it is fresh enough
+func (h *HealthChecker) Check(ctx context.Context) *ReadinessReport {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.cached != nil && time.Since(h.checkedAt) < h.cacheTTL {
+		return h.cached
+	}
+
+	report := &ReadinessReport{
+		Status:    HealthStatusOK,
+		CheckedAt: time.Now().UTC(),
+		Dependencies: map[string]DependencyStatus{
+			"dicedb_admin": h.ping(ctx, h.diceDBAdminClient),
+			"dicedb":       h.ping(ctx, h.diceDBClient),
+		},
+	}
+	for _, dependency := range report.Dependencies {
+		if dependency.Status != DependencyStatusUp {
+			report.Status = HealthStatusDegraded
+		}
+	}
+
+	if h.cleanupManager != nil {
+		cleanupStatus := h.cleanupManager.Status()
+		report.CleanupCron = &CleanupCronStatus{Running: cleanupStatus.Running}
+		if !cleanupStatus.LastSuccess.IsZero() {
+			lastSuccess := cleanupStatus.LastSuccess.UTC()
+			report.CleanupCron.LastSuccess = &lastSuccess
+		}
+		if cleanupStatus.LastErr != nil {
+			report.CleanupCron.LastError = cleanupStatus.LastErr.Error()
+		}
+	}
+
+	h.cached = report
+	h.checkedAt = time.Now()
+	return report
+}
+
+func (h *HealthChecker) ping(ctx context.Context, client *db.DiceDB) DependencyStatus {
+	if client == nil {
+		return DependencyStatus{Status: DependencyStatusDown, Error: "client not initialized"}
+	}
+
+	// The report is shared between probes, so it must not fail because the
+	// probe that triggered the check went away
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), h.pingTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := client.Ping(ctx)
+	latency := float64(time.Since(start).Microseconds()) / 1000
+
+	if err != nil {
+		return DependencyStatus{Status: DependencyStatusDown, State: client.State().String(), LatencyMs: latency, Error: err.Error()}
+	}
+	return DependencyStatus{Status: DependencyStatusUp, State: client.State().String(), LatencyMs: latency}
+}
@@ -1,83 +1,165 @@
 package server
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"path"
+	"strconv"
 	"time"
 
+	"server/config"
+	"server/internal/analytics"
+	"server/internal/apierror"
+	"server/internal/audit"
+	"server/internal/cliformat"
 	"server/internal/db"
+	"server/internal/explain"
+	"server/internal/export"
+	"server/internal/keyspace"
+	"server/internal/logging"
+	"server/internal/msgpack"
+	"server/internal/pagination"
+	"server/internal/presence"
+	"server/internal/resp"
+	"server/internal/session"
+	"server/internal/slowlog"
+	"server/internal/timing"
 	util "server/util"
+	"server/util/cmds"
 
 	"github.com/gin-gonic/gin"
 )
 
 type HTTPServer struct {
-	httpServer *http.Server
-	DiceClient *db.DiceDB
+	httpServer      *http.Server
+	DiceClient      *db.DiceDB
+	Auditor         *audit.Auditor      // Records every executed command, nil disables auditing
+	SlowLog         *slowlog.Log        // Records commands exceeding the slow log threshold, nil disables it
+	Analytics       *analytics.Recorder // Counts command usage, nil disables analytics
+	Presence        *presence.Tracker   // Counts active clients
+	Pager           *pagination.Pager   // Truncates replies exceeding the response budget, nil disables truncation
+	Sessions        *session.Manager    // Pins connections to sessions, nil disables pinning
+	BlockCommands   bool                // Rejects blocklisted commands, set in production
+	shutdownTimeout time.Duration
 }
 
 type HTTPResponse struct {
-	Data interface{} `json:"data"`
-}
-
-type HTTPErrorResponse struct {
-	Error interface{} `json:"error"`
+	Data        interface{}        `json:"data"`
+	Truncated   bool               `json:"truncated,omitempty"`    // Set when Data holds only the first part of the reply
+	TotalLength int                `json:"total_length,omitempty"` // Length of the whole reply in bytes, only set when truncated
+	Cursor      string             `json:"cursor,omitempty"`       // Token to fetch the next part of a truncated reply
+	Diff        *keyspace.Diff     `json:"diff,omitempty"`         // Changes of the touched keys, only set when requested with ?diff=true
+	Timing      map[string]float64 `json:"timing,omitempty"`       // Phase durations in ms, only set when requested with ?timing=true
 }
 
-func errorResponse(response string) string {
-	errorMessage := map[string]string{"error": response}
-	jsonResponse, err := json.Marshal(errorMessage)
-	if err != nil {
-		slog.Error("Error marshaling response: %v", slog.Any("err", err))
-		return `{"error": "internal server error"}`
-	}
+const (
+	// defaultSlowLogLimit is the number of entries returned by the slow log endpoint by default
+	defaultSlowLogLimit = 50
+	// defaultAnalyticsDays is the number of days reported by the analytics endpoint by default
+	defaultAnalyticsDays = 7
+)
 
-	return string(jsonResponse)
+// Options holds the dependencies of the HTTP server handlers, nil fields
+// disable the matching feature
+type Options struct {
+	DiceClient *db.DiceDB
+	Auditor    *audit.Auditor
+	SlowLog    *slowlog.Log
+	Analytics  *analytics.Recorder
+	Presence   *presence.Tracker
+	Pager      *pagination.Pager
+	Sessions   *session.Manager
 }
 
-func NewHTTPServer(router *gin.Engine, diceDBAdminClient *db.DiceDB, diceClient *db.DiceDB,
-	limit int64, window float64) *HTTPServer {
+// NewHTTPServer creates a server listening on the configured port
+func NewHTTPServer(router *gin.Engine, configValue *config.Config, opts Options) *HTTPServer {
 	return &HTTPServer{
 		httpServer: &http.Server{
-			Addr:              ":8080",
+			Addr:              configValue.Server.Port,
 			Handler:           router,
 			ReadHeaderTimeout: 5 * time.Second,
 		},
-		DiceClient: diceClient,
+		DiceClient:      opts.DiceClient,
+		Auditor:         opts.Auditor,
+		SlowLog:         opts.SlowLog,
+		Analytics:       opts.Analytics,
+		Presence:        opts.Presence,
+		Pager:           opts.Pager,
+		Sessions:        opts.Sessions,
+		BlockCommands:   configValue.Server.Environment == "production",
+		shutdownTimeout: configValue.Server.ShutdownTimeout,
 	}
 }
 
+// Run starts the HTTP server and blocks until either the server fails or the
+// context is cancelled. On cancellation the server stops accepting new connections
+// and waits for in-flight requests to complete before returning.
 func (s *HTTPServer) Run(ctx context.Context) error {
-	var err error
-
+	errCh := make(chan error, 1)
 	go func() {
-		slog.Info("starting HTTP server at", slog.String("addr", s.httpServer.Addr))
-		if err = s.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			slog.Error("http server error: %v", slog.Any("err", err))
+		logging.Component(logging.ComponentServer).Info("Starting HTTP server", slog.String("addr", s.httpServer.Addr))
+		if err := s.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
 		}
 	}()
 
-	go func() {
-		<-ctx.Done()
-		err = s.Shutdown()
-		if err != nil {
-			slog.Error("Failed to gracefully shutdown HTTP server", slog.Any("err", err))
-			return
-		}
-	}()
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
 
-	return err
+	logging.Component(logging.ComponentServer).Info("Shutting down HTTP server, draining in-flight requests",
+		slog.Duration("timeout", s.shutdownTimeout))
+	return s.Shutdown()
 }
 
+// Shutdown gracefully stops the HTTP server. It does not close the DiceDB clients,
+// these are owned by the caller and must only be closed once Shutdown returns.
 func (s *HTTPServer) Shutdown() error {
-	if err := s.DiceClient.Client.Close(); err != nil {
-		slog.Error("failed to close dicedb client: %v", slog.Any("err", err))
+	ctx := context.Background()
+	if s.shutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.shutdownTimeout)
+		defer cancel()
 	}
 
-	return s.httpServer.Shutdown(context.Background())
+	if err := s.httpServer.Shutdown(ctx); err != nil {
+		return fmt.Errorf("failed to drain in-flight requests: %w", err)
+	}
+
+	return nil
+}
+
+// Close releases the dependencies of the server once Run returned. The queued
+// audit events and slow log entries are written while the DiceDB clients are
+// still open, then stopBackground stops the background jobs and waits for them
+// and only then are the clients closed, in the given order.
+func (s *HTTPServer) Close(stopBackground func(), clients ...*db.DiceDB) {
+	logger := logging.Component(logging.ComponentServer)
+	flush := func(name string, drain func(context.Context) error) {
+		ctx := context.Background()
+		if s.shutdownTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, s.shutdownTimeout)
+			defer cancel()
+		}
+		if err := drain(ctx); err != nil {
+			logger.Error("Failed to flush "+name, slog.Any("err", err))
+		}
+	}
+	flush("audit log", s.Auditor.Close)
+	flush("slow log", s.SlowLog.Close)
+
+	stopBackground()
+	for _, client := range clients {
+		client.CloseDiceDB()
+	}
 }
 
 func (s *HTTPServer) HealthCheck(w http.ResponseWriter, request *http.Request) {
@@ -85,39 +167,439 @@ func (s *HTTPServer) HealthCheck(w http.ResponseWriter, request *http.Request) {
 }
 
 func (s *HTTPServer) CliHandler(w http.ResponseWriter, r *http.Request) {
-	diceCmd, err := util.ParseHTTPRequest(r)
+	start := time.Now()
+	timings := timing.FromContext(r.Context())
+	logger := logging.FromContext(r.Context(), logging.ComponentHTTP)
+	diceCmd, err := util.ParseHTTPRequest(r, s.BlockCommands)
+	timings.Add(timing.Parse, time.Since(start))
+	mediaType, formatErr := util.NegotiateRequest(r, util.MediaTypeJSON, util.MediaTypeText, util.MediaTypeRESP,
+		util.MediaTypeMsgPack, util.MediaTypeNDJSON, util.MediaTypeCSV)
+	if err == nil && formatErr != nil {
+		err = apierror.New(apierror.CodeInvalidRequest, formatErr.Error()).
+			WithHint("Use one of json, text, resp, msgpack, ndjson or csv.")
+		mediaType = util.MediaTypeJSON
+	}
 	if err != nil {
-		http.Error(w, errorResponse(err.Error()), http.StatusBadRequest)
+		s.audit(r, &cmds.CommandRequest{Cmd: util.CommandFromPath(r.URL.Path)}, audit.OutcomeRejected, err, start)
+		s.recordVisit(r, util.CommandFromPath(r.URL.Path), audit.OutcomeRejected, start)
+		writeError(w, r, mediaType, commandError(err))
+		return
+	}
+
+	// Commands of a session with a pinned connection run on that connection
+	r, release, ok := s.bindSession(w, r, mediaType)
+	if !ok {
 		return
 	}
+	defer release()
+
+	if mediaType != util.MediaTypeJSON {
+		s.rawReplyResponse(w, r, mediaType, diceCmd, start)
+		return
+	}
+
+	// Capture the keys the command touches, to diff them once it completes
+	differ := s.newDiffer(r, diceCmd)
 
-	resp, err := s.DiceClient.ExecuteCommand(diceCmd)
+	executeStart := time.Now()
+	resp, err := s.DiceClient.ExecuteCommand(r.Context(), diceCmd)
+	timings.Add(timing.DiceDB, time.Since(executeStart))
+	s.audit(r, diceCmd, outcomeOf(err), err, start)
+	s.recordSlowCommand(r, diceCmd, outcomeOf(err), start)
+	s.recordVisit(r, diceCmd.Cmd, outcomeOf(err), start)
+
+	if errors.Is(err, context.Canceled) {
+		logger.Debug("Client disconnected before command completed", slog.String("cmd", diceCmd.Cmd))
+		return
+	}
 	if err != nil {
-		slog.Error("error: failure in executing command", "error", slog.Any("err", err))
-		http.Error(w, errorResponse(err.Error()), http.StatusBadRequest)
+		logCommandError(logger, diceCmd, err)
+		apierror.Write(w, explain.Enrich(r.Context(), s.DiceClient, diceCmd, commandError(err)))
 		return
 	}
 
 	respStr, ok := resp.(string)
 	if !ok {
-		slog.Error("error: response is not a string", "error", slog.Any("err", err))
-		http.Error(w, errorResponse("internal server error"), http.StatusInternalServerError)
+		logger.Error("Unexpected command response type", slog.String("cmd", diceCmd.Cmd), slog.String("type", fmt.Sprintf("%T", resp)))
+		apierror.Write(w, apierror.Internal())
 		return
 	}
 
-	httpResponse := HTTPResponse{Data: respStr}
+	diff := s.keyspaceDiff(r, differ)
+
+	// Serialization is reported in the Server-Timing header only, the body
+	// cannot contain the time it takes to encode itself
+	serializeStart := time.Now()
+	httpResponse := pageResponse(s.Pager.Paginate(r.Context(), respStr))
+	httpResponse.Diff = diff
+	if timingRequested(r) {
+		httpResponse.Timing = timings.Milliseconds()
+	}
 	responseJSON, err := json.Marshal(httpResponse)
+	timings.Add(timing.Serialize, time.Since(serializeStart))
+	if err != nil {
+		logger.Error("Error marshaling response to JSON", slog.Any("err", err))
+		apierror.Write(w, apierror.Internal())
+		return
+	}
+
+	if _, err = w.Write(responseJSON); err != nil {
+		logger.Error("Failed to write response", slog.Any("err", err))
+	}
+}
+
+// CursorHandler returns the next part of a truncated reply
+func (s *HTTPServer) CursorHandler(w http.ResponseWriter, r *http.Request) {
+	page, err := s.Pager.Next(r.Context(), path.Base(r.URL.Path))
+	switch {
+	case errors.Is(err, pagination.ErrInvalidCursor):
+		apierror.Write(w, apierror.New(apierror.CodeInvalidRequest, err.Error()))
+		return
+	case errors.Is(err, pagination.ErrCursorExpired):
+		apierror.Write(w, apierror.New(apierror.CodeCursorExpired, err.Error()))
+		return
+	case err != nil:
+		logging.FromContext(r.Context(), logging.ComponentHTTP).Error("Failed to read truncated reply", slog.Any("err", err))
+		apierror.Write(w, apierror.Internal())
+		return
+	}
+
+	util.JSONResponse(w, http.StatusOK, pageResponse(page))
+}
+
+func pageResponse(page pagination.Page) HTTPResponse {
+	response := HTTPResponse{Data: page.Data}
+	if page.Truncated {
+		response.Truncated = true
+		response.TotalLength = page.TotalLength
+		response.Cursor = page.Cursor
+	}
+	return response
+}
+
+// commandError maps an error returned while parsing or executing a command to
+// the error sent to the client
+func commandError(err error) *apierror.Error {
+	var apiErr *apierror.Error
+	var timeoutErr *db.CommandTimeoutError
+	var unavailableErr *db.BackendUnavailableError
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr
+	case errors.As(err, &timeoutErr):
+		return apierror.New(apierror.CodeTimeout, timeoutErr.Error()).
+			WithDetail("command", timeoutErr.Cmd).
+			WithDetail("timeout_ms", timeoutErr.Timeout.Milliseconds())
+	case errors.As(err, &unavailableErr):
+		return apierror.New(apierror.CodeBackendUnavailable, "DiceDB is unavailable")
+	default:
+		return apierror.FromReply(err.Error())
+	}
+}
+
+// logCommandError logs a failed command at a level matching the cause
+func logCommandError(logger *slog.Logger, diceCmd *cmds.CommandRequest, err error) {
+	var timeoutErr *db.CommandTimeoutError
+	var unavailableErr *db.BackendUnavailableError
+	switch {
+	case errors.As(err, &timeoutErr):
+		logger.Warn("Command timed out", slog.String("cmd", timeoutErr.Cmd), slog.Duration("timeout", timeoutErr.Timeout))
+	case errors.As(err, &unavailableErr):
+		logger.Error("DiceDB is unavailable", slog.String("cmd", diceCmd.Cmd), slog.Any("err", unavailableErr.Err))
+	default:
+		logger.Debug("Command failed", slog.String("cmd", diceCmd.Cmd), slog.Any("err", err))
+	}
+}
+
+// errorReply renders an error of the server as an error reply, using the
+// error code as the prefix like DiceDB does with ERR or WRONGTYPE
+func errorReply(err *apierror.Error) db.ErrorReply {
+	return db.ErrorReply(string(err.Code) + " " + err.Message)
+}
+
+// writeError sends an error in the negotiated media type. Plain text and RESP
+// clients get an error reply, the other formats the error object.
+func writeError(w http.ResponseWriter, r *http.Request, mediaType string, apiErr *apierror.Error) {
+	switch mediaType {
+	case util.MediaTypeText, util.MediaTypeRESP:
+		replyResponse(w, r, mediaType, apiErr.Status(), nil, errorReply(apiErr))
+	case util.MediaTypeMsgPack:
+		body, err := msgpack.Encode(apierror.Response{Error: apiErr})
+		if err != nil {
+			slog.Error("Failed to encode error as MessagePack", slog.Any("err", err))
+			apierror.Write(w, apierror.Internal())
+			return
+		}
+		writeBody(w, util.MediaTypeMsgPack, apiErr.Status(), body)
+	case util.MediaTypeNDJSON:
+		body, _ := json.Marshal(apierror.Response{Error: apiErr})
+		writeBody(w, util.MediaTypeNDJSON, apiErr.Status(), append(body, '\n'))
+	default:
+		apierror.Write(w, apiErr)
+	}
+}
+
+// rawReplyResponse executes the command and writes the reply as received in
+// the negotiated media type: formatted the way redis-cli prints it, RESP or
+// MessagePack encoded, or laid out as NDJSON or CSV rows. Error replies are
+// sent with the same status as in JSON mode. Only JSON replies are paginated,
+// a page of these formats would not be valid on its own, so the reply is sent
+// whole whatever the response budget.
+func (s *HTTPServer) rawReplyResponse(w http.ResponseWriter, r *http.Request, mediaType string,
+	diceCmd *cmds.CommandRequest, start time.Time) {
+	timings := timing.FromContext(r.Context())
+	logger := logging.FromContext(r.Context(), logging.ComponentHTTP)
+
+	executeStart := time.Now()
+	reply, err := s.DiceClient.ExecuteCommandRaw(r.Context(), diceCmd)
+	timings.Add(timing.DiceDB, time.Since(executeStart))
+	// Error replies are failed commands even though they are regular replies
+	outcomeErr := err
+	if replyErr, ok := reply.(db.ErrorReply); ok {
+		outcomeErr = replyErr
+	}
+	s.audit(r, diceCmd, outcomeOf(outcomeErr), outcomeErr, start)
+	s.recordSlowCommand(r, diceCmd, outcomeOf(outcomeErr), start)
+	s.recordVisit(r, diceCmd.Cmd, outcomeOf(outcomeErr), start)
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		logger.Debug("Client disconnected before command completed", slog.String("cmd", diceCmd.Cmd))
+		return
+	case err != nil:
+		logCommandError(logger, diceCmd, err)
+		writeError(w, r, mediaType, commandError(err))
+		return
+	}
+
+	status := http.StatusOK
+	if replyErr, ok := reply.(db.ErrorReply); ok {
+		apiErr := apierror.FromReply(string(replyErr))
+		if mediaType != util.MediaTypeText && mediaType != util.MediaTypeRESP {
+			writeError(w, r, mediaType, apiErr)
+			return
+		}
+		status = apiErr.Status()
+	}
+	replyResponse(w, r, mediaType, status, diceCmd, reply)
+}
+
+// replyResponse encodes a raw reply in the negotiated media type and sends it
+// to the client. RESP replies use RESP2 unless ?protocol=3 is requested.
+// NDJSON and CSV rows are laid out according to the command.
+func replyResponse(w http.ResponseWriter, r *http.Request, mediaType string, status int,
+	diceCmd *cmds.CommandRequest, reply interface{}) {
+	serializeStart := time.Now()
+	var body []byte
+	var err error
+	contentType := mediaType
+	switch mediaType {
+	case util.MediaTypeRESP:
+		version := resp.RESP2
+		if r.URL.Query().Get("protocol") == "3" {
+			version = resp.RESP3
+		}
+		body = resp.Encode(reply, version)
+	case util.MediaTypeMsgPack:
+		body, err = msgpack.Encode(HTTPResponse{Data: msgpack.Reply(reply)})
+	case util.MediaTypeNDJSON, util.MediaTypeCSV:
+		var buf bytes.Buffer
+		if mediaType == util.MediaTypeNDJSON {
+			err = export.WriteNDJSON(&buf, export.TableOf(diceCmd, reply))
+		} else {
+			err = export.WriteCSV(&buf, export.TableOf(diceCmd, reply))
+			contentType = "text/csv; charset=utf-8"
+		}
+		body = buf.Bytes()
+	default:
+		body = []byte(cliformat.Format(reply))
+		contentType = "text/plain; charset=utf-8"
+	}
+	timing.FromContext(r.Context()).Add(timing.Serialize, time.Since(serializeStart))
+	if err != nil {
+		logging.FromContext(r.Context(), logging.ComponentHTTP).Error("Failed to encode reply",
+			slog.String("media_type", mediaType), slog.Any("err", err))
+		apierror.Write(w, apierror.Internal())
+		return
+	}
+
+	writeBody(w, contentType, status, body)
+}
+
+func writeBody(w http.ResponseWriter, contentType string, status int, body []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	if _, err := w.Write(body); err != nil {
+		slog.Error("Failed to write response", slog.Any("err", err))
+	}
+}
+
+func (s *HTTPServer) audit(r *http.Request, diceCmd *cmds.CommandRequest, outcome string, err error, start time.Time) {
+	if s.Auditor == nil {
+		return
+	}
+
+	client := audit.ClientFromContext(r.Context())
+	event := audit.Event{
+		Timestamp: start.UTC(),
+		RequestID: client.RequestID,
+		ClientIP:  client.IP,
+		UserAgent: client.UserAgent,
+		Command:   diceCmd.Cmd,
+		ArgCount:  len(diceCmd.Args),
+		Keys:      cmds.KeyNames(diceCmd.Cmd, diceCmd.Args),
+		Outcome:   outcome,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	s.Auditor.Record(event)
+}
+
+// recordSlowCommand adds the command to the slow log if the request took longer
+// than the threshold so far, including the time spent in middlewares
+func (s *HTTPServer) recordSlowCommand(r *http.Request, diceCmd *cmds.CommandRequest, outcome string, start time.Time) {
+	if s.SlowLog == nil {
+		return
+	}
+
+	timings := timing.FromContext(r.Context())
+	duration := time.Since(start)
+	if timings != nil {
+		duration = timings.Elapsed()
+	}
+
+	s.SlowLog.Record(r.Context(), duration, slowlog.Entry{
+		Timestamp: start.UTC(),
+		RequestID: audit.ClientFromContext(r.Context()).RequestID,
+		Command:   diceCmd.Cmd,
+		ArgCount:  len(diceCmd.Args),
+		Keys:      cmds.KeyNames(diceCmd.Cmd, diceCmd.Args),
+		Outcome:   outcome,
+		Timings:   timings.Milliseconds(),
+	})
+}
+
+// recordVisit counts the command in the usage analytics. Requests abandoned by
+// the client are not counted as the command did not fail.
+func (s *HTTPServer) recordVisit(r *http.Request, cmd, outcome string, start time.Time) {
+	if s.Analytics == nil || outcome == audit.OutcomeCancelled {
+		return
+	}
+
+	s.Analytics.Record(analytics.Visit{
+		Time:      start,
+		Command:   cmd,
+		Failed:    outcome != audit.OutcomeSuccess,
+		ClientIP:  audit.ClientFromContext(r.Context()).IP,
+		SessionID: r.Header.Get(analytics.SessionIDHeader),
+	})
+}
+
+// timingRequested reports whether the client asked for the timing breakdown in
+// the response body
+func timingRequested(r *http.Request) bool {
+	requested, _ := strconv.ParseBool(r.URL.Query().Get("timing"))
+	return requested
+}
+
+// SlowLogHandler returns the most recent slow commands, newest first. The
+// number of entries is set with ?limit=, defaulting to defaultSlowLogLimit.
+func (s *HTTPServer) SlowLogHandler(w http.ResponseWriter, r *http.Request) {
+	limit := int64(defaultSlowLogLimit)
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed <= 0 {
+			apierror.Write(w, apierror.New(apierror.CodeInvalidRequest, "limit must be a positive integer"))
+			return
+		}
+		limit = parsed
+	}
+
+	entries, err := s.SlowLog.Entries(r.Context(), limit)
 	if err != nil {
-		slog.Error("error marshaling response to json", "error", slog.Any("err", err))
-		http.Error(w, errorResponse("internal server error"), http.StatusInternalServerError)
+		logging.FromContext(r.Context(), logging.ComponentHTTP).Error("Failed to read slow log", slog.Any("err", err))
+		apierror.Write(w, apierror.Internal())
 		return
 	}
 
-	_, err = w.Write(responseJSON)
+	util.JSONResponse(w, http.StatusOK, HTTPResponse{Data: entries})
+}
+
+// AnalyticsHandler returns the usage between the ?from= and ?to= dates
+// (YYYY-MM-DD, inclusive), defaulting to the last defaultAnalyticsDays days
+func (s *HTTPServer) AnalyticsHandler(w http.ResponseWriter, r *http.Request) {
+	if s.Analytics == nil {
+		apierror.Write(w, apierror.New(apierror.CodeNotFound, "analytics are disabled"))
+		return
+	}
+
+	to := time.Now().UTC()
+	if value := r.URL.Query().Get("to"); value != "" {
+		parsed, err := time.Parse(analytics.DateLayout, value)
+		if err != nil {
+			apierror.Write(w, apierror.New(apierror.CodeInvalidRequest, "to must be a date formatted as YYYY-MM-DD"))
+			return
+		}
+		to = parsed
+	}
+	from := to.AddDate(0, 0, -(defaultAnalyticsDays - 1))
+	if value := r.URL.Query().Get("from"); value != "" {
+		parsed, err := time.Parse(analytics.DateLayout, value)
+		if err != nil {
+			apierror.Write(w, apierror.New(apierror.CodeInvalidRequest, "from must be a date formatted as YYYY-MM-DD"))
+			return
+		}
+		from = parsed
+	}
+
+	report, err := s.Analytics.Report(r.Context(), from, to)
+	if errors.Is(err, analytics.ErrInvalidRange) {
+		apierror.Write(w, apierror.New(apierror.CodeInvalidRequest, err.Error()))
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context(), logging.ComponentHTTP).Error("Failed to build analytics report", slog.Any("err", err))
+		apierror.Write(w, apierror.Internal())
+		return
+	}
+
+	util.JSONResponse(w, http.StatusOK, HTTPResponse{Data: report})
+}
+
+// PresenceHandler returns the number of active clients and the per-minute
+// history. Polling it keeps the caller active, it doubles as the UI heartbeat.
+func (s *HTTPServer) PresenceHandler(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), logging.ComponentHTTP)
+	now := time.Now()
+	if err := s.Presence.Touch(r.Context(), presence.ClientID(r), now); err != nil {
+		logger.Warn("Failed to record presence", slog.Any("err", err))
+	}
+
+	snapshot, err := s.Presence.Snapshot(r.Context(), now)
 	if err != nil {
-		http.Error(w, errorResponse("internal server error"), http.StatusInternalServerError)
+		logger.Error("Failed to count active clients", slog.Any("err", err))
+		apierror.Write(w, apierror.Internal())
 		return
 	}
+
+	util.JSONResponse(w, http.StatusOK, HTTPResponse{Data: snapshot})
+}
+
+// outcomeOf maps the error returned by ExecuteCommand to an audit outcome
+func outcomeOf(err error) string {
+	var timeoutErr *db.CommandTimeoutError
+	switch {
+	case err == nil:
+		return audit.OutcomeSuccess
+	case errors.As(err, &timeoutErr):
+		return audit.OutcomeTimeout
+	case errors.Is(err, context.Canceled):
+		return audit.OutcomeCancelled
+	default:
+		return audit.OutcomeError
+	}
 }
 
 func (s *HTTPServer) SearchHandler(w http.ResponseWriter, request *http.Request) {
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"server/internal/apierror"
+	"server/internal/keyspace"
+	"server/internal/logging"
+	util "server/util"
+)
+
+// maxValueElements is the maximum number of elements of a value returned by
+// the key endpoint
+const maxValueElements = 1000
+
+// KeysHandler pages through the keys of the user DiceDB with their type, TTL
+// and memory usage. Keys are filtered with ?pattern= (a glob pattern) and
+// ?type=, ?count= keys are scanned per page and ?cursor= fetches the next page.
+func (s *HTTPServer) KeysHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	opts := keyspace.ScanOptions{
+		Cursor:  query.Get("cursor"),
+		Pattern: query.Get("pattern"),
+		Type:    query.Get("type"),
+	}
+	if value := query.Get("count"); value != "" {
+		count, err := strconv.Atoi(value)
+		if err != nil || count <= 0 || count > keyspace.MaxScanCount {
+			apierror.Write(w, apierror.New(apierror.CodeInvalidRequest,
+				"count must be an integer between 1 and "+strconv.Itoa(keyspace.MaxScanCount)))
+			return
+		}
+		opts.Count = count
+	}
+
+	r, release, ok := s.bindSession(w, r, util.MediaTypeJSON)
+	if !ok {
+		return
+	}
+	defer release()
+
+	page, err := keyspace.Scan(r.Context(), s.DiceClient, opts)
+	if err != nil {
+		s.writeKeyspaceError(w, r, err)
+		return
+	}
+	util.JSONResponse(w, http.StatusOK, HTTPResponse{Data: page})
+}
+
+// KeyHandler returns the value of a key rendered according to its type, along
+// with its TTL and memory usage. Up to ?limit= elements of hashes, lists, sets
+// and sorted sets are returned.
+func (s *HTTPServer) KeyHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/keys/")
+	if name == "" || name == r.URL.Path {
+		apierror.Write(w, apierror.New(apierror.CodeInvalidRequest, "missing key"))
+		return
+	}
+	limits := keyspace.DefaultLimits
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > maxValueElements {
+			apierror.Write(w, apierror.New(apierror.CodeInvalidRequest,
+				"limit must be an integer between 1 and "+strconv.Itoa(maxValueElements)))
+			return
+		}
+		limits.MaxElements = limit
+	}
+
+	r, release, ok := s.bindSession(w, r, util.MediaTypeJSON)
+	if !ok {
+		return
+	}
+	defer release()
+
+	value, err := keyspace.Get(r.Context(), s.DiceClient, name, limits)
+	if err != nil {
+		s.writeKeyspaceError(w, r, err)
+		return
+	}
+	util.JSONResponse(w, http.StatusOK, HTTPResponse{Data: value})
+}
+
+// writeKeyspaceError writes the error of a key browser request
+func (s *HTTPServer) writeKeyspaceError(w http.ResponseWriter, r *http.Request, err error) {
+	logger := logging.FromContext(r.Context(), logging.ComponentHTTP)
+	switch {
+	case errors.Is(err, context.Canceled):
+		logger.Debug("Client disconnected before the keys were read")
+	case errors.Is(err, keyspace.ErrInvalidCursor), errors.Is(err, keyspace.ErrInvalidType):
+		apierror.Write(w, apierror.New(apierror.CodeInvalidRequest, err.Error()))
+	case errors.Is(err, keyspace.ErrNotFound):
+		apierror.Write(w, apierror.New(apierror.CodeNotFound, err.Error()))
+	default:
+		logger.Warn("Failed to read keys", slog.Any("err", err))
+		apierror.Write(w, commandError(err))
+	}
+}
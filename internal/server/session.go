@@ -0,0 +1,107 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"server/internal/apierror"
+	"server/internal/audit"
+	"server/internal/logging"
+	"server/internal/session"
+	util "server/util"
+)
+
+const (
+	// PinnedSessionHeader carries the ID of the session whose pinned
+	// connection runs the commands of the request. It is distinct from the
+	// analytics session ID, which clients send on every request.
+	PinnedSessionHeader = "X-Pinned-Session"
+	// SessionPinnedHeader is set on responses to commands executed on the
+	// pinned connection of the session
+	SessionPinnedHeader = "X-Session-Pinned"
+)
+
+// SessionResponse describes the pinned connection of a session
+type SessionResponse struct {
+	SessionID string `json:"session_id"`
+	Pinned    bool   `json:"pinned"`
+}
+
+// PinSessionHandler pins a dedicated connection to a new session and returns
+// its ID. The commands sent with the ID in the X-Pinned-Session header then run on
+// this connection until the session ends or the connection is idle for too long.
+func (s *HTTPServer) PinSessionHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.sessionsEnabled(w) {
+		return
+	}
+
+	sessionID, err := s.Sessions.Pin(audit.ClientFromContext(r.Context()).IP)
+	switch {
+	case errors.Is(err, session.ErrTooManySessions):
+		apierror.Write(w, apierror.New(apierror.CodeSessionLimit, err.Error()))
+		return
+	case errors.Is(err, session.ErrTooManyClientSessions):
+		apierror.Write(w, apierror.New(apierror.CodeConflict, err.Error()).
+			WithHint("End a session with DELETE /shell/session or wait for an idle one to expire."))
+		return
+	case err != nil:
+		logging.FromContext(r.Context(), logging.ComponentHTTP).Error("Failed to pin connection", slog.Any("err", err))
+		apierror.Write(w, apierror.Internal())
+		return
+	}
+
+	util.JSONResponse(w, http.StatusCreated, SessionResponse{SessionID: sessionID, Pinned: true})
+}
+
+// UnpinSessionHandler ends the session identified by the X-Pinned-Session header,
+// returning its connection to the pool
+func (s *HTTPServer) UnpinSessionHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.sessionsEnabled(w) {
+		return
+	}
+	sessionID := r.Header.Get(PinnedSessionHeader)
+	if sessionID == "" {
+		apierror.Write(w, apierror.New(apierror.CodeInvalidRequest, "missing "+PinnedSessionHeader+" header").
+			WithHint("Send the session_id returned by POST /shell/session in the "+PinnedSessionHeader+" header."))
+		return
+	}
+
+	if err := s.Sessions.Unpin(sessionID); errors.Is(err, session.ErrNotPinned) {
+		apierror.Write(w, apierror.New(apierror.CodeNotFound, err.Error()))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sessionsEnabled reports whether connections can be pinned, writing an error
+// if pinning is disabled
+func (s *HTTPServer) sessionsEnabled(w http.ResponseWriter) bool {
+	if s.Sessions == nil {
+		apierror.Write(w, apierror.New(apierror.CodeNotFound, "session-pinned connections are disabled"))
+		return false
+	}
+	return true
+}
+
+// bindSession binds the request to the pinned connection of its session, if
+// any, waiting for earlier requests of the session to complete. It reports
+// false, writing an error in mediaType, if the session is gone, or if the
+// client went away in the meantime. release must be called once the request
+// is done.
+func (s *HTTPServer) bindSession(w http.ResponseWriter, r *http.Request, mediaType string) (*http.Request, func(), bool) {
+	ctx, pinned, release, err := s.Sessions.Bind(r.Context(), r.Header.Get(PinnedSessionHeader))
+	if errors.Is(err, session.ErrSessionExpired) {
+		writeError(w, r, mediaType, apierror.New(apierror.CodeSessionExpired, err.Error()))
+		return r, release, false
+	}
+	if err != nil {
+		logging.FromContext(r.Context(), logging.ComponentHTTP).Debug("Client disconnected before the session connection was free")
+		return r, release, false
+	}
+	if pinned {
+		r = r.WithContext(ctx)
+		w.Header().Set(SessionPinnedHeader, "true")
+	}
+	return r, release, true
+}
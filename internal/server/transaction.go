@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"server/internal/apierror"
+	"server/internal/audit"
+	"server/internal/db"
+	"server/internal/logging"
+	util "server/util"
+	"server/util/cmds"
+)
+
+const (
+	TransactionCommitted = "committed"
+	TransactionAborted   = "aborted"
+)
+
+// TransactionResponse is the outcome of a transaction. Results holds a result
+// per queued command once the transaction is committed.
+type TransactionResponse struct {
+	Status  string                     `json:"status"`
+	Reason  string                     `json:"reason,omitempty"`
+	Results []TransactionCommandResult `json:"results,omitempty"`
+}
+
+// TransactionCommandResult is the reply of a queued command, or its error
+type TransactionCommandResult struct {
+	Command string          `json:"command"`
+	Data    interface{}     `json:"data,omitempty"`
+	Error   *apierror.Error `json:"error,omitempty"`
+}
+
+// TransactionHandler executes the queued commands between MULTI and EXEC on a
+// single connection, after watching the given keys. A transaction aborted
+// because a watched key changed is a regular outcome rather than an error.
+func (s *HTTPServer) TransactionHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	logger := logging.FromContext(r.Context(), logging.ComponentHTTP)
+	watch, commands, err := util.ParseTransactionRequest(r, s.BlockCommands)
+	if err != nil {
+		s.audit(r, &cmds.CommandRequest{Cmd: "EXEC"}, audit.OutcomeRejected, err, start)
+		s.recordVisit(r, "EXEC", audit.OutcomeRejected, start)
+		apierror.Write(w, commandError(err))
+		return
+	}
+
+	// Transactions of a session with a pinned connection run on that connection
+	r, release, ok := s.bindSession(w, r, util.MediaTypeJSON)
+	if !ok {
+		return
+	}
+	defer release()
+
+	results, err := s.DiceClient.ExecuteTransaction(r.Context(), watch, commands)
+	for i, command := range commands {
+		commandErr := err
+		if commandErr == nil {
+			commandErr = results[i].Err
+		}
+		s.audit(r, command, outcomeOf(commandErr), commandErr, start)
+		s.recordVisit(r, command.Cmd, outcomeOf(commandErr), start)
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		logger.Debug("Client disconnected before transaction completed")
+		return
+	case errors.Is(err, db.ErrTransactionAborted):
+		util.JSONResponse(w, http.StatusOK, HTTPResponse{Data: TransactionResponse{
+			Status: TransactionAborted,
+			Reason: err.Error(),
+		}})
+		return
+	case err != nil:
+		logCommandError(logger, &cmds.CommandRequest{Cmd: "EXEC"}, err)
+		apierror.Write(w, commandError(err))
+		return
+	}
+
+	response := TransactionResponse{Status: TransactionCommitted, Results: make([]TransactionCommandResult, len(results))}
+	for i, result := range results {
+		response.Results[i] = TransactionCommandResult{Command: commands[i].Cmd, Data: result.Reply}
+		if result.Err != nil {
+			response.Results[i].Error = commandError(result.Err)
+		}
+	}
+	logger.Debug("Transaction committed", slog.Int("commands", len(commands)))
+	util.JSONResponse(w, http.StatusOK, HTTPResponse{Data: response})
+}
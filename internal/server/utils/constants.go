@@ -2,4 +2,10 @@ package utils
 
 const (
 	LastCronCleanupTimeUnixMs = "playground_mono:last_cron_cleanup_run_time_unix_ms"
+	IdempotencyKeyPrefix      = "playground_mono:idempotency:"
+	AuditLogKey               = "playground_mono:audit_log"
+	SlowLogKey                = "playground_mono:slow_log"
+	AnalyticsKeyPrefix        = "playground_mono:analytics:"
+	PresenceKeyPrefix         = "playground_mono:presence:"
+	ResponseCursorKeyPrefix   = "playground_mono:response_cursor:"
 )
@@ -0,0 +1,298 @@
+// Package session pins dedicated DiceDB connections to playground sessions, so
+// that connection-scoped state such as the selected database or the client
+// name stays consistent across the requests of a session.
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"server/internal/db"
+	"server/internal/logging"
+	"server/internal/metrics"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrTooManySessions is returned when every pinned connection is in use
+	ErrTooManySessions = errors.New("too many sessions with a pinned connection, retry later")
+	// ErrTooManyClientSessions is returned when a client holds as many pinned
+	// connections as it may
+	ErrTooManyClientSessions = errors.New("too many pinned connections for this client, end a session first")
+	// ErrNotPinned is returned when ending a session without a pinned connection
+	ErrNotPinned = errors.New("session has no pinned connection")
+	// ErrSessionExpired is returned when binding a request to a session that
+	// ended, was evicted or was never pinned
+	ErrSessionExpired = errors.New("session has no pinned connection, it ended or was evicted")
+)
+
+// Manager hands out at most maxConns pinned connections, one per session and
+// at most maxClientConns per client. Connections idle for longer than
+// idleTimeout are returned to the pool.
+type Manager struct {
+	client         *db.DiceDB
+	maxConns       int
+	maxClientConns int
+	idleTimeout    time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]*pinned
+}
+
+// pinned is the connection of a session. sem serializes the requests of the
+// session, a connection must not be used concurrently.
+type pinned struct {
+	conn     *db.PinnedConn
+	clientIP string
+	sem      chan struct{}
+	lastUsed time.Time
+	ended    bool // Set once the session is removed, waiting requests fail with ErrSessionExpired
+}
+
+// New creates a manager pinning at most maxConns connections of the client,
+// and at most maxClientConns for a single client IP. It returns nil if
+// maxConns is not positive.
+func New(client *db.DiceDB, maxConns, maxClientConns int, idleTimeout time.Duration) *Manager {
+	if maxConns <= 0 {
+		return nil
+	}
+
+	return &Manager{
+		client:         client,
+		maxConns:       maxConns,
+		maxClientConns: maxClientConns,
+		idleTimeout:    idleTimeout,
+		sessions:       make(map[string]*pinned),
+	}
+}
+
+// Pin pins a connection to a new session of the client and returns its ID.
+// Idle connections are evicted to make room when a limit is reached.
+func (m *Manager) Pin(clientIP string) (string, error) {
+	sessionID, err := newSessionID()
+	if err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	var evicted map[string]*pinned
+	if len(m.sessions) >= m.maxConns || m.clientConnsLocked(clientIP) >= m.maxClientConns {
+		evicted = m.evictLocked(time.Now().Add(-m.idleTimeout))
+	}
+	switch {
+	case len(m.sessions) >= m.maxConns:
+		err = ErrTooManySessions
+	case m.clientConnsLocked(clientIP) >= m.maxClientConns:
+		err = ErrTooManyClientSessions
+	default:
+		m.sessions[sessionID] = &pinned{
+			conn:     m.client.PinConn(),
+			clientIP: clientIP,
+			sem:      make(chan struct{}, 1),
+			lastUsed: time.Now(),
+		}
+		metrics.SetPinnedConnections(len(m.sessions))
+	}
+	m.mu.Unlock()
+
+	m.closeEvicted(evicted)
+	if err != nil {
+		return "", err
+	}
+	return sessionID, nil
+}
+
+// Unpin ends the session. Its connection returns to the pool once the request
+// using it completes.
+func (m *Manager) Unpin(sessionID string) error {
+	m.mu.Lock()
+	p, ok := m.sessions[sessionID]
+	if ok {
+		m.removeLocked(sessionID, p)
+	}
+	m.mu.Unlock()
+	if !ok {
+		return ErrNotPinned
+	}
+
+	go m.end(sessionID, p, metrics.PinnedConnEnded)
+	return nil
+}
+
+// Bind returns a context executing the commands of the session on its pinned
+// connection, waiting for earlier requests of the session to complete. It
+// reports false and returns ctx as is for requests without a session ID, their
+// commands use pooled connections. Unknown sessions fail with
+// ErrSessionExpired rather than silently losing their connection state.
+// release must be called once the request is done with the connection.
+func (m *Manager) Bind(ctx context.Context, sessionID string) (context.Context, bool, func(), error) {
+	noop := func() {}
+	if m == nil || sessionID == "" {
+		return ctx, false, noop, nil
+	}
+
+	m.mu.Lock()
+	p, ok := m.sessions[sessionID]
+	m.mu.Unlock()
+	if !ok {
+		return ctx, false, noop, ErrSessionExpired
+	}
+
+	select {
+	case p.sem <- struct{}{}:
+	case <-ctx.Done():
+		return ctx, false, noop, ctx.Err()
+	}
+
+	m.mu.Lock()
+	if p.ended {
+		// The session ended while waiting for the connection
+		m.mu.Unlock()
+		<-p.sem
+		return ctx, false, noop, ErrSessionExpired
+	}
+	p.lastUsed = time.Now()
+	m.mu.Unlock()
+
+	return db.WithPinnedConn(ctx, p.conn), true, func() { m.release(sessionID, p) }, nil
+}
+
+// release hands the connection to the next request of the session. A broken
+// connection lost its state, the session ends rather than silently continuing
+// on a new connection.
+func (m *Manager) release(sessionID string, p *pinned) {
+	m.mu.Lock()
+	broken := p.conn.Broken() && !p.ended
+	if broken {
+		m.removeLocked(sessionID, p)
+	}
+	m.mu.Unlock()
+
+	if broken {
+		logging.Component(logging.ComponentSession).Info("Ending session with broken connection",
+			slog.String("session_id", sessionID))
+		m.close(sessionID, p.conn, metrics.PinnedConnBroken)
+	}
+	<-p.sem
+}
+
+// Run evicts idle connections until ctx is done, then closes every pinned
+// connection
+func (m *Manager) Run(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	ticker := time.NewTicker(max(m.idleTimeout/2, time.Second))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.mu.Lock()
+			evicted := m.evictLocked(time.Now().Add(-m.idleTimeout))
+			m.mu.Unlock()
+			m.closeEvicted(evicted)
+		case <-ctx.Done():
+			m.closeAll()
+			logging.Component(logging.ComponentSession).Info("Shutting down session manager")
+			return
+		}
+	}
+}
+
+// Len returns the number of pinned connections
+func (m *Manager) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.sessions)
+}
+
+// clientConnsLocked returns the number of connections pinned by the client
+func (m *Manager) clientConnsLocked(clientIP string) int {
+	n := 0
+	for _, p := range m.sessions {
+		if p.clientIP == clientIP {
+			n++
+		}
+	}
+	return n
+}
+
+// evictLocked removes the sessions last used before idleSince and returns
+// them, holding their connection, for closeEvicted to close once m.mu is
+// released. Connections in use by a request are not idle and are kept.
+func (m *Manager) evictLocked(idleSince time.Time) map[string]*pinned {
+	evicted := make(map[string]*pinned)
+	for sessionID, p := range m.sessions {
+		if !p.lastUsed.Before(idleSince) {
+			continue
+		}
+		select {
+		case p.sem <- struct{}{}:
+		default:
+			continue
+		}
+		m.removeLocked(sessionID, p)
+		evicted[sessionID] = p
+	}
+	return evicted
+}
+
+// closeEvicted closes the connections of the sessions returned by evictLocked
+func (m *Manager) closeEvicted(evicted map[string]*pinned) {
+	for sessionID, p := range evicted {
+		m.close(sessionID, p.conn, metrics.PinnedConnIdle)
+		<-p.sem
+	}
+}
+
+// removeLocked removes the session, requests waiting for its connection fail
+// with ErrSessionExpired
+func (m *Manager) removeLocked(sessionID string, p *pinned) {
+	p.ended = true
+	delete(m.sessions, sessionID)
+	metrics.SetPinnedConnections(len(m.sessions))
+}
+
+// end closes the connection of a removed session once the request using it
+// completes
+func (m *Manager) end(sessionID string, p *pinned, reason string) {
+	p.sem <- struct{}{}
+	m.close(sessionID, p.conn, reason)
+	<-p.sem
+}
+
+// closeAll ends every session. It is called once the HTTP server drained all
+// requests, no connection is in use.
+func (m *Manager) closeAll() {
+	m.mu.Lock()
+	sessions := make(map[string]*pinned, len(m.sessions))
+	for sessionID, p := range m.sessions {
+		sessions[sessionID] = p
+		m.removeLocked(sessionID, p)
+	}
+	m.mu.Unlock()
+
+	for sessionID, p := range sessions {
+		m.end(sessionID, p, metrics.PinnedConnShutdown)
+	}
+}
+
+// newSessionID returns a random session ID, unguessable so that a session
+// cannot be used by other clients
+func newSessionID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func (m *Manager) close(sessionID string, conn *db.PinnedConn, reason string) {
+	metrics.ObservePinnedConnectionClosed(reason)
+	if err := conn.Close(); err != nil {
+		logging.Component(logging.ComponentSession).Debug("Failed to close pinned connection",
+			slog.String("session_id", sessionID), slog.String("reason", reason), slog.Any("err", err))
+	}
+}
@@ -0,0 +1,176 @@
+// Package slowlog keeps the most recent commands that exceeded a latency
+// threshold in a capped list in the admin DiceDB, along with the breakdown of
+// where the time was spent.
+package slowlog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"server/internal/db"
+	"server/internal/logging"
+	"sync"
+	"time"
+)
+
+const (
+	// writeTimeout bounds the write of a single entry
+	writeTimeout = 5 * time.Second
+	// maxPendingWrites bounds the number of entries being written at once,
+	// entries are dropped beyond it so that a slow admin DiceDB never piles up goroutines
+	maxPendingWrites = 64
+)
+
+// Entry is a single slow command. Argument values are never recorded, only
+// their number and the key names.
+type Entry struct {
+	Timestamp  time.Time          `json:"timestamp"`
+	RequestID  string             `json:"request_id,omitempty"`
+	Command    string             `json:"command"`
+	ArgCount   int                `json:"arg_count"`
+	Keys       []string           `json:"keys,omitempty"`
+	Outcome    string             `json:"outcome"`
+	DurationMs float64            `json:"duration_ms"`
+	Timings    map[string]float64 `json:"timings,omitempty"`
+}
+
+// Log records slow commands. A nil Log is valid and records nothing.
+type Log struct {
+	client     *db.DiceDB
+	key        string
+	threshold  time.Duration
+	maxEntries int64
+	pending    chan struct{}
+
+	mu     sync.Mutex
+	closed bool
+	writes sync.WaitGroup
+}
+
+// New creates a slow log storing up to maxEntries commands slower than
+// threshold in the list at key. It returns nil if threshold is not positive.
+func New(client *db.DiceDB, key string, threshold time.Duration, maxEntries int64) *Log {
+	if threshold <= 0 || maxEntries <= 0 {
+		return nil
+	}
+
+	return &Log{
+		client:     client,
+		key:        key,
+		threshold:  threshold,
+		maxEntries: maxEntries,
+		pending:    make(chan struct{}, maxPendingWrites),
+	}
+}
+
+// Threshold returns the duration above which commands are recorded
+func (l *Log) Threshold() time.Duration {
+	if l == nil {
+		return 0
+	}
+	return l.threshold
+}
+
+// Record stores the entry in the background if the command took longer than
+// the threshold. It never blocks the caller.
+func (l *Log) Record(ctx context.Context, duration time.Duration, entry Entry) {
+	if l == nil || duration < l.threshold {
+		return
+	}
+	entry.DurationMs = float64(duration.Microseconds()) / 1000
+
+	logger := logging.FromContext(ctx, logging.ComponentHTTP)
+	select {
+	case l.pending <- struct{}{}:
+	default:
+		logger.Warn("Dropping slow log entry, too many pending writes", slog.String("cmd", entry.Command))
+		return
+	}
+
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		<-l.pending
+		logger.Warn("Dropping slow log entry, the slow log is closed", slog.String("cmd", entry.Command))
+		return
+	}
+	l.writes.Add(1)
+	l.mu.Unlock()
+
+	// The entry outlives the request, the write must not be cancelled with it
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		defer l.writes.Done()
+		defer func() { <-l.pending }()
+
+		writeCtx, cancel := context.WithTimeout(ctx, writeTimeout)
+		defer cancel()
+		if err := l.write(writeCtx, entry); err != nil {
+			logger.Error("Failed to write slow log entry", slog.String("cmd", entry.Command), slog.Any("err", err))
+		}
+	}()
+}
+
+// Close stops recording entries and waits for the pending writes. It gives up
+// waiting once ctx is done.
+func (l *Log) Close(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	l.closed = true
+	l.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		l.writes.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *Log) write(ctx context.Context, entry Entry) error {
+	data, err := json.Marshal(&entry)
+	if err != nil {
+		return err
+	}
+
+	if err := l.client.Client.LPush(ctx, l.key, string(data)).Err(); err != nil {
+		return fmt.Errorf("failed to push slow log entry: %w", err)
+	}
+	if err := l.client.Client.LTrim(ctx, l.key, 0, l.maxEntries-1).Err(); err != nil {
+		return fmt.Errorf("failed to trim slow log: %w", err)
+	}
+	return nil
+}
+
+// Entries returns up to limit of the most recent slow commands, newest first
+func (l *Log) Entries(ctx context.Context, limit int64) ([]Entry, error) {
+	if l == nil {
+		return []Entry{}, nil
+	}
+
+	values, err := l.client.Client.LRange(ctx, l.key, 0, limit-1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read slow log: %w", err)
+	}
+
+	entries := make([]Entry, 0, len(values))
+	for _, value := range values {
+		var entry Entry
+		if err := json.Unmarshal([]byte(value), &entry); err != nil {
+			logging.FromContext(ctx, logging.ComponentHTTP).Warn("Skipping malformed slow log entry", slog.Any("err", err))
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
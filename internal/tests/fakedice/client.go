@@ -0,0 +1,25 @@
+package fakedice
+
+import "strings"
+
+// client handles CLIENT SETNAME and CLIENT GETNAME with the name of the
+// connection. It reports false for other commands.
+func client(name *string, cmd string, args []string) (Reply, bool) {
+	if cmd != "CLIENT" || len(args) == 0 {
+		return "", false
+	}
+	switch strings.ToUpper(args[0]) {
+	case "SETNAME":
+		if len(args) != 2 {
+			return wrongArity("CLIENT|SETNAME"), true
+		}
+		*name = args[1]
+		return SimpleString("OK"), true
+	case "GETNAME":
+		if *name == "" {
+			return Nil(), true
+		}
+		return BulkString(*name), true
+	}
+	return "", false
+}
@@ -0,0 +1,53 @@
+package fakedice
+
+import (
+	"server/config"
+	"server/internal/db"
+	"testing"
+)
+
+// Clients are the admin and user DiceDB clients of a test, each connected to
+// its own fake server
+type Clients struct {
+	Config    *config.Config
+	Admin     *db.DiceDB
+	User      *db.DiceDB
+	AdminFake *Server
+	UserFake  *Server
+}
+
+// NewClients starts a fake server for the admin and for the user DiceDB and
+// connects clients configured from the environment to them. Everything is
+// closed when the test ends.
+func NewClients(t testing.TB) *Clients {
+	t.Helper()
+
+	c := &Clients{Config: config.LoadConfig(), AdminFake: start(t), UserFake: start(t)}
+	c.Config.DiceDBAdmin.Addr = c.AdminFake.Addr()
+	c.Config.DiceDB.Addr = c.UserFake.Addr()
+	c.Admin = connect(t, c.Config, true)
+	c.User = connect(t, c.Config, false)
+	return c
+}
+
+func start(t testing.TB) *Server {
+	t.Helper()
+
+	fake, err := NewServer()
+	if err != nil {
+		t.Fatalf("failed to start fake DiceDB: %v", err)
+	}
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+func connect(t testing.TB, configValue *config.Config, isAdmin bool) *db.DiceDB {
+	t.Helper()
+
+	client, err := db.InitDiceClient(configValue, isAdmin)
+	if err != nil {
+		t.Fatalf("failed to connect to fake DiceDB: %v", err)
+	}
+	t.Cleanup(client.CloseDiceDB)
+	return client
+}
@@ -0,0 +1,79 @@
+package fakedice
+
+import (
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// keys returns every key in lexical order
+func (s *Server) keys() []string {
+	var keys []string
+	seen := make(map[string]struct{})
+	add := func(key string) {
+		if _, ok := seen[key]; !ok {
+			seen[key] = struct{}{}
+			keys = append(keys, key)
+		}
+	}
+	for key := range s.data {
+		add(key)
+	}
+	for key := range s.lists {
+		add(key)
+	}
+	for key := range s.hashes {
+		add(key)
+	}
+	for key := range s.hlls {
+		add(key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// scan pages through the keys in lexical order, the cursor being the index of
+// the next key. MATCH, COUNT and TYPE are supported.
+func (s *Server) scan(args []string) Reply {
+	if len(args) == 0 || len(args)%2 != 1 {
+		return wrongArity("SCAN")
+	}
+	cursor, err := strconv.Atoi(args[0])
+	if err != nil || cursor < 0 {
+		return Error("ERR invalid cursor")
+	}
+	pattern, keyType, count := "*", "", 10
+	for i := 1; i < len(args); i += 2 {
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			pattern = args[i+1]
+		case "COUNT":
+			if count, err = strconv.Atoi(args[i+1]); err != nil || count <= 0 {
+				return Error("ERR syntax error")
+			}
+		case "TYPE":
+			keyType = strings.ToLower(args[i+1])
+		default:
+			return Error("ERR syntax error")
+		}
+	}
+
+	keys := s.keys()
+	end := min(cursor+count, len(keys))
+	items := []Reply{}
+	for i := min(cursor, end); i < end; i++ {
+		if matched, _ := path.Match(pattern, keys[i]); !matched {
+			continue
+		}
+		if keyType != "" && s.typeOf(keys[i]) != keyType {
+			continue
+		}
+		items = append(items, BulkString(keys[i]))
+	}
+	next := end
+	if next >= len(keys) {
+		next = 0
+	}
+	return Array(BulkString(strconv.Itoa(next)), Array(items...))
+}
@@ -0,0 +1,648 @@
+// Package fakedice provides a minimal in-process DiceDB server speaking RESP2.
+// It is meant for tests that need a real TCP backend without running a container,
+// and allows injecting latency and dropped connections per command.
+package fakedice
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Reply is a RESP2 encoded reply written back to the client as is
+type Reply string
+
+// SimpleString encodes a RESP2 simple string reply
+func SimpleString(s string) Reply { return Reply("+" + s + "\r\n") }
+
+// Error encodes a RESP2 error reply
+func Error(msg string) Reply { return Reply("-" + msg + "\r\n") }
+
+// Integer encodes a RESP2 integer reply
+func Integer(n int64) Reply { return Reply(":" + strconv.FormatInt(n, 10) + "\r\n") }
+
+// BulkString encodes a RESP2 bulk string reply
+func BulkString(s string) Reply { return Reply("$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n") }
+
+// Nil encodes a RESP2 nil bulk string reply
+func Nil() Reply { return Reply("$-1\r\n") }
+
+// Array encodes a RESP2 array reply out of already encoded items
+func Array(items ...Reply) Reply {
+	var b strings.Builder
+	b.WriteString("*" + strconv.Itoa(len(items)) + "\r\n")
+	for _, item := range items {
+		b.WriteString(string(item))
+	}
+	return Reply(b.String())
+}
+
+// HandlerFunc computes the reply of a command given its arguments (excluding the command name)
+type HandlerFunc func(args []string) Reply
+
+// Server is a fake DiceDB server. The zero value is not usable, use NewServer or NewServerAt.
+type Server struct {
+	listener net.Listener
+
+	// execMu is held exclusively while a transaction executes, so that it does
+	// not interleave with other commands
+	execMu sync.RWMutex
+
+	mu          sync.Mutex
+	data        map[string]string
+	lists       map[string][]string
+	hashes      map[string]map[string]string
+	hlls        map[string]map[string]struct{} // HyperLogLogs are exact sets in the fake
+	versions    map[string]uint64              // Bumped on every write to a key, for WATCH
+	flushes     uint64
+	handlers    map[string]HandlerFunc
+	delays      map[string]time.Duration
+	drops       map[string]int
+	lostReplies map[string]int
+	calls       map[string]int
+	conns       map[net.Conn]struct{}
+	closed      bool
+	done        chan struct{}
+
+	wg sync.WaitGroup
+}
+
+// NewServer starts a fake server on a random local port
+func NewServer() (*Server, error) {
+	return NewServerAt("127.0.0.1:0")
+}
+
+// NewServerAt starts a fake server listening on the given address
+func NewServerAt(addr string) (*Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start fake DiceDB server: %w", err)
+	}
+
+	s := &Server{
+		listener:    listener,
+		data:        make(map[string]string),
+		lists:       make(map[string][]string),
+		hashes:      make(map[string]map[string]string),
+		hlls:        make(map[string]map[string]struct{}),
+		versions:    make(map[string]uint64),
+		handlers:    make(map[string]HandlerFunc),
+		delays:      make(map[string]time.Duration),
+		drops:       make(map[string]int),
+		lostReplies: make(map[string]int),
+		calls:       make(map[string]int),
+		conns:       make(map[net.Conn]struct{}),
+		done:        make(chan struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.acceptLoop()
+	return s, nil
+}
+
+// Addr returns the address the server is listening on
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops the server and closes all open client connections
+func (s *Server) Close() {
+	s.mu.Lock()
+	if !s.closed {
+		close(s.done)
+	}
+	s.closed = true
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.listener.Close()
+	s.wg.Wait()
+}
+
+// Handle overrides the reply of a command
+func (s *Server) Handle(cmd string, fn HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[strings.ToUpper(cmd)] = fn
+}
+
+// SetDelay delays the reply of a command by the given duration
+func (s *Server) SetDelay(cmd string, delay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delays[strings.ToUpper(cmd)] = delay
+}
+
+// DropConnections makes the server close the connection instead of replying
+// the next n times the command is received.
+func (s *Server) DropConnections(cmd string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drops[strings.ToUpper(cmd)] = n
+}
+
+// DropReplies makes the server execute the command but close the connection
+// instead of replying the next n times it is received, simulating a write that
+// was applied while the reply was lost.
+func (s *Server) DropReplies(cmd string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lostReplies[strings.ToUpper(cmd)] = n
+}
+
+// Calls returns the number of times the command was received, including dropped ones
+func (s *Server) Calls(cmd string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[strings.ToUpper(cmd)]
+}
+
+// Value returns the raw value stored for a key by the built-in string commands
+func (s *Server) Value(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	val, ok := s.data[key]
+	return val, ok
+}
+
+// Hash returns a copy of the hash stored for a key by the built-in hash commands
+func (s *Server) Hash(key string) map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hash := make(map[string]string, len(s.hashes[key]))
+	for field, val := range s.hashes[key] {
+		hash[field] = val
+	}
+	return hash
+}
+
+// Exists reports whether a key holds a value of any type
+func (s *Server) Exists(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.exists(key)
+}
+
+func (s *Server) exists(key string) bool {
+	return s.typeOf(key) != "none"
+}
+
+// typeOf returns the type of the value held by a key as reported by TYPE,
+// HyperLogLogs being strings
+func (s *Server) typeOf(key string) string {
+	if _, ok := s.lists[key]; ok {
+		return "list"
+	}
+	if _, ok := s.hashes[key]; ok {
+		return "hash"
+	}
+	_, isString := s.data[key]
+	_, isHLL := s.hlls[key]
+	if isString || isHLL {
+		return "string"
+	}
+	return "none"
+}
+
+// List returns a copy of the list stored for a key by the built-in list commands
+func (s *Server) List(key string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.lists[key]...)
+}
+
+func (s *Server) acceptLoop() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.serve(conn)
+	}
+}
+
+func (s *Server) serve(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	reader := bufio.NewReader(conn)
+	tx := &transaction{}
+	name := ""
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		cmd := strings.ToUpper(args[0])
+		s.mu.Lock()
+		s.calls[cmd]++
+		delay := s.delays[cmd]
+		drop := s.drops[cmd] > 0
+		if drop {
+			s.drops[cmd]--
+		}
+		loseReply := s.lostReplies[cmd] > 0
+		if loseReply {
+			s.lostReplies[cmd]--
+		}
+		s.mu.Unlock()
+
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-s.done:
+				return
+			}
+		}
+		if drop {
+			return
+		}
+
+		// Outside of a transaction overridden commands are executed right away,
+		// even connection and transaction commands
+		s.mu.Lock()
+		_, overridden := s.handlers[cmd]
+		s.mu.Unlock()
+		reply, handled := Reply(""), false
+		if !overridden || tx.active {
+			reply, handled = s.transact(tx, &name, cmd, args[1:])
+			if !handled {
+				reply, handled = client(&name, cmd, args[1:])
+			}
+		}
+		if !handled {
+			s.execMu.RLock()
+			reply = s.execute(cmd, args[1:])
+			s.touch(cmd, args[1:])
+			s.execMu.RUnlock()
+		}
+		if loseReply {
+			return
+		}
+		if _, err := io.WriteString(conn, string(reply)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) execute(cmd string, args []string) Reply {
+	s.mu.Lock()
+	handler, ok := s.handlers[cmd]
+	s.mu.Unlock()
+	if ok {
+		return handler(args)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch cmd {
+	case "HELLO":
+		// Force clients to fall back to RESP2
+		return Error("ERR unknown command 'HELLO'")
+	case "PING":
+		return SimpleString("PONG")
+	case "AUTH", "CLIENT", "SELECT":
+		return SimpleString("OK")
+	case "FLUSHDB":
+		s.data = make(map[string]string)
+		s.lists = make(map[string][]string)
+		s.hashes = make(map[string]map[string]string)
+		s.hlls = make(map[string]map[string]struct{})
+		return SimpleString("OK")
+	case "GET":
+		if len(args) != 1 {
+			return wrongArity(cmd)
+		}
+		if val, exists := s.data[args[0]]; exists {
+			return BulkString(val)
+		}
+		return Nil()
+	case "STRLEN":
+		if len(args) != 1 {
+			return wrongArity(cmd)
+		}
+		return Integer(int64(len(s.data[args[0]])))
+	case "GETRANGE":
+		if len(args) != 3 {
+			return wrongArity(cmd)
+		}
+		start, err1 := strconv.Atoi(args[1])
+		end, err2 := strconv.Atoi(args[2])
+		if err1 != nil || err2 != nil {
+			return Error("ERR value is not an integer or out of range")
+		}
+		return BulkString(getRange(s.data[args[0]], start, end))
+	case "SET":
+		if len(args) < 2 {
+			return wrongArity(cmd)
+		}
+		exists := s.exists(args[0])
+		for _, opt := range args[2:] {
+			switch strings.ToUpper(opt) {
+			case "NX":
+				if exists {
+					return Nil()
+				}
+			case "XX":
+				if !exists {
+					return Nil()
+				}
+			}
+		}
+		s.data[args[0]] = args[1]
+		return SimpleString("OK")
+	case "DEL":
+		var deleted int64
+		for _, key := range args {
+			if s.exists(key) {
+				delete(s.data, key)
+				delete(s.lists, key)
+				delete(s.hashes, key)
+				delete(s.hlls, key)
+				deleted++
+			}
+		}
+		return Integer(deleted)
+	case "INCR", "INCRBY":
+		incr := int64(1)
+		if cmd == "INCRBY" {
+			if len(args) != 2 {
+				return wrongArity(cmd)
+			}
+			var err error
+			if incr, err = strconv.ParseInt(args[1], 10, 64); err != nil {
+				return Error("ERR value is not an integer or out of range")
+			}
+		} else if len(args) != 1 {
+			return wrongArity(cmd)
+		}
+		var n int64
+		if val, exists := s.data[args[0]]; exists {
+			var err error
+			if n, err = strconv.ParseInt(val, 10, 64); err != nil {
+				return Error("ERR value is not an integer or out of range")
+			}
+		}
+		n += incr
+		s.data[args[0]] = strconv.FormatInt(n, 10)
+		return Integer(n)
+	case "EXPIRE":
+		if len(args) < 2 {
+			return wrongArity(cmd)
+		}
+		if s.exists(args[0]) {
+			return Integer(1)
+		}
+		return Integer(0)
+	case "EXISTS":
+		var count int64
+		for _, key := range args {
+			if s.exists(key) {
+				count++
+			}
+		}
+		return Integer(count)
+	case "TYPE":
+		if len(args) != 1 {
+			return wrongArity(cmd)
+		}
+		return SimpleString(s.typeOf(args[0]))
+	case "TTL", "PTTL":
+		// Expiries are not tracked, keys never expire
+		if len(args) != 1 {
+			return wrongArity(cmd)
+		}
+		if s.exists(args[0]) {
+			return Integer(-1)
+		}
+		return Integer(-2)
+	case "SCAN":
+		return s.scan(args)
+	case "HINCRBY":
+		if len(args) != 3 {
+			return wrongArity(cmd)
+		}
+		incr, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			return Error("ERR value is not an integer or out of range")
+		}
+		hash, exists := s.hashes[args[0]]
+		if !exists {
+			hash = make(map[string]string)
+			s.hashes[args[0]] = hash
+		}
+		var n int64
+		if val, exists := hash[args[1]]; exists {
+			if n, err = strconv.ParseInt(val, 10, 64); err != nil {
+				return Error("ERR hash value is not an integer")
+			}
+		}
+		n += incr
+		hash[args[1]] = strconv.FormatInt(n, 10)
+		return Integer(n)
+	case "HGETALL":
+		if len(args) != 1 {
+			return wrongArity(cmd)
+		}
+		items := []Reply{}
+		for field, val := range s.hashes[args[0]] {
+			items = append(items, BulkString(field), BulkString(val))
+		}
+		return Array(items...)
+	case "HLEN":
+		if len(args) != 1 {
+			return wrongArity(cmd)
+		}
+		return Integer(int64(len(s.hashes[args[0]])))
+	case "PFADD":
+		if len(args) < 1 {
+			return wrongArity(cmd)
+		}
+		hll, exists := s.hlls[args[0]]
+		if !exists {
+			hll = make(map[string]struct{})
+			s.hlls[args[0]] = hll
+		}
+		var changed int64
+		if !exists {
+			changed = 1
+		}
+		for _, val := range args[1:] {
+			if _, seen := hll[val]; !seen {
+				hll[val] = struct{}{}
+				changed = 1
+			}
+		}
+		return Integer(changed)
+	case "PFCOUNT":
+		if len(args) < 1 {
+			return wrongArity(cmd)
+		}
+		union := make(map[string]struct{})
+		for _, key := range args {
+			if _, isString := s.data[key]; isString {
+				return Error("WRONGTYPE Key is not a valid HyperLogLog string value.")
+			}
+			for val := range s.hlls[key] {
+				union[val] = struct{}{}
+			}
+		}
+		return Integer(int64(len(union)))
+	case "LPUSH":
+		if len(args) < 2 {
+			return wrongArity(cmd)
+		}
+		if t := s.typeOf(args[0]); t != "none" && t != "list" {
+			return Error(wrongType)
+		}
+		list := s.lists[args[0]]
+		for _, val := range args[1:] {
+			list = append([]string{val}, list...)
+		}
+		s.lists[args[0]] = list
+		return Integer(int64(len(list)))
+	case "LLEN":
+		if len(args) != 1 {
+			return wrongArity(cmd)
+		}
+		return Integer(int64(len(s.lists[args[0]])))
+	case "LRANGE", "LTRIM":
+		if len(args) != 3 {
+			return wrongArity(cmd)
+		}
+		start, errStart := strconv.Atoi(args[1])
+		stop, errStop := strconv.Atoi(args[2])
+		if errStart != nil || errStop != nil {
+			return Error("ERR value is not an integer or out of range")
+		}
+		list := s.lists[args[0]]
+		start, stop = listRange(start, stop, len(list))
+		if cmd == "LTRIM" {
+			if start > stop {
+				delete(s.lists, args[0])
+			} else {
+				s.lists[args[0]] = append([]string(nil), list[start:stop+1]...)
+			}
+			return SimpleString("OK")
+		}
+		items := []Reply{}
+		for i := start; i <= stop; i++ {
+			items = append(items, BulkString(list[i]))
+		}
+		return Array(items...)
+	default:
+		return Error(fmt.Sprintf("ERR unknown command '%s'", strings.ToLower(cmd)))
+	}
+}
+
+// wrongType is the error replied when a command does not apply to the type of a key
+const wrongType = "WRONGTYPE Operation against a key holding the wrong kind of value"
+
+// listRange normalizes inclusive list indexes, negative ones counting from the
+// end. The range is empty when start > stop.
+func listRange(start, stop, length int) (int, int) {
+	if start < 0 {
+		start = max(start+length, 0)
+	}
+	if stop < 0 {
+		stop += length
+	}
+	return start, min(stop, length-1)
+}
+
+func wrongArity(cmd string) Reply {
+	return Error(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(cmd)))
+}
+
+// readCommand reads a RESP2 array of bulk strings
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := readLine(reader)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, errors.New("expected RESP array")
+	}
+
+	count, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		header, err := readLine(reader)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(header, "$") {
+			return nil, errors.New("expected RESP bulk string")
+		}
+
+		size, err := strconv.Atoi(header[1:])
+		if err != nil {
+			return nil, err
+		}
+
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
+
+func readLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// getRange returns the substring between the inclusive offsets start and end,
+// negative offsets counting from the end of the string
+func getRange(val string, start, end int) string {
+	if start < 0 {
+		start = max(len(val)+start, 0)
+	}
+	if end < 0 {
+		end = len(val) + end
+	}
+	end = min(end, len(val)-1)
+	if start > end {
+		return ""
+	}
+	return val[start : end+1]
+}
@@ -0,0 +1,126 @@
+package fakedice
+
+import "strings"
+
+// transaction is the MULTI/EXEC state of a connection
+type transaction struct {
+	active  bool
+	queued  [][]string
+	watched map[string]uint64 // Version of each watched key when it was watched
+	flushes uint64            // Number of FLUSHDB calls when the first key was watched
+}
+
+// writeCommands are the built-in commands changing keys, mapped to whether
+// every argument is a key rather than only the first one
+var writeCommands = map[string]bool{
+	"SET": false, "DEL": true, "INCR": false, "INCRBY": false, "EXPIRE": false, "HINCRBY": false, "PFADD": false,
+	"LPUSH": false, "LTRIM": false,
+}
+
+// touch bumps the version of the keys changed by a command, failing the
+// transactions watching them
+func (s *Server) touch(cmd string, args []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cmd == "FLUSHDB" {
+		s.flushes++
+		return
+	}
+
+	allKeys, ok := writeCommands[cmd]
+	if !ok || len(args) == 0 {
+		return
+	}
+	keys := args[:1]
+	if allKeys {
+		keys = args
+	}
+	for _, key := range keys {
+		s.versions[key]++
+	}
+}
+
+// transact handles the transaction commands and queues commands sent after
+// MULTI. It reports false for commands that are executed right away.
+func (s *Server) transact(tx *transaction, name *string, cmd string, args []string) (Reply, bool) {
+	switch cmd {
+	case "MULTI":
+		if tx.active {
+			return Error("ERR MULTI calls can not be nested"), true
+		}
+		tx.active = true
+		return SimpleString("OK"), true
+	case "WATCH":
+		if tx.active {
+			return Error("ERR WATCH inside MULTI is not allowed"), true
+		}
+		if len(args) == 0 {
+			return wrongArity(cmd), true
+		}
+		s.mu.Lock()
+		if tx.watched == nil {
+			tx.watched = make(map[string]uint64)
+			tx.flushes = s.flushes
+		}
+		for _, key := range args {
+			if _, ok := tx.watched[key]; !ok {
+				tx.watched[key] = s.versions[key]
+			}
+		}
+		s.mu.Unlock()
+		return SimpleString("OK"), true
+	case "UNWATCH":
+		tx.watched = nil
+		return SimpleString("OK"), true
+	case "DISCARD":
+		if !tx.active {
+			return Error("ERR DISCARD without MULTI"), true
+		}
+		*tx = transaction{}
+		return SimpleString("OK"), true
+	case "EXEC":
+		if !tx.active {
+			return Error("ERR EXEC without MULTI"), true
+		}
+		return s.exec(tx, name), true
+	}
+
+	if !tx.active {
+		return "", false
+	}
+	tx.queued = append(tx.queued, append([]string{cmd}, args...))
+	return SimpleString("QUEUED"), true
+}
+
+// exec runs the queued commands without other commands interleaving, or
+// replies with a nil array if a watched key changed. name is the client name
+// of the connection.
+func (s *Server) exec(tx *transaction, name *string) Reply {
+	queued, watched, flushes := tx.queued, tx.watched, tx.flushes
+	*tx = transaction{}
+
+	s.execMu.Lock()
+	defer s.execMu.Unlock()
+
+	s.mu.Lock()
+	changed := watched != nil && flushes != s.flushes
+	for key, version := range watched {
+		changed = changed || s.versions[key] != version
+	}
+	s.mu.Unlock()
+	if changed {
+		return Reply("*-1\r\n")
+	}
+
+	replies := make([]Reply, 0, len(queued))
+	for _, args := range queued {
+		cmd := strings.ToUpper(args[0])
+		if reply, handled := client(name, cmd, args[1:]); handled {
+			replies = append(replies, reply)
+			continue
+		}
+		replies = append(replies, s.execute(cmd, args[1:]))
+		s.touch(cmd, args[1:])
+	}
+	return Array(replies...)
+}
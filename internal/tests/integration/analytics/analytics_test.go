@@ -0,0 +1,182 @@
+package analytics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"server/internal/analytics"
+	"server/internal/middleware"
+	"server/internal/server"
+	"server/internal/server/utils"
+	"server/internal/tests/fakedice"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const adminToken = "s3cret"
+
+type fixture struct {
+	router    *gin.Engine
+	recorder  *analytics.Recorder
+	adminFake *fakedice.Server
+}
+
+func setup(t *testing.T) *fixture {
+	clients := fakedice.NewClients(t)
+
+	recorder := analytics.New(clients.Admin, time.Hour, time.Hour, 24*time.Hour)
+	httpServer := &server.HTTPServer{DiceClient: clients.User, Analytics: recorder}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.RequestLoggerMiddleware)
+	router.POST("/shell/exec/:cmd", gin.WrapF(httpServer.CliHandler))
+	adminGroup := router.Group("/admin", middleware.NewAdminAuthMiddleware(adminToken))
+	adminGroup.GET("/analytics", gin.WrapF(httpServer.AnalyticsHandler))
+	return &fixture{router: router, recorder: recorder, adminFake: clients.AdminFake}
+}
+
+func (f *fixture) exec(remoteAddr, sessionID, cmd string, args ...string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(args)
+	r := httptest.NewRequest(http.MethodPost, "/shell/exec/"+cmd, bytes.NewReader(body))
+	r.RemoteAddr = remoteAddr
+	if sessionID != "" {
+		r.Header.Set(analytics.SessionIDHeader, sessionID)
+	}
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, r)
+	return w
+}
+
+func (f *fixture) report(t *testing.T, query string) analytics.Report {
+	w := f.get("/admin/analytics"+query, adminToken)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var body struct {
+		Data analytics.Report `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	return body.Data
+}
+
+func (f *fixture) get(path, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, path, http.NoBody)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, r)
+	return w
+}
+
+func flush(t *testing.T, recorder *analytics.Recorder) {
+	require.NoError(t, recorder.Flush(context.Background()))
+}
+
+func TestCommandsAreCounted(t *testing.T) {
+	f := setup(t)
+
+	require.Equal(t, http.StatusOK, f.exec("10.0.0.1:1234", "s1", "SET", "k1", "v1").Code)
+	require.Equal(t, http.StatusOK, f.exec("10.0.0.1:1234", "s1", "GET", "k1").Code)
+	require.Equal(t, http.StatusBadRequest, f.exec("10.0.0.2:1234", "s2", "INCR", "k1").Code)
+	require.Equal(t, http.StatusOK, f.exec("10.0.0.2:1234", "", "GET", "k1").Code)
+	flush(t, f.recorder)
+
+	hour := time.Now().UTC().Format("2006010215")
+	assert.Equal(t, map[string]string{"SET:success": "1", "GET:success": "2", "INCR:error": "1"},
+		f.adminFake.Hash(utils.AnalyticsKeyPrefix+"commands:hour:"+hour))
+	for _, key := range []string{"10.0.0.1", "10.0.0.2"} {
+		assert.False(t, f.adminFake.Exists(key), "client IPs must not be stored as is")
+	}
+
+	report := f.report(t, "")
+	require.Len(t, report.Days, 7)
+	today := report.Days[6]
+	assert.Equal(t, time.Now().UTC().Format(analytics.DateLayout), today.Date)
+	assert.Equal(t, analytics.Counts{Success: 2}, today.Commands["GET"])
+	assert.Equal(t, analytics.Counts{Error: 1}, today.Commands["INCR"])
+	assert.Equal(t, int64(2), today.UniqueVisitors)
+	assert.Equal(t, int64(2), today.UniqueSessions)
+	assert.Equal(t, today.Commands, report.Totals.Commands)
+}
+
+func TestRollup(t *testing.T) {
+	f := setup(t)
+
+	now := time.Now().UTC()
+	past := now.Add(-3 * time.Hour)
+	for i := 0; i < 3; i++ {
+		f.recorder.Record(analytics.Visit{Time: past, Command: "get", ClientIP: "10.0.0.1"})
+	}
+	f.recorder.Record(analytics.Visit{Time: past, Command: "set", Failed: true, ClientIP: "10.0.0.1"})
+	// The current hour is not over yet and must not be rolled up
+	f.recorder.Record(analytics.Visit{Time: now, Command: "get", ClientIP: "10.0.0.1"})
+	flush(t, f.recorder)
+
+	require.NoError(t, f.recorder.Rollup(context.Background(), now))
+	require.NoError(t, f.recorder.Rollup(context.Background(), now), "rolling up twice must not count twice")
+	assert.Equal(t, 1, f.adminFake.Calls("EXEC"), "an hour is rolled up in a single transaction")
+
+	pastHourKey := utils.AnalyticsKeyPrefix + "commands:hour:" + past.Format("2006010215")
+	assert.False(t, f.adminFake.Exists(pastHourKey), "rolled up hours are deleted")
+	assert.Equal(t, map[string]string{"GET:success": "3", "SET:error": "1"},
+		f.adminFake.Hash(utils.AnalyticsKeyPrefix+"commands:day:"+past.Format("20060102")))
+	assert.True(t, f.adminFake.Exists(utils.AnalyticsKeyPrefix+"commands:hour:"+now.Format("2006010215")))
+
+	report := f.report(t, "?from="+past.Format(analytics.DateLayout)+"&to="+now.Format(analytics.DateLayout))
+	assert.Equal(t, analytics.Counts{Success: 4}, report.Totals.Commands["GET"])
+	assert.Equal(t, analytics.Counts{Error: 1}, report.Totals.Commands["SET"])
+	assert.Equal(t, int64(1), report.Totals.UniqueVisitors)
+}
+
+func TestUniqueVisitorsAcrossDays(t *testing.T) {
+	f := setup(t)
+
+	today := time.Now().UTC()
+	yesterday := today.AddDate(0, 0, -1)
+	f.recorder.Record(analytics.Visit{Time: yesterday, Command: "GET", ClientIP: "10.0.0.1", SessionID: "a"})
+	f.recorder.Record(analytics.Visit{Time: yesterday, Command: "GET", ClientIP: "10.0.0.2", SessionID: "b"})
+	f.recorder.Record(analytics.Visit{Time: today, Command: "GET", ClientIP: "10.0.0.2", SessionID: "c"})
+	flush(t, f.recorder)
+
+	report := f.report(t, "?from="+yesterday.Format(analytics.DateLayout)+"&to="+today.Format(analytics.DateLayout))
+	require.Len(t, report.Days, 2)
+	assert.Equal(t, int64(2), report.Days[0].UniqueVisitors)
+	assert.Equal(t, int64(1), report.Days[1].UniqueVisitors)
+	assert.Equal(t, int64(2), report.Totals.UniqueVisitors)
+	assert.Equal(t, int64(3), report.Totals.UniqueSessions)
+}
+
+func TestRunFlushesOnShutdown(t *testing.T) {
+	f := setup(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go f.recorder.Run(ctx, &wg)
+
+	now := time.Now().UTC()
+	f.recorder.Record(analytics.Visit{Time: now, Command: "PING"})
+	cancel()
+	wg.Wait()
+
+	assert.Equal(t, map[string]string{"PING:success": "1"},
+		f.adminFake.Hash(utils.AnalyticsKeyPrefix+"commands:hour:"+now.Format("2006010215")))
+}
+
+func TestAnalyticsEndpointValidation(t *testing.T) {
+	f := setup(t)
+
+	assert.Equal(t, http.StatusUnauthorized, f.get("/admin/analytics", "").Code)
+	assert.Equal(t, http.StatusBadRequest, f.get("/admin/analytics?from=yesterday", adminToken).Code)
+	assert.Equal(t, http.StatusBadRequest, f.get("/admin/analytics?from=2026-02-01&to=2026-01-01", adminToken).Code)
+	assert.Equal(t, http.StatusBadRequest, f.get("/admin/analytics?from=2020-01-01&to=2026-01-01", adminToken).Code)
+	assert.Equal(t, http.StatusOK, f.get("/admin/analytics?from=2026-01-01&to=2026-01-31", adminToken).Code)
+}
@@ -0,0 +1,143 @@
+package apierror
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"server/internal/apierror"
+	"server/internal/middleware"
+	"server/internal/server"
+	"server/internal/tests/fakedice"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fixture struct {
+	router   *gin.Engine
+	userFake *fakedice.Server
+}
+
+func setup(t *testing.T, limit int64) *fixture {
+	clients := fakedice.NewClients(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	httpServer := server.NewHTTPServer(router, clients.Config, server.Options{DiceClient: clients.User})
+	router.Use(middleware.RequestLoggerMiddleware)
+	router.Use(middleware.NewRateLimiterMiddleware(clients.Admin, limit, 60).Exec)
+	router.POST("/shell/exec/:cmd", gin.WrapF(httpServer.CliHandler))
+	router.GET("/admin/slowlog", middleware.NewAdminAuthMiddleware("s3cret"), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return &fixture{router: router, userFake: clients.UserFake}
+}
+
+func (f *fixture) exec(cmd string, args ...string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(args)
+	return f.do(httptest.NewRequest(http.MethodPost, "/shell/exec/"+cmd, bytes.NewReader(body)))
+}
+
+func (f *fixture) do(r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, r)
+	return w
+}
+
+// decode asserts the response is an error object with the given status
+func decode(t *testing.T, w *httptest.ResponseRecorder, status int) *apierror.Error {
+	require.Equal(t, status, w.Code, w.Body.String())
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var body apierror.Response
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body), w.Body.String())
+	require.NotNil(t, body.Error)
+	return body.Error
+}
+
+func TestCommandErrors(t *testing.T) {
+	f := setup(t, 1000)
+	require.Equal(t, http.StatusOK, f.exec("SET", "k1", "v1").Code)
+
+	err := decode(t, f.exec("LPUSH", "k1", "a"), http.StatusConflict)
+	assert.Equal(t, apierror.CodeWrongType, err.Code)
+	assert.Equal(t, "WRONGTYPE Operation against a key holding the wrong kind of value", err.Message)
+	assert.NotEmpty(t, err.Hint)
+
+	err = decode(t, f.exec("NOTACOMMAND"), http.StatusBadRequest)
+	assert.Equal(t, apierror.CodeUnknownCommand, err.Code)
+
+	err = decode(t, f.exec("GET"), http.StatusBadRequest)
+	assert.Equal(t, apierror.CodeSyntax, err.Code)
+	assert.Contains(t, err.Message, "wrong number of arguments")
+
+	err = decode(t, f.exec("INCR", "k1"), http.StatusBadRequest)
+	assert.Equal(t, apierror.CodeCommandError, err.Code)
+	assert.Contains(t, err.Message, "not an integer")
+
+	err = decode(t, f.do(httptest.NewRequest(http.MethodPost, "/shell/exec/GET", strings.NewReader("not json"))), http.StatusBadRequest)
+	assert.Equal(t, apierror.CodeInvalidRequest, err.Code)
+}
+
+func TestBlockedCommand(t *testing.T) {
+	t.Setenv("ENVIRONMENT", "production")
+	f := setup(t, 1000)
+
+	err := decode(t, f.exec("FLUSHALL"), http.StatusForbidden)
+	assert.Equal(t, apierror.CodeBlockedCommand, err.Code)
+	assert.Zero(t, f.userFake.Calls("FLUSHALL"))
+}
+
+func TestRateLimited(t *testing.T) {
+	f := setup(t, 1)
+	require.Equal(t, http.StatusOK, f.exec("GET", "k1").Code)
+
+	err := decode(t, f.exec("GET", "k1"), http.StatusTooManyRequests)
+	assert.Equal(t, apierror.CodeRateLimited, err.Code)
+	assert.Equal(t, float64(1), err.Details["limit"])
+	assert.Positive(t, err.Details["retry_after_sec"])
+	assert.Equal(t, 1, f.userFake.Calls("GET"), "rejected requests must not reach the handler")
+}
+
+func TestBackendUnavailable(t *testing.T) {
+	f := setup(t, 1000)
+	f.userFake.Close()
+
+	err := decode(t, f.exec("GET", "k1"), http.StatusServiceUnavailable)
+	assert.Equal(t, apierror.CodeBackendUnavailable, err.Code)
+	assert.NotContains(t, err.Message, "127.0.0.1", "connection details are not sent to clients")
+}
+
+func TestAdminAuthErrors(t *testing.T) {
+	f := setup(t, 1000)
+
+	err := decode(t, f.do(httptest.NewRequest(http.MethodGet, "/admin/slowlog", http.NoBody)), http.StatusUnauthorized)
+	assert.Equal(t, apierror.CodeUnauthorized, err.Code)
+}
+
+func TestFromReply(t *testing.T) {
+	tests := []struct {
+		message string
+		code    apierror.Code
+		status  int
+	}{
+		{"WRONGTYPE Operation against a key holding the wrong kind of value", apierror.CodeWrongType, http.StatusConflict},
+		{"(error) WRONGTYPE Key is not a valid HyperLogLog string value", apierror.CodeWrongType, http.StatusConflict},
+		{"ERR unknown command 'foo'", apierror.CodeUnknownCommand, http.StatusBadRequest},
+		{"ERR syntax error", apierror.CodeSyntax, http.StatusBadRequest},
+		{"(error) ERR wrong number of arguments for 'hget' command", apierror.CodeSyntax, http.StatusBadRequest},
+		{"ERR Unsupported option extra_argument", apierror.CodeSyntax, http.StatusBadRequest},
+		{"ERR value is not an integer or out of range", apierror.CodeCommandError, http.StatusBadRequest},
+	}
+
+	for _, tc := range tests {
+		err := apierror.FromReply(tc.message)
+		assert.Equal(t, tc.code, err.Code, tc.message)
+		assert.Equal(t, tc.status, err.Status(), tc.message)
+		assert.False(t, strings.HasPrefix(err.Message, "(error)"), "the pretty rendering prefix is stripped")
+	}
+}
@@ -0,0 +1,243 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"server/config"
+	"server/internal/audit"
+	"server/internal/db"
+	"server/internal/middleware"
+	"server/internal/server"
+	"server/internal/server/utils"
+	"server/internal/tests/fakedice"
+	"server/util/cmds"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setup starts fake DiceDB instances and returns connected admin and user clients
+func setup(t *testing.T) (admin, user *db.DiceDB, adminFake *fakedice.Server) {
+	clients := fakedice.NewClients(t)
+	return clients.Admin, clients.User, clients.AdminFake
+}
+
+func newRouter(user *db.DiceDB, auditor *audit.Auditor) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.RequestLoggerMiddleware)
+	router.POST("/shell/exec/:cmd", gin.WrapF((&server.HTTPServer{DiceClient: user, Auditor: auditor}).CliHandler))
+	return router
+}
+
+func exec(router *gin.Engine, cmd string, args ...string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(args)
+	r := httptest.NewRequest(http.MethodPost, "/shell/exec/"+cmd, bytes.NewReader(body))
+	r.Header.Set(middleware.RequestIDHeader, "req-"+strings.ToLower(cmd))
+	r.Header.Set("User-Agent", "audit-test")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func decode(t *testing.T, data []byte) []audit.Event {
+	var events []audit.Event
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var event audit.Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event), scanner.Text())
+		events = append(events, event)
+	}
+	return events
+}
+
+func closeAuditor(t *testing.T, auditor *audit.Auditor) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, auditor.Close(ctx))
+}
+
+func TestCommandsAreAudited(t *testing.T) {
+	admin, user, adminFake := setup(t)
+
+	buf := &bytes.Buffer{}
+	auditor := audit.New([]audit.Sink{
+		audit.NewWriterSink("buffer", buf),
+		audit.NewDiceDBSink(admin, utils.AuditLogKey, 10),
+	}, audit.Options{SampleRate: 1, QueueSize: 16, BatchSize: 4, FlushInterval: time.Hour})
+	router := newRouter(user, auditor)
+
+	require.Equal(t, http.StatusOK, exec(router, "SET", "k1", "secret-value").Code)
+	require.Equal(t, http.StatusOK, exec(router, "GET", "k1").Code)
+	require.Equal(t, http.StatusBadRequest, exec(router, "INCR", "k1").Code)
+	closeAuditor(t, auditor)
+
+	events := decode(t, buf.Bytes())
+	require.Len(t, events, 3)
+	assert.NotContains(t, buf.String(), "secret-value", "argument values must never be recorded")
+
+	set := events[0]
+	assert.Equal(t, "SET", set.Command)
+	assert.Equal(t, 2, set.ArgCount)
+	assert.Equal(t, []string{"k1"}, set.Keys)
+	assert.Equal(t, audit.OutcomeSuccess, set.Outcome)
+	assert.Equal(t, "req-set", set.RequestID)
+	assert.Equal(t, "audit-test", set.UserAgent)
+	assert.NotEmpty(t, set.ClientIP)
+	assert.False(t, set.Timestamp.IsZero())
+
+	incr := events[2]
+	assert.Equal(t, "INCR", incr.Command)
+	assert.Equal(t, audit.OutcomeError, incr.Outcome)
+	assert.Contains(t, incr.Error, "not an integer")
+
+	// The DiceDB sink keeps the newest events first
+	stored := adminFake.List(utils.AuditLogKey)
+	require.Len(t, stored, 3)
+	var newest audit.Event
+	require.NoError(t, json.Unmarshal([]byte(stored[0]), &newest))
+	assert.Equal(t, "INCR", newest.Command)
+}
+
+func TestRejectedCommandIsAudited(t *testing.T) {
+	_, user, _ := setup(t)
+
+	buf := &bytes.Buffer{}
+	auditor := audit.New([]audit.Sink{audit.NewWriterSink("buffer", buf)}, audit.Options{SampleRate: 1})
+	router := newRouter(user, auditor)
+
+	r := httptest.NewRequest(http.MethodPost, "/shell/exec/GET", strings.NewReader("not json"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	closeAuditor(t, auditor)
+
+	events := decode(t, buf.Bytes())
+	require.Len(t, events, 1)
+	assert.Equal(t, "GET", events[0].Command)
+	assert.Equal(t, audit.OutcomeRejected, events[0].Outcome)
+}
+
+func TestSamplingKeepsFailures(t *testing.T) {
+	_, user, _ := setup(t)
+
+	buf := &bytes.Buffer{}
+	auditor := audit.New([]audit.Sink{audit.NewWriterSink("buffer", buf)}, audit.Options{SampleRate: 0})
+	router := newRouter(user, auditor)
+
+	for i := 0; i < 5; i++ {
+		exec(router, "SET", "k1", "v1")
+	}
+	exec(router, "INCR", "k1")
+	closeAuditor(t, auditor)
+
+	events := decode(t, buf.Bytes())
+	require.Len(t, events, 1)
+	assert.Equal(t, audit.OutcomeError, events[0].Outcome)
+}
+
+// blockingSink blocks every write until released
+type blockingSink struct {
+	release chan struct{}
+	written int
+}
+
+func (s *blockingSink) Name() string { return "blocking" }
+
+func (s *blockingSink) Write(_ context.Context, events []audit.Event) error {
+	<-s.release
+	s.written += len(events)
+	return nil
+}
+
+func (s *blockingSink) Close() error { return nil }
+
+func TestFullQueueDropsEvents(t *testing.T) {
+	sink := &blockingSink{release: make(chan struct{})}
+	auditor := audit.New([]audit.Sink{sink}, audit.Options{SampleRate: 1, QueueSize: 1, BatchSize: 1})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			auditor.Record(audit.Event{Command: "GET", Outcome: audit.OutcomeSuccess})
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Record blocked on a stalled sink")
+	}
+
+	close(sink.release)
+	closeAuditor(t, auditor)
+	assert.LessOrEqual(t, sink.written, 2, "events beyond the queue capacity should have been dropped")
+	assert.GreaterOrEqual(t, sink.written, 1)
+}
+
+func TestFileSinkRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	sink, err := audit.NewFileSink(path, 200, 2)
+	require.NoError(t, err)
+
+	batch := []audit.Event{{Command: "SET", Keys: []string{"k1"}, Outcome: audit.OutcomeSuccess}}
+	for i := 0; i < 10; i++ {
+		require.NoError(t, sink.Write(context.Background(), batch))
+	}
+	require.NoError(t, sink.Close())
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(name)
+		require.NoError(t, err, name)
+		assert.LessOrEqual(t, info.Size(), int64(200), name)
+	}
+	_, err = os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err), "only maxBackups rotated files should be kept")
+
+	data, err := os.ReadFile(path + ".1")
+	require.NoError(t, err)
+	assert.NotEmpty(t, decode(t, data))
+}
+
+func TestNewFromConfig(t *testing.T) {
+	auditor, err := audit.NewFromConfig(&config.AuditConfig{}, nil)
+	require.NoError(t, err)
+	assert.Nil(t, auditor, "no sinks disables auditing")
+
+	_, err = audit.NewFromConfig(&config.AuditConfig{Sinks: []string{"kafka"}}, nil)
+	assert.Error(t, err)
+}
+
+func TestKeyNames(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		args []string
+		want []string
+	}{
+		{"GET", []string{"k1"}, []string{"k1"}},
+		{"set", []string{"k1", "v1", "EX", "10"}, []string{"k1"}},
+		{"MSET", []string{"k1", "v1", "k2", "v2"}, []string{"k1", "k2"}},
+		{"DEL", []string{"k1", "k2", "k3"}, []string{"k1", "k2", "k3"}},
+		{"BLPOP", []string{"l1", "l2", "0"}, []string{"l1", "l2"}},
+		{"BITOP", []string{"AND", "dest", "k1", "k2"}, []string{"dest", "k1", "k2"}},
+		{"PING", nil, nil},
+		{"UNKNOWN", []string{"a", "b"}, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.cmd, func(t *testing.T) {
+			assert.Equal(t, tc.want, cmds.KeyNames(tc.cmd, tc.args))
+		})
+	}
+}
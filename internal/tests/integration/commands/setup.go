@@ -4,11 +4,11 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"server/config"
+	"server/internal/apierror"
 	"server/internal/db"
 	"server/internal/server"
 	"time"
@@ -71,15 +71,14 @@ func (hce *HTTPCommandExecutor) FireCommand(httpCommand HTTPCommand) (resp strin
 	handler := http.HandlerFunc(hce.httpServer.CliHandler)
 	handler.ServeHTTP(rr, req)
 	if status := rr.Code; status != http.StatusOK {
-		var cmdErr struct {
-			Error string `json:"error"`
-		}
+		var cmdErr apierror.Response
 		err = json.Unmarshal(rr.Body.Bytes(), &cmdErr)
-		if err != nil {
+		if err != nil || cmdErr.Error == nil {
 			return "", fmt.Errorf("failed to parse error: %s - %v", rr.Body.String(), err)
 		}
 
-		return "", errors.New(cmdErr.Error)
+		// Render the DiceDB message the way the CLI prints it
+		return "", fmt.Errorf("(error) %s", cmdErr.Error.Message)
 	}
 
 	var cmdResp struct {
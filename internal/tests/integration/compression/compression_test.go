@@ -0,0 +1,171 @@
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"server/internal/middleware"
+	"server/internal/server"
+	"server/internal/tests/fakedice"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const minSize = 1024
+
+func newRouter(t *testing.T) *gin.Engine {
+	clients := fakedice.NewClients(t)
+
+	// LRANGE returns a reply of n items of 16 bytes each
+	clients.UserFake.Handle("LRANGE", func(args []string) fakedice.Reply {
+		var n int
+		_, _ = fmt.Sscan(args[2], &n)
+		items := make([]fakedice.Reply, n)
+		for i := range items {
+			items[i] = fakedice.BulkString(fmt.Sprintf("item-%010d", i))
+		}
+		return fakedice.Array(items...)
+	})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.NewCompressionMiddleware(minSize, 6, 5, []string{"/raw"}).Exec)
+	router.POST("/shell/exec/:cmd", gin.WrapF((&server.HTTPServer{DiceClient: clients.User}).CliHandler))
+	router.GET("/raw", func(c *gin.Context) {
+		c.String(http.StatusOK, string(bytes.Repeat([]byte("a"), 4*minSize)))
+	})
+	router.GET("/events", func(c *gin.Context) {
+		c.Header("Content-Type", "text/event-stream")
+		for i := 0; i < 100; i++ {
+			_, _ = fmt.Fprintf(c.Writer, "data: event %d with some padding to make it larger\n\n", i)
+			c.Writer.Flush()
+		}
+	})
+	return router
+}
+
+func lrange(router *gin.Engine, n int, acceptEncoding, accept string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal([]string{"l", "0", fmt.Sprint(n)})
+	r := httptest.NewRequest(http.MethodPost, "/shell/exec/lrange", bytes.NewReader(body))
+	if acceptEncoding != "" {
+		r.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	if accept != "" {
+		r.Header.Set("Accept", accept)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func decode(t *testing.T, w *httptest.ResponseRecorder) string {
+	var reader io.Reader
+	switch w.Header().Get("Content-Encoding") {
+	case "gzip":
+		gz, err := gzip.NewReader(w.Body)
+		require.NoError(t, err)
+		reader = gz
+	case "br":
+		reader = brotli.NewReader(w.Body)
+	default:
+		reader = w.Body
+	}
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestLargeRepliesAreCompressed(t *testing.T) {
+	router := newRouter(t)
+	plain := lrange(router, 1000, "", "")
+	require.Equal(t, http.StatusOK, plain.Code)
+	expected := plain.Body.String()
+
+	tests := []struct {
+		acceptEncoding string
+		encoding       string
+	}{
+		{"gzip", "gzip"},
+		{"br", "br"},
+		{"gzip, deflate, br", "br"},
+		{"br;q=0.5, gzip", "gzip"},
+		{"*", "br"},
+		{"br;q=0, gzip;q=0", ""},
+		{"identity", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.acceptEncoding, func(t *testing.T) {
+			w := lrange(router, 1000, tt.acceptEncoding, "")
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tt.encoding, w.Header().Get("Content-Encoding"))
+			assert.Contains(t, w.Header().Values("Vary"), "Accept-Encoding")
+			if tt.encoding != "" {
+				assert.Less(t, w.Body.Len(), len(expected))
+			}
+			assert.Equal(t, expected, decode(t, w))
+		})
+	}
+}
+
+func TestTextRepliesAreCompressed(t *testing.T) {
+	router := newRouter(t)
+
+	w := lrange(router, 1000, "gzip", "text/plain")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Contains(t, decode(t, w), "1000) \"item-0000000999\"")
+}
+
+func TestSmallRepliesAreNotCompressed(t *testing.T) {
+	router := newRouter(t)
+
+	w := lrange(router, 2, "gzip, br", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Contains(t, w.Header().Values("Vary"), "Accept-Encoding")
+	assert.Contains(t, w.Body.String(), "item-0000000001")
+}
+
+func TestBinaryRepliesAreNotCompressed(t *testing.T) {
+	router := newRouter(t)
+
+	w := lrange(router, 1000, "gzip", "application/msgpack")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+}
+
+func TestStreamsAndExcludedPathsAreNotCompressed(t *testing.T) {
+	router := newRouter(t)
+
+	r := httptest.NewRequest(http.MethodGet, "/events", http.NoBody)
+	r.Header.Set("Accept", "text/event-stream")
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Contains(t, w.Body.String(), "data: event 99")
+
+	// Event streams are recognized by their content type as well
+	r = httptest.NewRequest(http.MethodGet, "/events", http.NoBody)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Contains(t, w.Body.String(), "data: event 99")
+
+	r = httptest.NewRequest(http.MethodGet, "/raw", http.NoBody)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Empty(t, w.Header().Values("Vary"))
+	assert.Equal(t, 4*minSize, w.Body.Len())
+}
@@ -0,0 +1,82 @@
+package dbclient
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"server/config"
+	"server/internal/db"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfigReadsSecretFiles(t *testing.T) {
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(passwordFile, []byte("s3cret\n"), 0o600))
+
+	t.Setenv("DICEDB_PASSWORD", "from-env")
+	t.Setenv("DICEDB_PASSWORD_FILE", passwordFile)
+	t.Setenv("DICEDB_METADATA_PASSWORD", "admin-from-env")
+
+	configValue := config.LoadConfig()
+	assert.Equal(t, "s3cret", configValue.DiceDB.Password, "should prefer the secret file over the env var")
+	assert.Equal(t, "admin-from-env", configValue.DiceDBAdmin.Password, "should fall back to the env var")
+}
+
+func TestLoadFailsOnUnreadableSecretFile(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "from-env")
+	t.Setenv("ADMIN_TOKEN_FILE", filepath.Join(t.TempDir(), "missing"))
+
+	configValue, err := config.Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ADMIN_TOKEN_FILE")
+	assert.Empty(t, configValue.Admin.Token, "should not fall back to the env var")
+}
+
+func TestLoadConfigReadsTLSSettings(t *testing.T) {
+	t.Setenv("DICEDB_METADATA_TLS_ENABLED", "true")
+	t.Setenv("DICEDB_METADATA_TLS_SERVER_NAME", "dicedb.internal")
+	t.Setenv("DICEDB_METADATA_TLS_INSECURE_SKIP_VERIFY", "true")
+
+	configValue := config.LoadConfig()
+	assert.True(t, configValue.DiceDBAdmin.TLS.Enabled)
+	assert.Equal(t, "dicedb.internal", configValue.DiceDBAdmin.TLS.ServerName)
+	assert.True(t, configValue.DiceDBAdmin.TLS.InsecureSkipVerify)
+	assert.False(t, configValue.DiceDB.TLS.Enabled, "TLS settings should be per connection")
+}
+
+func TestInitDiceClientReportsFailingClient(t *testing.T) {
+	// Reserve a free port and close it so that nothing is listening on it
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	configValue := config.LoadConfig()
+	configValue.DiceDBAdmin.Addr = addr
+
+	_, err = db.InitDiceClient(configValue, true)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "DiceDB admin client")
+	assert.Contains(t, err.Error(), addr)
+}
+
+func TestInitDiceClientRejectsInvalidTLSConfig(t *testing.T) {
+	configValue := config.LoadConfig()
+	configValue.DiceDB.TLS.Enabled = true
+	configValue.DiceDB.TLS.CAFile = filepath.Join(t.TempDir(), "missing-ca.pem")
+
+	_, err := db.InitDiceClient(configValue, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "DiceDB user client")
+	assert.Contains(t, err.Error(), "CA bundle")
+
+	configValue.DiceDB.TLS.CAFile = ""
+	configValue.DiceDB.TLS.CertFile = "client.pem"
+
+	_, err = db.InitDiceClient(configValue, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "both client certificate and key files must be set")
+}
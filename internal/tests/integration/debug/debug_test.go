@@ -0,0 +1,120 @@
+package debug
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"server/internal/middleware"
+	"server/internal/server"
+	"server/internal/tests/fakedice"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const adminToken = "s3cret"
+
+func newRouter(t *testing.T) *gin.Engine {
+	t.Setenv("DICEDB_METADATA_PASSWORD", "admin-password")
+	t.Setenv("ADMIN_TOKEN", adminToken)
+	clients := fakedice.NewClients(t)
+
+	rateLimiter := middleware.NewRateLimiterMiddleware(clients.Admin, 10, 60)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(rateLimiter.Exec)
+	router.POST("/shell/exec/:cmd", func(c *gin.Context) { c.Status(http.StatusOK) })
+	server.RegisterDebugRoutes(router.Group("/debug", middleware.NewAdminAuthMiddleware(adminToken)), clients.Config, rateLimiter)
+	return router
+}
+
+func get(router *gin.Engine, path, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, path, http.NoBody)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func TestDebugRoutesRequireToken(t *testing.T) {
+	router := newRouter(t)
+
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/heap", "/debug/vars", "/debug/config", "/debug/ratelimiter"} {
+		assert.Equal(t, http.StatusUnauthorized, get(router, path, "").Code, path)
+		assert.Equal(t, http.StatusUnauthorized, get(router, path, "wrong").Code, path)
+	}
+}
+
+func TestPprof(t *testing.T) {
+	router := newRouter(t)
+
+	w := get(router, "/debug/pprof/", adminToken)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "goroutine")
+
+	w = get(router, "/debug/pprof/goroutine?debug=1", adminToken)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "goroutine profile")
+
+	assert.Equal(t, http.StatusOK, get(router, "/debug/pprof/cmdline", adminToken).Code)
+	assert.Equal(t, http.StatusNotFound, get(router, "/debug/pprof/unknown", adminToken).Code)
+}
+
+func TestRuntimeVars(t *testing.T) {
+	router := newRouter(t)
+
+	w := get(router, "/debug/vars", adminToken)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var vars map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &vars))
+	for _, name := range []string{"goroutines", "gc", "heap", "memstats"} {
+		assert.Contains(t, vars, name)
+	}
+
+	var heap map[string]float64
+	require.NoError(t, json.Unmarshal(vars["heap"], &heap))
+	assert.Positive(t, heap["alloc_bytes"])
+}
+
+func TestConfigDumpRedactsSecrets(t *testing.T) {
+	router := newRouter(t)
+
+	w := get(router, "/debug/config", adminToken)
+	require.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.NotContains(t, body, "admin-password")
+	assert.NotContains(t, body, adminToken)
+	assert.Contains(t, body, "[REDACTED]")
+	assert.Contains(t, body, "RequestLimitPerMin")
+}
+
+func TestRateLimiterState(t *testing.T) {
+	router := newRouter(t)
+
+	for i := 0; i < 3; i++ {
+		r := httptest.NewRequest(http.MethodPost, "/shell/exec/get", strings.NewReader("[]"))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	w := get(router, "/debug/ratelimiter", adminToken)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var body struct {
+		Data middleware.RateLimiterState `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, int64(10), body.Data.Limit)
+	assert.Equal(t, int64(3), body.Data.Count)
+	assert.Equal(t, int64(7), body.Data.Remaining)
+	assert.True(t, strings.HasPrefix(body.Data.Key, "request_count:"))
+	assert.False(t, body.Data.ResetAt.IsZero())
+}
@@ -0,0 +1,232 @@
+package diff
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"server/internal/db"
+	"server/internal/keyspace"
+	"server/internal/middleware"
+	"server/internal/server"
+	"server/internal/tests/fakedice"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fixture struct {
+	router   *gin.Engine
+	user     *db.DiceDB
+	userFake *fakedice.Server
+}
+
+func setup(t *testing.T) *fixture {
+	clients := fakedice.NewClients(t)
+
+	httpServer := &server.HTTPServer{DiceClient: clients.User}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.ServerTimingMiddleware)
+	router.POST("/shell/exec/:cmd", gin.WrapF(httpServer.CliHandler))
+	return &fixture{router: router, user: clients.User, userFake: clients.UserFake}
+}
+
+func (f *fixture) do(t *testing.T, args ...interface{}) {
+	require.NoError(t, f.user.Client.Do(context.Background(), args...).Err())
+}
+
+func (f *fixture) exec(query, cmd string, args ...string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(args)
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/shell/exec/"+cmd+query, bytes.NewReader(body)))
+	return w
+}
+
+// diff executes the command with ?diff=true and returns the diff of the response
+func (f *fixture) diff(t *testing.T, cmd string, args ...string) *keyspace.Diff {
+	w := f.exec("?diff=true", cmd, args...)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp server.HTTPResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp.Diff
+}
+
+func TestCreatedKey(t *testing.T) {
+	f := setup(t)
+
+	diff := f.diff(t, "SET", "greeting", "hello")
+	require.NotNil(t, diff)
+	require.Len(t, diff.Keys, 1)
+	key := diff.Keys[0]
+	assert.Equal(t, "greeting", key.Key)
+	assert.Equal(t, keyspace.ChangeCreated, key.Change)
+	assert.Nil(t, key.Before)
+	require.NotNil(t, key.After)
+	assert.Equal(t, keyspace.TypeString, key.After.Type)
+	assert.Equal(t, "hello", key.After.Value)
+	assert.Equal(t, int64(-1), key.After.TTL)
+}
+
+func TestModifiedKey(t *testing.T) {
+	f := setup(t)
+	f.do(t, "SET", "greeting", "hello")
+
+	key := f.diff(t, "SET", "greeting", "world").Keys[0]
+	assert.Equal(t, keyspace.ChangeModified, key.Change)
+	assert.Equal(t, []string{keyspace.FieldValue}, key.Fields)
+	assert.Equal(t, "hello", key.Before.Value)
+	assert.Equal(t, "world", key.After.Value)
+	assert.Nil(t, key.Elements)
+
+	key = f.diff(t, "SET", "greeting", "world").Keys[0]
+	assert.Equal(t, keyspace.ChangeUnchanged, key.Change)
+	assert.Empty(t, key.Fields)
+}
+
+func TestDeletedKey(t *testing.T) {
+	f := setup(t)
+	f.do(t, "SET", "greeting", "hello")
+
+	diff := f.diff(t, "DEL", "greeting", "missing", "greeting")
+	require.Len(t, diff.Keys, 2, "duplicate keys are diffed once")
+	assert.Equal(t, keyspace.ChangeDeleted, diff.Keys[0].Change)
+	assert.Equal(t, "hello", diff.Keys[0].Before.Value)
+	assert.Nil(t, diff.Keys[0].After)
+	assert.Equal(t, "missing", diff.Keys[1].Key)
+	assert.Equal(t, keyspace.ChangeUnchanged, diff.Keys[1].Change)
+}
+
+func TestHashFieldChanges(t *testing.T) {
+	f := setup(t)
+	f.do(t, "HINCRBY", "user:1", "visits", 1)
+
+	key := f.diff(t, "HINCRBY", "user:1", "logins", "1").Keys[0]
+	assert.Equal(t, keyspace.ChangeModified, key.Change)
+	require.NotNil(t, key.Elements)
+	assert.Equal(t, map[string]interface{}{"logins": "1"}, key.Elements.Added)
+	assert.Empty(t, key.Elements.Removed)
+	assert.Empty(t, key.Elements.Updated)
+
+	key = f.diff(t, "HINCRBY", "user:1", "visits", "2").Keys[0]
+	require.NotNil(t, key.Elements)
+	assert.Nil(t, key.Elements.Added)
+	assert.Equal(t, map[string]keyspace.Update{"visits": {Before: "1", After: "3"}}, key.Elements.Updated)
+}
+
+func TestTTLChange(t *testing.T) {
+	f := setup(t)
+	f.do(t, "SET", "session", "abc")
+	var expired atomic.Bool
+	f.userFake.Handle("EXPIRE", func([]string) fakedice.Reply {
+		expired.Store(true)
+		return fakedice.Integer(1)
+	})
+	f.userFake.Handle("PTTL", func([]string) fakedice.Reply {
+		if expired.Load() {
+			return fakedice.Integer(60000)
+		}
+		return fakedice.Integer(-1)
+	})
+
+	key := f.diff(t, "EXPIRE", "session", "60").Keys[0]
+	assert.Equal(t, keyspace.ChangeModified, key.Change)
+	assert.Equal(t, []string{keyspace.FieldTTL}, key.Fields)
+	assert.Equal(t, int64(-1), key.Before.TTL)
+	assert.Equal(t, int64(60), key.After.TTL)
+}
+
+func TestExpiringKeyCountingDownIsNotATTLChange(t *testing.T) {
+	f := setup(t)
+	f.do(t, "HINCRBY", "session", "hits", "1")
+	expiresAt := time.Now().Add(1500 * time.Millisecond)
+	f.userFake.Handle("PTTL", func([]string) fakedice.Reply {
+		return fakedice.Integer(time.Until(expiresAt).Milliseconds())
+	})
+	// The TTL ticks down past a whole second while the command runs
+	f.userFake.SetDelay("HINCRBY", 600*time.Millisecond)
+
+	key := f.diff(t, "HINCRBY", "session", "hits", "1").Keys[0]
+	assert.Equal(t, keyspace.ChangeModified, key.Change)
+	assert.Equal(t, []string{keyspace.FieldValue}, key.Fields)
+}
+
+func TestReadOnlyCommandsAreNotDiffed(t *testing.T) {
+	f := setup(t)
+	f.do(t, "SET", "greeting", "hello")
+	typeCalls := f.userFake.Calls("TYPE")
+
+	assert.Nil(t, f.diff(t, "GET", "greeting"))
+	assert.Nil(t, f.diff(t, "PING"), "commands without keys are not diffed")
+	assert.Equal(t, typeCalls, f.userFake.Calls("TYPE"), "no key was captured")
+}
+
+func TestDiffIsOptional(t *testing.T) {
+	f := setup(t)
+	w := f.exec("", "SET", "greeting", "hello")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "diff")
+	assert.Equal(t, 0, f.userFake.Calls("TYPE"))
+}
+
+func TestFailedCommandIsNotDiffed(t *testing.T) {
+	f := setup(t)
+	f.do(t, "SET", "greeting", "hello")
+
+	w := f.exec("?diff=true", "LPUSH", "greeting", "a")
+	assert.NotEqual(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), `"diff"`)
+}
+
+func TestCapturedValuesAreCapped(t *testing.T) {
+	f := setup(t)
+	for i := 0; i < 60; i++ {
+		f.do(t, "LPUSH", "queue", i)
+	}
+
+	key := f.diff(t, "LPUSH", "queue", "new").Keys[0]
+	assert.Equal(t, keyspace.ChangeModified, key.Change)
+	assert.Equal(t, int64(60), key.Before.Length)
+	assert.Equal(t, int64(61), key.After.Length)
+	assert.True(t, key.After.Truncated)
+	assert.Len(t, key.After.Value, keyspace.DiffLimits.MaxElements)
+	assert.Nil(t, key.Elements, "truncated values are not diffed element by element")
+
+	key = f.diff(t, "SET", "big", strings.Repeat("x", 5000)).Keys[0]
+	assert.True(t, key.After.Truncated)
+	assert.Equal(t, int64(5000), key.After.Length)
+	assert.Len(t, key.After.Value, keyspace.DiffLimits.MaxBytes)
+}
+
+func TestDiffedKeysAreCapped(t *testing.T) {
+	f := setup(t)
+	keys := make([]string, keyspace.MaxDiffKeys+2)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key:%d", i)
+		f.do(t, "SET", keys[i], i)
+	}
+
+	diff := f.diff(t, "DEL", keys...)
+	assert.True(t, diff.Truncated)
+	require.Len(t, diff.Keys, keyspace.MaxDiffKeys)
+	for _, key := range diff.Keys {
+		assert.Equal(t, keyspace.ChangeDeleted, key.Change)
+	}
+}
+
+func TestDiffTiming(t *testing.T) {
+	f := setup(t)
+	w := f.exec("?diff=true&timing=true", "SET", "greeting", "hello")
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp server.HTTPResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Contains(t, resp.Timing, "diff")
+	assert.Contains(t, w.Header().Get("Server-Timing"), "diff;dur=")
+}
@@ -0,0 +1,100 @@
+package explain
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"server/internal/apierror"
+	"server/internal/server"
+	"server/internal/tests/fakedice"
+	"server/util/cmds"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newServer(t *testing.T) *server.HTTPServer {
+	return &server.HTTPServer{DiceClient: fakedice.NewClients(t).User}
+}
+
+func exec(s *server.HTTPServer, cmd string, args ...string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(args)
+	r := httptest.NewRequest(http.MethodPost, "/shell/exec/"+cmd, bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	s.CliHandler(w, r)
+	return w
+}
+
+func decode(t *testing.T, w *httptest.ResponseRecorder) *apierror.Error {
+	var body apierror.Response
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body), w.Body.String())
+	require.NotNil(t, body.Error)
+	return body.Error
+}
+
+func TestUnknownCommandSuggestions(t *testing.T) {
+	s := newServer(t)
+
+	w := exec(s, "HSETT", "k1", "f", "v")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	err := decode(t, w)
+	assert.Equal(t, apierror.CodeUnknownCommand, err.Code)
+	require.NotEmpty(t, err.Suggestions)
+	assert.Equal(t, "HSET", err.Suggestions[0])
+	assert.Contains(t, err.Hint, "Did you mean HSET")
+
+	err = decode(t, exec(s, "XYZZYQW"))
+	assert.Equal(t, apierror.CodeUnknownCommand, err.Code)
+	assert.Empty(t, err.Suggestions, "nothing is close enough")
+}
+
+func TestWrongArityShowsSyntax(t *testing.T) {
+	s := newServer(t)
+
+	err := decode(t, exec(s, "get"))
+	assert.Equal(t, apierror.CodeSyntax, err.Code)
+	assert.Equal(t, "GET key", err.Syntax)
+	assert.Equal(t, "Usage: GET key", err.Hint)
+	assert.Equal(t, "https://dicedb.io/commands/get/", err.DocsURL)
+}
+
+func TestWrongTypeShowsKeyType(t *testing.T) {
+	s := newServer(t)
+	require.Equal(t, http.StatusOK, exec(s, "SET", "k1", "v1").Code)
+
+	w := exec(s, "LPUSH", "k1", "a")
+	require.Equal(t, http.StatusConflict, w.Code)
+	err := decode(t, w)
+	assert.Equal(t, apierror.CodeWrongType, err.Code)
+	assert.Equal(t, "WRONGTYPE Operation against a key holding the wrong kind of value", err.Message,
+		"the original message is kept")
+	assert.Equal(t, "string", err.Details["key_type"])
+	assert.Equal(t, "k1", err.Details["key"])
+	assert.Contains(t, err.Hint, `"k1" holds a string value`)
+	assert.Equal(t, "LPUSH key element [element ...]", err.Syntax)
+}
+
+func TestSuggest(t *testing.T) {
+	tests := []struct {
+		cmd   string
+		first string
+	}{
+		{"HSETT", "HSET"},
+		{"gte", "GET"},
+		{"LPSUH", "LPUSH"},
+		{"ZRANGBYSCORE", "ZRANGEBYSCORE"},
+		{"JSON.GTE", "JSON.GET"},
+	}
+
+	for _, tc := range tests {
+		suggestions := cmds.Suggest(tc.cmd)
+		require.NotEmpty(t, suggestions, tc.cmd)
+		assert.Equal(t, tc.first, suggestions[0], tc.cmd)
+		assert.LessOrEqual(t, len(suggestions), 3)
+	}
+
+	assert.Empty(t, cmds.Suggest(""))
+	assert.NotContains(t, cmds.Suggest("GET"), "GET", "the command itself is not a suggestion")
+}
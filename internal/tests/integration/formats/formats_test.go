@@ -0,0 +1,174 @@
+package formats
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"server/internal/apierror"
+	"server/internal/middleware"
+	"server/internal/server"
+	"server/internal/tests/fakedice"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ugorji/go/codec"
+)
+
+var msgpackHandle = &codec.MsgpackHandle{WriteExt: true}
+
+func newRouter(t *testing.T) (*gin.Engine, *fakedice.Server) {
+	clients := fakedice.NewClients(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.RequestLoggerMiddleware)
+	router.POST("/shell/exec/:cmd", gin.WrapF((&server.HTTPServer{DiceClient: clients.User}).CliHandler))
+	return router, clients.UserFake
+}
+
+func exec(router *gin.Engine, target, accept string, args ...string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(args)
+	r := httptest.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if accept != "" {
+		r.Header.Set("Accept", accept)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func encodeMsgpack(t *testing.T, v interface{}) []byte {
+	var buf []byte
+	require.NoError(t, codec.NewEncoderBytes(&buf, msgpackHandle).Encode(v))
+	return buf
+}
+
+func decodeMsgpack(t *testing.T, data []byte) map[string]interface{} {
+	var v map[string]interface{}
+	require.NoError(t, codec.NewDecoderBytes(data, msgpackHandle).Decode(&v))
+	return v
+}
+
+func TestMessagePackRequestAndResponse(t *testing.T) {
+	router, fake := newRouter(t)
+	value := "\x00\xffbinary"
+
+	r := httptest.NewRequest(http.MethodPost, "/shell/exec/set", bytes.NewReader(encodeMsgpack(t, []interface{}{"k", []byte(value)})))
+	r.Header.Set("Content-Type", "application/msgpack")
+	r.Header.Set("Accept", "application/msgpack")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/msgpack", w.Header().Get("Content-Type"))
+	assert.Equal(t, "OK", decodeMsgpack(t, w.Body.Bytes())["data"])
+
+	stored, ok := fake.Value("k")
+	require.True(t, ok)
+	assert.Equal(t, value, stored)
+
+	w = exec(router, "/shell/exec/get", "application/x-msgpack", "k")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []byte(value), decodeMsgpack(t, w.Body.Bytes())["data"], "invalid UTF-8 is sent as binary data")
+}
+
+func TestMessagePackErrors(t *testing.T) {
+	router, fake := newRouter(t)
+	fake.Handle("GET", func([]string) fakedice.Reply {
+		return fakedice.Error("WRONGTYPE Operation against a key holding the wrong kind of value")
+	})
+
+	w := exec(router, "/shell/exec/get", "application/msgpack", "k")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	apiErr, ok := decodeMsgpack(t, w.Body.Bytes())["error"].(map[interface{}]interface{})
+	require.True(t, ok, "error object in %q", w.Body.String())
+	assert.Equal(t, string(apierror.CodeWrongType), apiErr["code"])
+
+	r := httptest.NewRequest(http.MethodPost, "/shell/exec/get", bytes.NewReader(encodeMsgpack(t, []interface{}{int64(1)})))
+	r.Header.Set("Content-Type", "application/msgpack")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), string(apierror.CodeInvalidRequest))
+}
+
+func TestHashAsCSV(t *testing.T) {
+	router, fake := newRouter(t)
+	fake.Handle("HGETALL", func([]string) fakedice.Reply {
+		return fakedice.Array(
+			fakedice.BulkString("name"), fakedice.BulkString("dice"),
+			fakedice.BulkString("motto"), fakedice.BulkString("fast, \"reactive\""),
+		)
+	})
+
+	for _, request := range []struct{ target, accept string }{
+		{"/shell/exec/hgetall", "text/csv"},
+		{"/shell/exec/hgetall?format=csv", ""},
+	} {
+		w := exec(router, request.target, request.accept, "h")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Equal(t, "field,value\nname,dice\nmotto,\"fast, \"\"reactive\"\"\"\n", w.Body.String())
+	}
+}
+
+func TestSortedSetWithScoresAsNDJSON(t *testing.T) {
+	router, fake := newRouter(t)
+	fake.Handle("ZRANGE", func([]string) fakedice.Reply {
+		return fakedice.Array(fakedice.BulkString("a"), fakedice.BulkString("1"), fakedice.BulkString("b"), fakedice.BulkString("2.5"))
+	})
+
+	w := exec(router, "/shell/exec/zrange?format=ndjson", "", "z", "0", "-1", "WITHSCORES")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	assert.Equal(t, "{\"member\":\"a\",\"score\":\"1\"}\n{\"member\":\"b\",\"score\":\"2.5\"}\n", w.Body.String())
+
+	w = exec(router, "/shell/exec/zrange?format=ndjson", "", "z", "0", "-1")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "{\"value\":\"a\"}\n{\"value\":\"1\"}\n{\"value\":\"b\"}\n{\"value\":\"2.5\"}\n", w.Body.String(),
+		"without WITHSCORES the reply is a plain list")
+}
+
+func TestScalarsAndNestedArrays(t *testing.T) {
+	router, fake := newRouter(t)
+	fake.Handle("INCR", func([]string) fakedice.Reply { return fakedice.Integer(7) })
+	fake.Handle("SCAN", func([]string) fakedice.Reply {
+		return fakedice.Array(fakedice.BulkString("0"), fakedice.Array(fakedice.BulkString("k1"), fakedice.BulkString("k2")))
+	})
+
+	w := exec(router, "/shell/exec/incr", "application/x-ndjson", "n")
+	assert.Equal(t, "{\"value\":7}\n", w.Body.String())
+	w = exec(router, "/shell/exec/incr", "text/csv", "n")
+	assert.Equal(t, "value\n7\n", w.Body.String())
+
+	w = exec(router, "/shell/exec/scan", "application/x-ndjson", "0")
+	assert.Equal(t, "[\"0\"]\n[\"k1\",\"k2\"]\n", w.Body.String())
+	w = exec(router, "/shell/exec/scan", "text/csv", "0")
+	assert.Equal(t, "0\nk1,k2\n", w.Body.String())
+}
+
+func TestNDJSONErrors(t *testing.T) {
+	router, _ := newRouter(t)
+
+	w := exec(router, "/shell/exec/frobnicate?format=ndjson", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var resp apierror.Response
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Equal(t, apierror.CodeUnknownCommand, resp.Error.Code)
+}
+
+func TestUnsupportedFormat(t *testing.T) {
+	router, _ := newRouter(t)
+
+	w := exec(router, "/shell/exec/get?format=xml", "", "k")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	body, err := io.ReadAll(w.Body)
+	require.NoError(t, err)
+	var resp apierror.Response
+	require.NoError(t, json.Unmarshal(body, &resp))
+	assert.Equal(t, apierror.CodeInvalidRequest, resp.Error.Code)
+	assert.Contains(t, resp.Error.Message, "xml")
+}
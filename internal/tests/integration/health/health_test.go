@@ -0,0 +1,74 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"server/internal/server"
+	"server/internal/tests/fakedice"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ready(t *testing.T, checker *server.HealthChecker) (int, server.ReadinessReport) {
+	w := httptest.NewRecorder()
+	checker.Ready(w, httptest.NewRequest(http.MethodGet, "/health/ready", http.NoBody))
+
+	var report server.ReadinessReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	return w.Code, report
+}
+
+func TestLiveness(t *testing.T) {
+	checker := server.NewHealthChecker(nil, nil, nil, time.Second, time.Second)
+
+	w := httptest.NewRecorder()
+	checker.Live(w, httptest.NewRequest(http.MethodGet, "/health/live", http.NoBody))
+	assert.Equal(t, http.StatusOK, w.Code, "liveness should not depend on DiceDB")
+}
+
+func TestReadinessAllDependenciesUp(t *testing.T) {
+	clients := fakedice.NewClients(t)
+	cleanupManager := server.NewCleanupManager(clients.Admin, clients.User, time.Hour)
+	checker := server.NewHealthChecker(clients.Admin, clients.User, cleanupManager, time.Second, 0)
+
+	code, report := ready(t, checker)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, server.HealthStatusOK, report.Status)
+	assert.Equal(t, server.DependencyStatusUp, report.Dependencies["dicedb_admin"].Status)
+	assert.Equal(t, server.DependencyStatusUp, report.Dependencies["dicedb"].Status)
+	require.NotNil(t, report.CleanupCron)
+	assert.False(t, report.CleanupCron.Running, "cleanup cron was never started")
+	assert.Nil(t, report.CleanupCron.LastSuccess)
+}
+
+func TestReadinessDegradedWhenDependencyDown(t *testing.T) {
+	clients := fakedice.NewClients(t)
+	checker := server.NewHealthChecker(clients.Admin, clients.User, nil, 200*time.Millisecond, 0)
+
+	clients.UserFake.Close()
+
+	code, report := ready(t, checker)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, server.HealthStatusDegraded, report.Status)
+	assert.Equal(t, server.DependencyStatusUp, report.Dependencies["dicedb_admin"].Status)
+	assert.Equal(t, server.DependencyStatusDown, report.Dependencies["dicedb"].Status)
+	assert.NotEmpty(t, report.Dependencies["dicedb"].Error)
+}
+
+func TestReadinessIsCached(t *testing.T) {
+	clients := fakedice.NewClients(t)
+	checker := server.NewHealthChecker(clients.Admin, clients.User, nil, time.Second, time.Minute)
+
+	adminPings, userPings := clients.AdminFake.Calls("PING"), clients.UserFake.Calls("PING")
+	for i := 0; i < 10; i++ {
+		code, _ := ready(t, checker)
+		require.Equal(t, http.StatusOK, code)
+	}
+
+	assert.Equal(t, adminPings+1, clients.AdminFake.Calls("PING"), "admin DiceDB should be pinged once within the cache TTL")
+	assert.Equal(t, userPings+1, clients.UserFake.Calls("PING"), "user DiceDB should be pinged once within the cache TTL")
+}
@@ -0,0 +1,256 @@
+package keys
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"server/internal/apierror"
+	"server/internal/db"
+	"server/internal/keyspace"
+	"server/internal/middleware"
+	"server/internal/server"
+	"server/internal/tests/fakedice"
+	"sort"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fixture struct {
+	router   *gin.Engine
+	user     *db.DiceDB
+	userFake *fakedice.Server
+}
+
+func setup(t *testing.T, limit int64) *fixture {
+	clients := fakedice.NewClients(t)
+
+	httpServer := &server.HTTPServer{DiceClient: clients.User}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.NewRateLimiterMiddleware(clients.Admin, limit, 60).Exec)
+	router.GET("/keys", gin.WrapF(httpServer.KeysHandler))
+	router.GET("/keys/*key", gin.WrapF(httpServer.KeyHandler))
+	return &fixture{router: router, user: clients.User, userFake: clients.UserFake}
+}
+
+func (f *fixture) do(t *testing.T, args ...interface{}) {
+	require.NoError(t, f.user.Client.Do(context.Background(), args...).Err())
+}
+
+func (f *fixture) get(target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	return w
+}
+
+func decode[T any](t *testing.T, w *httptest.ResponseRecorder) T {
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		Data T `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp.Data
+}
+
+func decodeError(t *testing.T, w *httptest.ResponseRecorder, status int) apierror.Code {
+	require.Equal(t, status, w.Code, w.Body.String())
+	var resp struct {
+		Error apierror.Error `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp.Error.Code
+}
+
+func TestListKeys(t *testing.T) {
+	f := setup(t, 1000)
+	f.do(t, "SET", "greeting", "hello")
+	f.do(t, "HINCRBY", "user:1", "visits", 3)
+	f.do(t, "LPUSH", "queue", "a", "b")
+	f.do(t, "PFADD", "visitors", "alice", "bob")
+	f.userFake.Handle("MEMORY", func(args []string) fakedice.Reply { return fakedice.Integer(56) })
+
+	page := decode[keyspace.Page](t, f.get("/keys"))
+	assert.True(t, page.Done)
+	assert.Equal(t, "0", page.Cursor)
+	types := make(map[string]string)
+	for _, key := range page.Keys {
+		types[key.Name] = key.Type
+		assert.Equal(t, int64(-1), key.TTL)
+		require.NotNil(t, key.MemoryBytes)
+		assert.Equal(t, int64(56), *key.MemoryBytes)
+	}
+	assert.Equal(t, map[string]string{
+		"greeting": keyspace.TypeString,
+		"user:1":   keyspace.TypeHash,
+		"queue":    keyspace.TypeList,
+		"visitors": keyspace.TypeHyperLogLog,
+	}, types)
+}
+
+func TestMemoryIsOptional(t *testing.T) {
+	f := setup(t, 1000)
+	f.do(t, "SET", "greeting", "hello")
+
+	// The fake server does not know MEMORY USAGE
+	page := decode[keyspace.Page](t, f.get("/keys"))
+	require.Len(t, page.Keys, 1)
+	assert.Nil(t, page.Keys[0].MemoryBytes)
+	assert.NotContains(t, f.get("/keys").Body.String(), "memory_bytes")
+}
+
+func TestPageThroughKeys(t *testing.T) {
+	f := setup(t, 1000)
+	for i := 0; i < 25; i++ {
+		f.do(t, "SET", fmt.Sprintf("key:%02d", i), i)
+	}
+
+	var names []string
+	cursor := "0"
+	for pages := 1; ; pages++ {
+		require.LessOrEqual(t, pages, 3)
+		page := decode[keyspace.Page](t, f.get("/keys?count=10&cursor="+cursor))
+		assert.LessOrEqual(t, len(page.Keys), 10)
+		for _, key := range page.Keys {
+			names = append(names, key.Name)
+		}
+		if page.Done {
+			break
+		}
+		cursor = page.Cursor
+	}
+	sort.Strings(names)
+	require.Len(t, names, 25)
+	assert.Equal(t, "key:00", names[0])
+	assert.Equal(t, "key:24", names[24])
+}
+
+func TestFilterKeys(t *testing.T) {
+	f := setup(t, 1000)
+	f.do(t, "SET", "user:name", "alice")
+	f.do(t, "HINCRBY", "user:1", "visits", 1)
+	f.do(t, "HINCRBY", "stats", "visits", 1)
+
+	page := decode[keyspace.Page](t, f.get("/keys?pattern="+url.QueryEscape("user:*")))
+	assert.ElementsMatch(t, []string{"user:name", "user:1"}, names(page))
+
+	page = decode[keyspace.Page](t, f.get("/keys?type=hash"))
+	assert.ElementsMatch(t, []string{"user:1", "stats"}, names(page))
+
+	page = decode[keyspace.Page](t, f.get("/keys?type=hash&pattern="+url.QueryEscape("user:*")))
+	assert.Equal(t, []string{"user:1"}, names(page))
+}
+
+func TestInvalidListRequests(t *testing.T) {
+	f := setup(t, 1000)
+	for _, target := range []string{"/keys?cursor=next", "/keys?type=json", "/keys?count=0", "/keys?count=5000"} {
+		assert.Equal(t, apierror.CodeInvalidRequest, decodeError(t, f.get(target), http.StatusBadRequest), target)
+	}
+}
+
+func TestKeyValues(t *testing.T) {
+	f := setup(t, 1000)
+	f.do(t, "SET", "greeting", "hello")
+	f.do(t, "HINCRBY", "user:1", "visits", 3)
+	f.do(t, "LPUSH", "queue", "c", "b", "a")
+	f.do(t, "PFADD", "visitors", "alice", "bob", "carol")
+	f.do(t, "SET", "path/to/key", "nested")
+
+	value := decode[map[string]interface{}](t, f.get("/keys/greeting"))
+	assert.Equal(t, "string", value["type"])
+	assert.Equal(t, "hello", value["value"])
+	assert.Equal(t, float64(5), value["length"])
+	assert.Equal(t, float64(-1), value["ttl"])
+
+	value = decode[map[string]interface{}](t, f.get("/keys/user:1"))
+	assert.Equal(t, "hash", value["type"])
+	assert.Equal(t, map[string]interface{}{"visits": "3"}, value["value"])
+
+	value = decode[map[string]interface{}](t, f.get("/keys/queue"))
+	assert.Equal(t, "list", value["type"])
+	assert.Equal(t, []interface{}{"a", "b", "c"}, value["value"])
+
+	value = decode[map[string]interface{}](t, f.get("/keys/visitors"))
+	assert.Equal(t, "hyperloglog", value["type"])
+	assert.Equal(t, float64(3), value["value"])
+
+	value = decode[map[string]interface{}](t, f.get("/keys/"+url.PathEscape("path/to/key")))
+	assert.Equal(t, "path/to/key", value["name"])
+	assert.Equal(t, "nested", value["value"])
+}
+
+func TestSetAndSortedSetValues(t *testing.T) {
+	f := setup(t, 1000)
+	f.userFake.Handle("TYPE", func(args []string) fakedice.Reply {
+		if args[0] == "tags" {
+			return fakedice.SimpleString("set")
+		}
+		return fakedice.SimpleString("zset")
+	})
+	f.userFake.Handle("SMEMBERS", func([]string) fakedice.Reply {
+		return fakedice.Array(fakedice.BulkString("go"), fakedice.BulkString("db"))
+	})
+	f.userFake.Handle("ZCARD", func([]string) fakedice.Reply { return fakedice.Integer(3) })
+	f.userFake.Handle("ZRANGE", func(args []string) fakedice.Reply {
+		assert.Equal(t, []string{"scores", "0", "1", "WITHSCORES"}, args)
+		return fakedice.Array(fakedice.BulkString("alice"), fakedice.BulkString("1.5"),
+			fakedice.BulkString("bob"), fakedice.BulkString("2"))
+	})
+
+	set := decode[keyspace.Value](t, f.get("/keys/tags"))
+	assert.Equal(t, keyspace.TypeSet, set.Type)
+	assert.Equal(t, []interface{}{"db", "go"}, set.Value)
+
+	zset := decode[map[string]interface{}](t, f.get("/keys/scores?limit=2"))
+	assert.Equal(t, "zset", zset["type"])
+	assert.Equal(t, float64(3), zset["length"])
+	assert.Equal(t, true, zset["truncated"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"member": "alice", "score": 1.5},
+		map[string]interface{}{"member": "bob", "score": float64(2)},
+	}, zset["value"])
+}
+
+func TestTruncatedValues(t *testing.T) {
+	f := setup(t, 1000)
+	f.do(t, "LPUSH", "queue", "e", "d", "c", "b", "a")
+
+	value := decode[map[string]interface{}](t, f.get("/keys/queue?limit=2"))
+	assert.Equal(t, []interface{}{"a", "b"}, value["value"])
+	assert.Equal(t, float64(5), value["length"])
+	assert.Equal(t, true, value["truncated"])
+
+	value = decode[map[string]interface{}](t, f.get("/keys/queue"))
+	assert.Len(t, value["value"], 5)
+	assert.NotContains(t, value, "truncated")
+
+	assert.Equal(t, apierror.CodeInvalidRequest, decodeError(t, f.get("/keys/queue?limit=0"), http.StatusBadRequest))
+}
+
+func TestMissingKey(t *testing.T) {
+	f := setup(t, 1000)
+	assert.Equal(t, apierror.CodeNotFound, decodeError(t, f.get("/keys/missing"), http.StatusNotFound))
+}
+
+func TestKeyBrowserIsRateLimited(t *testing.T) {
+	f := setup(t, 2)
+	f.do(t, "SET", "greeting", "hello")
+
+	decode[keyspace.Page](t, f.get("/keys"))
+	decode[keyspace.Value](t, f.get("/keys/greeting"))
+	assert.Equal(t, apierror.CodeRateLimited, decodeError(t, f.get("/keys"), http.StatusTooManyRequests))
+	assert.Equal(t, apierror.CodeRateLimited, decodeError(t, f.get("/keys/greeting"), http.StatusTooManyRequests))
+}
+
+func names(page keyspace.Page) []string {
+	names := make([]string, len(page.Keys))
+	for i, key := range page.Keys {
+		names[i] = key.Name
+	}
+	return names
+}
@@ -0,0 +1,162 @@
+package logging
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"server/config"
+	"server/internal/logging"
+	"server/internal/middleware"
+	"server/internal/server"
+	"server/internal/tests/fakedice"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRouter installs a logger writing to the returned buffer and builds a router
+// logging requests executed against fake DiceDB instances
+func newRouter(t *testing.T, loggingConfig config.LoggingConfig) (*gin.Engine, *bytes.Buffer) {
+	previous := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, logging.Init(&loggingConfig, buf))
+
+	clients := fakedice.NewClients(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.RequestLoggerMiddleware)
+	router.Use(middleware.NewRateLimiterMiddleware(clients.Admin, 1000, 60).Exec)
+	router.POST("/shell/exec/:cmd", gin.WrapF((&server.HTTPServer{DiceClient: clients.User}).CliHandler))
+	return router, buf
+}
+
+func exec(router *gin.Engine, requestID, cmd string, args ...string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(args)
+	r := httptest.NewRequest(http.MethodPost, "/shell/exec/"+cmd, bytes.NewReader(body))
+	if requestID != "" {
+		r.Header.Set(middleware.RequestIDHeader, requestID)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+// records parses the JSON log lines written to buf
+func records(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var lines []map[string]any
+	scanner := bufio.NewScanner(bytes.NewReader(buf.Bytes()))
+	for scanner.Scan() {
+		var line map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line), scanner.Text())
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func find(lines []map[string]any, msg string) map[string]any {
+	for _, line := range lines {
+		if line["msg"] == msg {
+			return line
+		}
+	}
+	return nil
+}
+
+func TestRequestLogLine(t *testing.T) {
+	router, buf := newRouter(t, config.LoggingConfig{Format: "json", Level: "debug", RedactArgs: true})
+
+	w := exec(router, "req-123", "set", "k", "secret-value")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "req-123", w.Header().Get(middleware.RequestIDHeader))
+
+	lines := records(t, buf)
+	access := find(lines, "Request completed")
+	require.NotNil(t, access, buf.String())
+	assert.Equal(t, "req-123", access["request_id"])
+	assert.Equal(t, "http", access["component"])
+	assert.Equal(t, "POST", access["method"])
+	assert.Equal(t, "/shell/exec/set", access["path"])
+	assert.Equal(t, "/shell/exec/:cmd", access["route"])
+	assert.Equal(t, "SET", access["command"])
+	assert.EqualValues(t, http.StatusOK, access["status"])
+	assert.Equal(t, "allowed", access["rate_limit"])
+	assert.Contains(t, access, "latency_ms")
+
+	// Handlers, middlewares and ExecuteCommand share the request-scoped logger
+	executing := find(lines, "Executing command")
+	require.NotNil(t, executing, buf.String())
+	assert.Equal(t, "req-123", executing["request_id"])
+	assert.Equal(t, "db", executing["component"])
+	assert.Equal(t, []any{"[REDACTED]", "[REDACTED]"}, executing["args"])
+	assert.Equal(t, "req-123", find(lines, "Created rate limiter key")["request_id"])
+
+	assert.NotContains(t, buf.String(), "secret-value")
+}
+
+func TestRequestIDIsGeneratedWhenMissingOrInvalid(t *testing.T) {
+	router, _ := newRouter(t, config.LoggingConfig{Level: "info", RedactArgs: true})
+
+	generated := exec(router, "", "get", "k").Header().Get(middleware.RequestIDHeader)
+	assert.Len(t, generated, 32)
+
+	forged := exec(router, "abc\"\ninjected", "get", "k").Header().Get(middleware.RequestIDHeader)
+	assert.Len(t, forged, 32)
+	assert.NotEqual(t, generated, forged)
+}
+
+func TestArgsAreLoggedWhenRedactionIsDisabled(t *testing.T) {
+	router, buf := newRouter(t, config.LoggingConfig{Level: "debug", RedactArgs: false})
+
+	require.Equal(t, http.StatusOK, exec(router, "", "set", "k", "visible-value").Code)
+	assert.Equal(t, []any{"k", "visible-value"}, find(records(t, buf), "Executing command")["args"])
+}
+
+func TestComponentLevelOverrides(t *testing.T) {
+	router, buf := newRouter(t, config.LoggingConfig{
+		Level:           "warn",
+		ComponentLevels: map[string]string{"db": "debug"},
+		RedactArgs:      true,
+	})
+
+	require.Equal(t, http.StatusOK, exec(router, "", "get", "k").Code)
+
+	lines := records(t, buf)
+	assert.NotNil(t, find(lines, "Executing command"), "db logs at debug")
+	assert.Nil(t, find(lines, "Request completed"), "http logs at the default warn level")
+	assert.Nil(t, find(lines, "Created rate limiter key"), "ratelimiter logs at the default warn level")
+
+	// Failed requests are still logged at warn
+	require.Equal(t, http.StatusBadRequest, exec(router, "", "notacommand").Code)
+	failed := find(records(t, buf), "Request completed")
+	require.NotNil(t, failed)
+	assert.Equal(t, "WARN", failed["level"])
+}
+
+func TestTextFormat(t *testing.T) {
+	router, buf := newRouter(t, config.LoggingConfig{Format: "text", Level: "info", RedactArgs: true})
+
+	require.Equal(t, http.StatusOK, exec(router, "req-text", "get", "k").Code)
+	assert.Contains(t, buf.String(), `msg="Request completed"`)
+	assert.Contains(t, buf.String(), "request_id=req-text")
+	assert.Contains(t, buf.String(), "command=GET")
+}
+
+func TestInvalidLoggingConfig(t *testing.T) {
+	_, err := logging.NewLogger(&config.LoggingConfig{Level: "verbose"}, &bytes.Buffer{})
+	assert.Error(t, err)
+
+	_, err = logging.NewLogger(&config.LoggingConfig{Format: "xml"}, &bytes.Buffer{})
+	assert.Error(t, err)
+
+	_, err = logging.NewLogger(&config.LoggingConfig{ComponentLevels: map[string]string{"db": "loud"}}, &bytes.Buffer{})
+	assert.True(t, err != nil && strings.Contains(err.Error(), "db"))
+}
@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"server/internal/metrics"
+	"server/internal/middleware"
+	"server/internal/server"
+	"server/internal/tests/fakedice"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRouter starts a fake DiceDB and returns an instrumented router executing commands against it
+func newRouter(t *testing.T) *gin.Engine {
+	clients := fakedice.NewClients(t)
+	require.NoError(t, metrics.RegisterPool(clients.User.Role(), clients.User.Client))
+
+	httpServer := &server.HTTPServer{DiceClient: clients.User}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.MetricsMiddleware)
+	router.POST("/shell/exec/:cmd", gin.WrapF(httpServer.CliHandler))
+	router.POST("/shell/tx", gin.WrapF(httpServer.TransactionHandler))
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+	return router
+}
+
+func exec(router *gin.Engine, cmd string, args ...string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(args)
+	r := httptest.NewRequest(http.MethodPost, "/shell/exec/"+cmd, strings.NewReader(string(body)))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func scrape(t *testing.T, router *gin.Engine) string {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody))
+	require.Equal(t, http.StatusOK, w.Code)
+	return w.Body.String()
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	router := newRouter(t)
+
+	require.Equal(t, http.StatusOK, exec(router, "SET", "k", "v").Code)
+	require.Equal(t, http.StatusOK, exec(router, "GET", "k").Code)
+	require.Equal(t, http.StatusBadRequest, exec(router, "NOTACOMMAND", "k").Code)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/no/such/route", http.NoBody))
+
+	body := scrape(t, router)
+
+	// HTTP requests are labelled by route template, never by raw path
+	assert.Contains(t, body, `playground_http_requests_total{method="POST",route="/shell/exec/:cmd",status="200"} 2`)
+	assert.Contains(t, body, `playground_http_requests_total{method="POST",route="/shell/exec/:cmd",status="400"} 1`)
+	assert.Contains(t, body, `playground_http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	assert.NotContains(t, body, "/no/such/route")
+
+	// Command latencies and errors, unknown commands are collapsed
+	assert.Contains(t, body, `playground_dicedb_command_duration_seconds_count{client="user",command="GET"} 1`)
+	assert.Contains(t, body, `playground_dicedb_command_duration_seconds_count{client="user",command="SET"} 1`)
+	assert.Contains(t, body, `playground_dicedb_command_errors_total{client="user",command="OTHER"} 1`)
+	assert.NotContains(t, body, `command="NOTACOMMAND"`)
+	assert.NotContains(t, body, `playground_dicedb_command_errors_total{client="user",command="GET"}`,
+		"successful commands are not errors")
+
+	// Pool statistics of the registered client
+	assert.Contains(t, body, `playground_dicedb_pool_connections{client="user"}`)
+	assert.Contains(t, body, `playground_dicedb_pool_hits_total{client="user"}`)
+}
+
+func TestTransactionCommandsAreObserved(t *testing.T) {
+	router := newRouter(t)
+
+	require.Equal(t, http.StatusOK, exec(router, "SET", "name", "alice").Code)
+	body, _ := json.Marshal(map[string]interface{}{"commands": [][]string{{"INCR", "visits"}, {"INCR", "name"}}})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/shell/tx", strings.NewReader(string(body))))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Every command of the pipeline is observed, failing ones as errors
+	metricsBody := scrape(t, router)
+	assert.Contains(t, metricsBody, `playground_dicedb_command_duration_seconds_count{client="user",command="INCR"} 2`)
+	assert.Contains(t, metricsBody, `playground_dicedb_command_duration_seconds_count{client="user",command="MULTI"}`)
+	assert.Contains(t, metricsBody, `playground_dicedb_command_duration_seconds_count{client="user",command="EXEC"}`)
+	assert.Contains(t, metricsBody, `playground_dicedb_command_errors_total{client="user",command="INCR"} 1`)
+}
+
+func TestCommandLabelIsBounded(t *testing.T) {
+	assert.Equal(t, "GET", metrics.CommandLabel("get"))
+	assert.Equal(t, "JSON.GET", metrics.CommandLabel("json.get"))
+	assert.Equal(t, metrics.OtherCommand, metrics.CommandLabel("definitely-not-a-command"))
+	assert.Equal(t, metrics.OtherCommand, metrics.CommandLabel(""))
+}
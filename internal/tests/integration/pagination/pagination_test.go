@@ -0,0 +1,147 @@
+package pagination
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"server/internal/apierror"
+	"server/internal/db"
+	"server/internal/middleware"
+	"server/internal/pagination"
+	"server/internal/server"
+	"server/internal/tests/fakedice"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const maxBytes = 64
+
+type fixture struct {
+	router    *gin.Engine
+	admin     *db.DiceDB
+	adminFake *fakedice.Server
+	userFake  *fakedice.Server
+}
+
+type response struct {
+	Data        string `json:"data"`
+	Truncated   bool   `json:"truncated"`
+	TotalLength int    `json:"total_length"`
+	Cursor      string `json:"cursor"`
+}
+
+func setup(t *testing.T) *fixture {
+	clients := fakedice.NewClients(t)
+
+	httpServer := &server.HTTPServer{DiceClient: clients.User, Pager: pagination.New(clients.Admin, maxBytes, time.Minute)}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.RequestLoggerMiddleware)
+	router.POST("/shell/exec/:cmd", gin.WrapF(httpServer.CliHandler))
+	router.GET("/shell/cursor/:cursor", gin.WrapF(httpServer.CursorHandler))
+	return &fixture{router: router, admin: clients.Admin, adminFake: clients.AdminFake, userFake: clients.UserFake}
+}
+
+func (f *fixture) exec(t *testing.T, cmd string, args ...string) response {
+	body, _ := json.Marshal(args)
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/shell/exec/"+cmd, bytes.NewReader(body)))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp response
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp
+}
+
+func (f *fixture) next(cursor string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/shell/cursor/"+cursor, nil))
+	return w
+}
+
+func keys(n int) fakedice.Reply {
+	items := make([]fakedice.Reply, n)
+	for i := range items {
+		items[i] = fakedice.BulkString(fmt.Sprintf("key:%02d", i))
+	}
+	return fakedice.Array(items...)
+}
+
+func TestSmallRepliesAreNotTruncated(t *testing.T) {
+	f := setup(t)
+	f.userFake.Handle("KEYS", func([]string) fakedice.Reply { return keys(2) })
+
+	resp := f.exec(t, "KEYS", "*")
+	assert.False(t, resp.Truncated)
+	assert.Empty(t, resp.Cursor)
+	assert.Zero(t, resp.TotalLength)
+	assert.Contains(t, resp.Data, "key:01")
+}
+
+func TestLargeRepliesArePaginated(t *testing.T) {
+	f := setup(t)
+	f.userFake.Handle("KEYS", func([]string) fakedice.Reply { return keys(40) })
+
+	first := f.exec(t, "KEYS", "*")
+	require.True(t, first.Truncated)
+	require.NotEmpty(t, first.Cursor)
+	assert.LessOrEqual(t, len(first.Data), maxBytes)
+	assert.True(t, strings.HasSuffix(first.Data, "\n"), "pages end on a line break")
+
+	full := first.Data
+	cursor := first.Cursor
+	for pages := 1; cursor != ""; pages++ {
+		require.Less(t, pages, 100, "cursor does not advance")
+		w := f.next(cursor)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var page response
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		assert.LessOrEqual(t, len(page.Data), maxBytes)
+		full += page.Data
+		cursor = page.Cursor
+		if cursor != "" {
+			assert.Equal(t, first.TotalLength, page.TotalLength)
+		}
+	}
+
+	assert.Len(t, full, first.TotalLength)
+	for i := 0; i < 40; i++ {
+		assert.Contains(t, full, fmt.Sprintf("key:%02d", i))
+	}
+	assert.Zero(t, f.adminFake.Calls("GET"), "pages are read without fetching the whole reply")
+	assert.NotZero(t, f.adminFake.Calls("GETRANGE"))
+}
+
+func TestInvalidCursor(t *testing.T) {
+	f := setup(t)
+
+	w := f.next("not-a-cursor")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var resp apierror.Response
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, apierror.CodeInvalidRequest, resp.Error.Code)
+}
+
+func TestExpiredCursor(t *testing.T) {
+	f := setup(t)
+	f.userFake.Handle("KEYS", func([]string) fakedice.Reply { return keys(40) })
+
+	first := f.exec(t, "KEYS", "*")
+	require.NotEmpty(t, first.Cursor)
+
+	// Drop the cached reply as its TTL would
+	require.NoError(t, f.admin.Client.FlushDB(context.Background()).Err())
+
+	w := f.next(first.Cursor)
+	assert.Equal(t, http.StatusGone, w.Code)
+	var resp apierror.Response
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, apierror.CodeCursorExpired, resp.Error.Code)
+}
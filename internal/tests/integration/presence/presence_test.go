@@ -0,0 +1,139 @@
+package presence
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"server/internal/analytics"
+	"server/internal/middleware"
+	"server/internal/presence"
+	"server/internal/server"
+	"server/internal/tests/fakedice"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fixture struct {
+	router    *gin.Engine
+	tracker   *presence.Tracker
+	adminFake *fakedice.Server
+}
+
+func setup(t *testing.T, cacheTTL time.Duration) *fixture {
+	clients := fakedice.NewClients(t)
+
+	tracker := presence.NewTracker(clients.Admin, 5*time.Minute, 30*time.Minute, cacheTTL)
+	httpServer := &server.HTTPServer{DiceClient: clients.User, Presence: tracker}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.RequestLoggerMiddleware)
+	router.Use(middleware.NewPresenceMiddleware(tracker).Exec)
+	router.POST("/shell/exec/:cmd", gin.WrapF(httpServer.CliHandler))
+	router.GET("/presence", gin.WrapF(httpServer.PresenceHandler))
+	return &fixture{router: router, tracker: tracker, adminFake: clients.AdminFake}
+}
+
+func (f *fixture) exec(remoteAddr, sessionID string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal([]string{"k1"})
+	r := httptest.NewRequest(http.MethodPost, "/shell/exec/get", bytes.NewReader(body))
+	r.RemoteAddr = remoteAddr
+	if sessionID != "" {
+		r.Header.Set(analytics.SessionIDHeader, sessionID)
+	}
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, r)
+	return w
+}
+
+func (f *fixture) presence(t *testing.T, remoteAddr string) presence.Snapshot {
+	r := httptest.NewRequest(http.MethodGet, "/presence", http.NoBody)
+	r.RemoteAddr = remoteAddr
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var body struct {
+		Data presence.Snapshot `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	return body.Data
+}
+
+func TestActiveClients(t *testing.T) {
+	f := setup(t, 0)
+
+	for i := 0; i < 3; i++ {
+		require.Equal(t, http.StatusOK, f.exec("10.0.0.1:1234", "").Code)
+	}
+	require.Equal(t, http.StatusOK, f.exec("10.0.0.2:1234", "").Code)
+	// Clients sending a session ID are counted per session
+	require.Equal(t, http.StatusOK, f.exec("10.0.0.2:1234", "tab-1").Code)
+	require.Equal(t, http.StatusOK, f.exec("10.0.0.2:1234", "tab-2").Code)
+	assert.Equal(t, 4, f.adminFake.Calls("PFADD"), "clients are written once per minute")
+
+	// The caller of /presence is counted as well
+	snapshot := f.presence(t, "10.0.0.3:1234")
+	assert.Equal(t, int64(5), snapshot.Active)
+	assert.Equal(t, int64(300), snapshot.WindowSeconds)
+	require.Len(t, snapshot.History, 30)
+	assert.Equal(t, int64(5), snapshot.History[29].Active)
+	assert.True(t, snapshot.History[0].Time.Before(snapshot.History[29].Time))
+}
+
+func TestSlidingWindow(t *testing.T) {
+	f := setup(t, 0)
+	ctx := context.Background()
+	now := time.Now()
+
+	require.NoError(t, f.tracker.Touch(ctx, "recent", now.Add(-4*time.Minute)))
+	require.NoError(t, f.tracker.Touch(ctx, "gone", now.Add(-10*time.Minute)))
+	require.NoError(t, f.tracker.Touch(ctx, "current", now))
+
+	snapshot, err := f.tracker.Snapshot(ctx, now)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), snapshot.Active, "clients outside the window are no longer active")
+
+	var total int64
+	for _, interval := range snapshot.History {
+		total += interval.Active
+	}
+	assert.Equal(t, int64(3), total, "the history still shows clients outside the window")
+}
+
+func TestSnapshotIsCached(t *testing.T) {
+	f := setup(t, time.Minute)
+	ctx := context.Background()
+	now := time.Now()
+
+	require.NoError(t, f.tracker.Touch(ctx, "a", now))
+	first, err := f.tracker.Snapshot(ctx, now)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), first.Active)
+
+	require.NoError(t, f.tracker.Touch(ctx, "b", now))
+	cached, err := f.tracker.Snapshot(ctx, now.Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), cached.Active)
+
+	refreshed, err := f.tracker.Snapshot(ctx, now.Add(2*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), refreshed.Active, "the snapshot is refreshed once the cache expires")
+}
+
+func TestPresenceFailureDoesNotFailRequests(t *testing.T) {
+	f := setup(t, 0)
+	f.adminFake.Handle("PFADD", func(args []string) fakedice.Reply {
+		return fakedice.Error("ERR out of memory")
+	})
+
+	require.Equal(t, http.StatusOK, f.exec("10.0.0.1:1234", "").Code)
+	require.Equal(t, http.StatusOK, f.exec("10.0.0.1:1234", "").Code)
+	assert.Equal(t, 2, f.adminFake.Calls("PFADD"), "failed writes are retried on the next request")
+}
@@ -38,7 +38,7 @@ func TestRateLimiterExceedsLimit(t *testing.T) {
 	w = httptest.NewRecorder()
 	rateLimiter.ServeHTTP(w, r)
 	require.Equal(t, http.StatusTooManyRequests, w.Code)
-	require.Contains(t, w.Body.String(), "429 - Too Many Requests")
+	require.Contains(t, w.Body.String(), `"code":"RATE_LIMITED"`)
 }
 
 func TestRateLimitHeadersSet(t *testing.T) {
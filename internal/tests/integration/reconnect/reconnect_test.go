@@ -0,0 +1,97 @@
+package reconnect
+
+import (
+	"context"
+	"net"
+	"server/config"
+	"server/internal/db"
+	"server/internal/tests/fakedice"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// freeAddr reserves a free local port and releases it so that nothing listens on it yet
+func freeAddr(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+	return addr
+}
+
+func newMonitoredClient(t *testing.T, addr string) *db.DiceDB {
+	configValue := config.LoadConfig()
+	configValue.DiceDB.Addr = addr
+
+	client, err := db.NewDiceClient(configValue, false)
+	require.NoError(t, err, "creating a client should not require DiceDB to be up")
+	t.Cleanup(client.CloseDiceDB)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go client.Monitor(ctx, 100*time.Millisecond, 200*time.Millisecond)
+
+	return client
+}
+
+func TestStartupWaitsForDelayedListener(t *testing.T) {
+	addr := freeAddr(t)
+	client := newMonitoredClient(t, addr)
+	assert.Equal(t, db.StateConnecting, client.State())
+
+	time.AfterFunc(500*time.Millisecond, func() {
+		fake, err := fakedice.NewServerAt(addr)
+		if err != nil {
+			t.Errorf("failed to start delayed listener: %v", err)
+			return
+		}
+		t.Cleanup(fake.Close)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, client.WaitConnected(ctx), "should connect once the listener appears")
+	assert.Equal(t, db.StateConnected, client.State())
+}
+
+func TestStartupDeadlineLeavesClientDegraded(t *testing.T) {
+	client := newMonitoredClient(t, freeAddr(t))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	err := client.WaitConnected(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, db.StateConnecting, client.State(), "client should still be usable once DiceDB comes up")
+}
+
+func TestReconnectsAfterConnectionLoss(t *testing.T) {
+	addr := freeAddr(t)
+	fake, err := fakedice.NewServerAt(addr)
+	require.NoError(t, err)
+
+	client := newMonitoredClient(t, addr)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, client.WaitConnected(ctx))
+
+	fake.Close()
+	require.Eventually(t, func() bool {
+		return client.State() == db.StateDisconnected
+	}, 5*time.Second, 20*time.Millisecond, "should notice the connection loss")
+
+	fake, err = fakedice.NewServerAt(addr)
+	require.NoError(t, err)
+	defer fake.Close()
+
+	require.NoError(t, client.WaitConnected(ctx), "should reconnect once DiceDB is back")
+	require.NoError(t, client.Ping(ctx))
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 100*time.Millisecond, db.Backoff(0, 100*time.Millisecond, time.Second))
+	assert.Equal(t, 400*time.Millisecond, db.Backoff(2, 100*time.Millisecond, time.Second))
+	assert.Equal(t, time.Second, db.Backoff(10, 100*time.Millisecond, time.Second))
+}
@@ -0,0 +1,175 @@
+package resp
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"server/internal/db"
+	"server/internal/middleware"
+	"server/internal/resp"
+	"server/internal/server"
+	"server/internal/tests/fakedice"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply interface{}
+		resp2 string
+		resp3 string
+	}{
+		{"status", db.StatusReply("OK"), "+OK\r\n", "+OK\r\n"},
+		{"error", db.ErrorReply("ERR unknown command"), "-ERR unknown command\r\n", "-ERR unknown command\r\n"},
+		{"error with line breaks", db.ErrorReply("ERR bad\r\nthing"), "-ERR bad  thing\r\n", "-ERR bad  thing\r\n"},
+		{"bulk string", "hello", "$5\r\nhello\r\n", "$5\r\nhello\r\n"},
+		{"empty bulk string", "", "$0\r\n\r\n", "$0\r\n\r\n"},
+		{"binary bulk string", "a\r\nb\x00", "$5\r\na\r\nb\x00\r\n", "$5\r\na\r\nb\x00\r\n"},
+		{"multi-byte bulk string", "héllo", "$6\r\nhéllo\r\n", "$6\r\nhéllo\r\n"},
+		{"integer", int64(42), ":42\r\n", ":42\r\n"},
+		{"negative integer", int64(-7), ":-7\r\n", ":-7\r\n"},
+		{"nil", nil, "$-1\r\n", "_\r\n"},
+		{"double", 1.5, "$3\r\n1.5\r\n", ",1.5\r\n"},
+		{"infinite double", math.Inf(-1), "$4\r\n-inf\r\n", ",-inf\r\n"},
+		{"true", true, ":1\r\n", "#t\r\n"},
+		{"false", false, ":0\r\n", "#f\r\n"},
+		{"empty array", []interface{}{}, "*0\r\n", "*0\r\n"},
+		{
+			"array",
+			[]interface{}{"a", int64(1), nil},
+			"*3\r\n$1\r\na\r\n:1\r\n$-1\r\n",
+			"*3\r\n$1\r\na\r\n:1\r\n_\r\n",
+		},
+		{
+			"nested array",
+			[]interface{}{"0", []interface{}{"k1", []interface{}{}}, db.ErrorReply("ERR x")},
+			"*3\r\n$1\r\n0\r\n*2\r\n$2\r\nk1\r\n*0\r\n-ERR x\r\n",
+			"*3\r\n$1\r\n0\r\n*2\r\n$2\r\nk1\r\n*0\r\n-ERR x\r\n",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.resp2, string(resp.Encode(tc.reply, resp.RESP2)))
+			assert.Equal(t, tc.resp3, string(resp.Encode(tc.reply, resp.RESP3)))
+		})
+	}
+}
+
+func TestAppendReply(t *testing.T) {
+	buf := resp.AppendReply([]byte("+PONG\r\n"), int64(1), resp.RESP2)
+	assert.Equal(t, "+PONG\r\n:1\r\n", string(buf))
+}
+
+func TestDecodeArgs(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"empty", "", nil},
+		{"array", "*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$5\r\nhello\r\n", []string{"SET", "k", "hello"}},
+		{"binary safe", "*1\r\n$4\r\na\r\nb\r\n", []string{"a\r\nb"}},
+		{"empty bulk string", "*1\r\n$0\r\n\r\n", []string{""}},
+		{"empty array", "*0\r\n", []string{}},
+		{"inline", "GET k1\r\n", []string{"GET", "k1"}},
+		{"inline without CRLF", "GET  k1", []string{"GET", "k1"}},
+		{"inline with LF", "GET k1\n", []string{"GET", "k1"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			args, err := resp.DecodeArgs([]byte(tc.input))
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, args)
+		})
+	}
+}
+
+func TestDecodeArgsErrors(t *testing.T) {
+	for _, input := range []string{
+		"*2\r\n$3\r\nGET\r\n",                   // missing element
+		"*1\r\n$10\r\nshort\r\n",                // truncated bulk string
+		"*1\r\n$3\r\nGETX\r\n",                  // bulk string longer than announced
+		"*1\r\n:1\r\n",                          // not a bulk string
+		"*x\r\n",                                // invalid count
+		"*-1\r\n",                               // null arrays are not commands
+		"*1",                                    // missing CRLF
+		"*1\r\n$1\r\na\r\n*1\r\n$1\r\nb\r\n",    // more than one command
+		"GET a\r\nGET b\r\n",                    // more than one inline command
+		"*1\r\n$9223372036854775807\r\nGET\r\n", // bulk length overflowing length+2
+		"*1048576\r\n",                          // count far beyond the data
+	} {
+		_, err := resp.DecodeArgs([]byte(input))
+		assert.ErrorIs(t, err, resp.ErrProtocol, "%q", input)
+	}
+}
+
+func newRouter(t *testing.T) (*gin.Engine, *fakedice.Server) {
+	clients := fakedice.NewClients(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.RequestLoggerMiddleware)
+	router.POST("/shell/exec/:cmd", gin.WrapF((&server.HTTPServer{DiceClient: clients.User}).CliHandler))
+	return router, clients.UserFake
+}
+
+func exec(router *gin.Engine, path, contentType, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, path, bytes.NewReader([]byte(body)))
+	r.Header.Set("Accept", "application/x-resp")
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func TestRESPResponses(t *testing.T) {
+	router, fake := newRouter(t)
+
+	args, _ := json.Marshal([]string{"k1", "v1"})
+	w := exec(router, "/shell/exec/set", "application/json", string(args))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-resp", w.Header().Get("Content-Type"))
+	assert.Equal(t, "+OK\r\n", w.Body.String())
+
+	w = exec(router, "/shell/exec/get", "application/x-resp", "*1\r\n$2\r\nk1\r\n")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "$2\r\nv1\r\n", w.Body.String())
+
+	w = exec(router, "/shell/exec/get", "application/x-resp", "*1\r\n$7\r\nmissing\r\n")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "$-1\r\n", w.Body.String())
+
+	w = exec(router, "/shell/exec/get?protocol=3", "application/x-resp", "*1\r\n$7\r\nmissing\r\n")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "_\r\n", w.Body.String())
+
+	fake.Handle("LRANGE", func(args []string) fakedice.Reply {
+		return fakedice.Array(fakedice.BulkString("a"), fakedice.Array(fakedice.Integer(1)), fakedice.Nil())
+	})
+	w = exec(router, "/shell/exec/lrange", "application/x-resp", "LRANGE l 0 -1")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "*3\r\n$1\r\na\r\n*1\r\n:1\r\n$-1\r\n", w.Body.String())
+}
+
+func TestRESPErrors(t *testing.T) {
+	router, _ := newRouter(t)
+
+	w := exec(router, "/shell/exec/set", "application/x-resp", "*1\r\n$2\r\nk1\r\n*1\r\n$2\r\nv1\r\n")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Regexp(t, `^-INVALID_REQUEST protocol error: [^\r\n]+\r\n$`, w.Body.String())
+
+	require.Equal(t, http.StatusOK, exec(router, "/shell/exec/set", "application/x-resp", "*2\r\n$2\r\nk1\r\n$1\r\nx\r\n").Code)
+	w = exec(router, "/shell/exec/incr", "application/x-resp", "*1\r\n$2\r\nk1\r\n")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Regexp(t, `^-ERR [^\r\n]*not an integer[^\r\n]*\r\n$`, w.Body.String())
+}
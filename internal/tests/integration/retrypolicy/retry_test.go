@@ -0,0 +1,221 @@
+package retrypolicy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"server/internal/db"
+	"server/internal/middleware"
+	"server/internal/server"
+	"server/internal/tests/fakedice"
+	"server/util/cmds"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newClients connects clients retrying reads three times to fake DiceDB servers
+func newClients(t *testing.T) *fakedice.Clients {
+	t.Setenv("READ_RETRY_MAX", "3")
+	t.Setenv("RETRY_INITIAL_BACKOFF_MS", "10")
+	return fakedice.NewClients(t)
+}
+
+func TestClassifyCommand(t *testing.T) {
+	tests := []struct {
+		cmd      string
+		args     []string
+		expected db.CommandClass
+	}{
+		{cmd: "GET", args: []string{"k"}, expected: db.ClassRead},
+		{cmd: "hgetall", args: []string{"h"}, expected: db.ClassRead},
+		{cmd: "SET", args: []string{"k", "v", "EX", "10"}, expected: db.ClassIdempotentWrite},
+		{cmd: "SET", args: []string{"k", "v", "NX"}, expected: db.ClassNonIdempotentWrite},
+		{cmd: "SET", args: []string{"k", "v", "get"}, expected: db.ClassNonIdempotentWrite},
+		{cmd: "INCR", args: []string{"k"}, expected: db.ClassNonIdempotentWrite},
+		{cmd: "LPUSH", args: []string{"l", "a"}, expected: db.ClassNonIdempotentWrite},
+		{cmd: "PFADD", args: []string{"hll", "a"}, expected: db.ClassNonIdempotentWrite},
+		{cmd: "UNKNOWNCMD", expected: db.ClassNonIdempotentWrite},
+	}
+
+	for _, test := range tests {
+		t.Run(test.cmd, func(t *testing.T) {
+			assert.Equal(t, test.expected, db.ClassifyCommand(test.cmd, test.args))
+		})
+	}
+}
+
+func TestReadsAreRetriedOnDroppedConnections(t *testing.T) {
+	clients := newClients(t)
+	fake, client := clients.UserFake, clients.User
+	_, err := client.ExecuteCommand(context.Background(), &cmds.CommandRequest{Cmd: "SET", Args: []string{"k", "v"}})
+	require.NoError(t, err)
+
+	fake.DropConnections("GET", 2)
+	resp, err := client.ExecuteCommand(context.Background(), &cmds.CommandRequest{Cmd: "GET", Args: []string{"k"}})
+	require.NoError(t, err)
+	assert.Equal(t, `"v"`, resp)
+	assert.Equal(t, 3, fake.Calls("GET"), "GET should be retried until it succeeds")
+}
+
+func TestReadRetriesAreBounded(t *testing.T) {
+	clients := newClients(t)
+	fake, client := clients.UserFake, clients.User
+
+	fake.DropConnections("GET", 10)
+	_, err := client.ExecuteCommand(context.Background(), &cmds.CommandRequest{Cmd: "GET", Args: []string{"k"}})
+	require.Error(t, err)
+	assert.Equal(t, 4, fake.Calls("GET"), "GET should be attempted once plus the configured retries")
+}
+
+func TestNonIdempotentWritesAreNotRetried(t *testing.T) {
+	clients := newClients(t)
+	fake, client := clients.UserFake, clients.User
+
+	// The write is applied but the reply never makes it back
+	fake.DropReplies("INCR", 1)
+	_, err := client.ExecuteCommand(context.Background(), &cmds.CommandRequest{Cmd: "INCR", Args: []string{"counter"}})
+	require.Error(t, err)
+	assert.Equal(t, 1, fake.Calls("INCR"), "INCR must not be retried automatically")
+
+	val, _ := fake.Value("counter")
+	assert.Equal(t, "1", val, "INCR must be applied exactly once")
+}
+
+func TestDiceDBErrorsAreNotRetried(t *testing.T) {
+	clients := newClients(t)
+	fake, client := clients.UserFake, clients.User
+	fake.Handle("GET", func(args []string) fakedice.Reply {
+		return fakedice.Error("WRONGTYPE Operation against a key holding the wrong kind of value")
+	})
+
+	_, err := client.ExecuteCommand(context.Background(), &cmds.CommandRequest{Cmd: "GET", Args: []string{"k"}})
+	require.Error(t, err)
+	assert.Equal(t, 1, fake.Calls("GET"))
+}
+
+// idempotentRouter executes commands behind the idempotency middleware
+type idempotentRouter struct {
+	router   *gin.Engine
+	userFake *fakedice.Server
+}
+
+func newIdempotentRouter(t *testing.T) *idempotentRouter {
+	clients := newClients(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.NewIdempotencyMiddleware(clients.Admin, time.Minute).Exec)
+	httpServer := &server.HTTPServer{DiceClient: clients.User}
+	router.POST("/shell/exec/:cmd", gin.WrapF(httpServer.CliHandler))
+	return &idempotentRouter{router: router, userFake: clients.UserFake}
+}
+
+// incr sends INCR with the Idempotency-Key
+func (f *idempotentRouter) incr(key, body string) *httptest.ResponseRecorder {
+	return f.send(context.Background(), "/shell/exec/incr", key, body)
+}
+
+func (f *idempotentRouter) send(ctx context.Context, target, key, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body)).WithContext(ctx)
+	r.Header.Set(middleware.IdempotencyKeyHeader, key)
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, r)
+	return w
+}
+
+// assertOutcomeUnknown asserts that the retry of a request that may have been
+// applied is rejected rather than executed again
+func assertOutcomeUnknown(t *testing.T, retry *httptest.ResponseRecorder) {
+	assert.Equal(t, http.StatusConflict, retry.Code, retry.Body.String())
+	assert.Contains(t, retry.Body.String(), "may have been applied")
+	assert.Empty(t, retry.Header().Get(middleware.IdempotentReplayedHeader))
+}
+
+func TestIdempotencyKeyReplaysWrites(t *testing.T) {
+	f := newIdempotentRouter(t)
+
+	first := f.incr("req-1", `["counter"]`)
+	require.Equal(t, http.StatusOK, first.Code)
+	assert.JSONEq(t, `{"data":"(integer) 1"}`, first.Body.String())
+
+	retry := f.incr("req-1", `["counter"]`)
+	require.Equal(t, http.StatusOK, retry.Code)
+	assert.Equal(t, first.Body.String(), retry.Body.String(), "retry should replay the stored response")
+	assert.Equal(t, "true", retry.Header().Get(middleware.IdempotentReplayedHeader))
+	assert.Equal(t, 1, f.userFake.Calls("INCR"), "the write should be executed once")
+
+	mismatch := f.incr("req-1", `["other"]`)
+	assert.Equal(t, http.StatusUnprocessableEntity, mismatch.Code, "a key must not be reused for a different request")
+
+	// The response of another format is not the stored one
+	otherFormat := f.send(context.Background(), "/shell/exec/incr?format=text", "req-1", `["counter"]`)
+	assert.Equal(t, http.StatusUnprocessableEntity, otherFormat.Code, otherFormat.Body.String())
+
+	next := f.incr("req-2", `["counter"]`)
+	require.Equal(t, http.StatusOK, next.Code)
+	assert.JSONEq(t, `{"data":"(integer) 2"}`, next.Body.String())
+}
+
+func TestIdempotencyKeyOfLostReplyIsNotRetried(t *testing.T) {
+	f := newIdempotentRouter(t)
+	f.userFake.DropReplies("INCR", 1)
+
+	first := f.incr("req-1", `["counter"]`)
+	require.Equal(t, http.StatusServiceUnavailable, first.Code, first.Body.String())
+
+	assertOutcomeUnknown(t, f.incr("req-1", `["counter"]`))
+	assert.Equal(t, 1, f.userFake.Calls("INCR"))
+	value, _ := f.userFake.Value("counter")
+	assert.Equal(t, "1", value, "the counter is incremented once")
+}
+
+func TestIdempotencyKeyOfCancelledRequestIsNotRetried(t *testing.T) {
+	f := newIdempotentRouter(t)
+	f.userFake.SetDelay("INCR", 500*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	f.send(ctx, "/shell/exec/incr", "req-1", `["counter"]`)
+
+	// The client got no response but the command still reaches DiceDB
+	f.userFake.SetDelay("INCR", 0)
+	assertOutcomeUnknown(t, f.incr("req-1", `["counter"]`))
+	assert.Eventually(t, func() bool {
+		value, _ := f.userFake.Value("counter")
+		return value == "1"
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, f.userFake.Calls("INCR"))
+}
+
+func TestIdempotentResponseOfSlowCommandIsStored(t *testing.T) {
+	if testing.Short() {
+		t.Skip("the command outlives the idempotency store timeout of 5s")
+	}
+	t.Setenv("COMMAND_TIMEOUT_MS", "8000")
+	f := newIdempotentRouter(t)
+	f.userFake.SetDelay("INCR", 5500*time.Millisecond)
+
+	first := f.incr("req-1", `["counter"]`)
+	require.Equal(t, http.StatusOK, first.Code, first.Body.String())
+
+	f.userFake.SetDelay("INCR", 0)
+	retry := f.incr("req-1", `["counter"]`)
+	assert.Equal(t, http.StatusOK, retry.Code, retry.Body.String())
+	assert.Equal(t, "true", retry.Header().Get(middleware.IdempotentReplayedHeader))
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, 1, f.userFake.Calls("INCR"))
+}
+
+func TestIdempotentRequestBodyIsBounded(t *testing.T) {
+	f := newIdempotentRouter(t)
+
+	body := `["` + strings.Repeat("k", cmds.MaxTransactionBodyBytes) + `"]`
+	w := f.incr("req-1", body)
+	assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	assert.Zero(t, f.userFake.Calls("INCR"))
+}
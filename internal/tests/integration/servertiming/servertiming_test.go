@@ -0,0 +1,186 @@
+package servertiming
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"server/internal/middleware"
+	"server/internal/server"
+	"server/internal/server/utils"
+	"server/internal/slowlog"
+	"server/internal/tests/fakedice"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const adminToken = "s3cret"
+
+// newRouter builds a router executing commands against fake DiceDB instances,
+// with a slow log recording commands slower than threshold
+func newRouter(t *testing.T, threshold time.Duration) (*gin.Engine, *fakedice.Server, *fakedice.Server) {
+	clients := fakedice.NewClients(t)
+
+	httpServer := &server.HTTPServer{
+		DiceClient: clients.User,
+		SlowLog:    slowlog.New(clients.Admin, utils.SlowLogKey, threshold, 2),
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.RequestLoggerMiddleware)
+	router.Use(middleware.ServerTimingMiddleware)
+	router.Use(middleware.NewRateLimiterMiddleware(clients.Admin, 1000, 60).Exec)
+	router.POST("/shell/exec/:cmd", gin.WrapF(httpServer.CliHandler))
+	adminGroup := router.Group("/admin", middleware.NewAdminAuthMiddleware(adminToken))
+	adminGroup.GET("/slowlog", gin.WrapF(httpServer.SlowLogHandler))
+	return router, clients.AdminFake, clients.UserFake
+}
+
+func exec(router *gin.Engine, path string, args ...string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(args)
+	r := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func getSlowLog(router *gin.Engine, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/admin/slowlog", http.NoBody)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+// phases parses a Server-Timing header into its metric names
+func phases(header string) []string {
+	var names []string
+	for _, part := range strings.Split(header, ", ") {
+		name, _, _ := strings.Cut(part, ";")
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestServerTimingHeader(t *testing.T) {
+	router, _, _ := newRouter(t, time.Hour)
+
+	w := exec(router, "/shell/exec/set", "k1", "v1")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	header := w.Header().Get(middleware.ServerTimingHeader)
+	assert.Equal(t, []string{"ratelimit", "parse", "dicedb", "serialize", "total"}, phases(header))
+	assert.Regexp(t, `^ratelimit;dur=\d+\.\d{3}, `, header)
+
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.NotContains(t, body, "timing", "timing is only included in the body when requested")
+}
+
+func TestServerTimingOnRejectedRequest(t *testing.T) {
+	router, _, _ := newRouter(t, time.Hour)
+
+	r := httptest.NewRequest(http.MethodPost, "/shell/exec/get", strings.NewReader("not json"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, []string{"ratelimit", "parse", "total"}, phases(w.Header().Get(middleware.ServerTimingHeader)))
+}
+
+func TestTimingInBody(t *testing.T) {
+	router, _, _ := newRouter(t, time.Hour)
+
+	w := exec(router, "/shell/exec/set?timing=true", "k1", "v1")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var body struct {
+		Data   string             `json:"data"`
+		Timing map[string]float64 `json:"timing"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "OK", body.Data)
+	assert.Contains(t, body.Timing, "parse")
+	assert.Contains(t, body.Timing, "ratelimit")
+	assert.Contains(t, body.Timing, "dicedb")
+	assert.NotContains(t, body.Timing, "serialize")
+}
+
+func TestSlowCommandsAreLogged(t *testing.T) {
+	router, adminFake, userFake := newRouter(t, 50*time.Millisecond)
+	userFake.SetDelay("GET", 100*time.Millisecond)
+
+	require.Equal(t, http.StatusOK, exec(router, "/shell/exec/set", "k1", "secret-value").Code)
+	for i := 0; i < 3; i++ {
+		require.Equal(t, http.StatusOK, exec(router, "/shell/exec/get", "k1").Code)
+	}
+
+	// Entries are written in the background and capped at 2
+	require.Eventually(t, func() bool {
+		return len(adminFake.List(utils.SlowLogKey)) == 2 && userFake.Calls("GET") == 3
+	}, 2*time.Second, 10*time.Millisecond)
+
+	w := getSlowLog(router, adminToken)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "secret-value")
+
+	var body struct {
+		Data []slowlog.Entry `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Len(t, body.Data, 2)
+	for _, entry := range body.Data {
+		assert.Equal(t, "GET", entry.Command)
+		assert.Equal(t, []string{"k1"}, entry.Keys)
+		assert.GreaterOrEqual(t, entry.DurationMs, 100.0)
+		assert.GreaterOrEqual(t, entry.Timings["dicedb"], 100.0)
+		assert.NotEmpty(t, entry.RequestID)
+	}
+}
+
+func TestSlowLogCloseWaitsForPendingWrites(t *testing.T) {
+	clients := fakedice.NewClients(t)
+	adminFake := clients.AdminFake
+
+	log := slowlog.New(clients.Admin, utils.SlowLogKey, time.Millisecond, 10)
+	adminFake.SetDelay("LPUSH", 200*time.Millisecond)
+	log.Record(context.Background(), time.Second, slowlog.Entry{Command: "GET"})
+
+	require.NoError(t, log.Close(context.Background()))
+	assert.Len(t, adminFake.List(utils.SlowLogKey), 1)
+
+	// Entries recorded after Close are dropped
+	log.Record(context.Background(), time.Second, slowlog.Entry{Command: "GET"})
+	require.NoError(t, log.Close(context.Background()))
+	assert.Len(t, adminFake.List(utils.SlowLogKey), 1)
+}
+
+func TestSlowLogRequiresToken(t *testing.T) {
+	router, _, _ := newRouter(t, time.Hour)
+
+	assert.Equal(t, http.StatusUnauthorized, getSlowLog(router, "").Code)
+	assert.Equal(t, http.StatusUnauthorized, getSlowLog(router, "wrong").Code)
+	assert.Equal(t, http.StatusOK, getSlowLog(router, adminToken).Code)
+}
+
+func TestAdminDisabledWithoutToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/admin/slowlog", middleware.NewAdminAuthMiddleware(""), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/admin/slowlog", http.NoBody)
+	r.Header.Set("Authorization", "Bearer ")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,333 @@
+package session
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"server/internal/analytics"
+	"server/internal/apierror"
+	"server/internal/middleware"
+	"server/internal/server"
+	"server/internal/session"
+	"server/internal/tests/fakedice"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	aliceAddr = "10.0.0.1:1234"
+	bobAddr   = "10.0.0.2:1234"
+)
+
+type fixture struct {
+	router    *gin.Engine
+	sessions  *session.Manager
+	userFake  *fakedice.Server
+	adminFake *fakedice.Server
+}
+
+func setup(t *testing.T, maxConns, maxClientConns int, idleTimeout time.Duration) *fixture {
+	clients := fakedice.NewClients(t)
+
+	sessions := session.New(clients.User, maxConns, maxClientConns, idleTimeout)
+	httpServer := &server.HTTPServer{DiceClient: clients.User, Sessions: sessions}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	require.NoError(t, router.SetTrustedProxies(clients.Config.Server.TrustedProxies))
+	router.Use(middleware.RequestLoggerMiddleware)
+	router.Use(middleware.NewRateLimiterMiddleware(clients.Admin, 1000, 60).Exec)
+	router.POST("/shell/exec/:cmd", gin.WrapF(httpServer.CliHandler))
+	router.POST("/shell/tx", gin.WrapF(httpServer.TransactionHandler))
+	router.GET("/keys", gin.WrapF(httpServer.KeysHandler))
+	router.POST("/shell/session", gin.WrapF(httpServer.PinSessionHandler))
+	router.DELETE("/shell/session", gin.WrapF(httpServer.UnpinSessionHandler))
+	return &fixture{router: router, sessions: sessions, userFake: clients.UserFake, adminFake: clients.AdminFake}
+}
+
+// pin pins a connection for the client at remoteAddr
+func (f *fixture) pin(remoteAddr string) *httptest.ResponseRecorder {
+	return f.pinForwarded(remoteAddr, "")
+}
+
+// pinForwarded pins a connection for the client at remoteAddr, claiming to
+// forward the request of forwardedFor
+func (f *fixture) pinForwarded(remoteAddr, forwardedFor string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/shell/session", http.NoBody)
+	req.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+	return w
+}
+
+// pinned pins a connection and returns the ID of its session
+func (f *fixture) pinned(t *testing.T, remoteAddr string) string {
+	w := f.pin(remoteAddr)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var resp server.SessionResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.True(t, resp.Pinned)
+	require.NotEmpty(t, resp.SessionID)
+	return resp.SessionID
+}
+
+func (f *fixture) unpin(sessionID string) *httptest.ResponseRecorder {
+	return f.do(http.MethodDelete, "/shell/session", sessionID, nil)
+}
+
+func (f *fixture) exec(sessionID, cmd string, args ...string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(args)
+	return f.do(http.MethodPost, "/shell/exec/"+cmd, sessionID, body)
+}
+
+func (f *fixture) do(method, target, sessionID string, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, bytes.NewReader(body))
+	if sessionID != "" {
+		req.Header.Set(server.PinnedSessionHeader, sessionID)
+	}
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+	return w
+}
+
+func data(t *testing.T, w *httptest.ResponseRecorder) interface{} {
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp server.HTTPResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp.Data
+}
+
+// assertExpired asserts that the command of a session without a pinned
+// connection was rejected rather than run on a pooled connection
+func assertExpired(t *testing.T, w *httptest.ResponseRecorder) {
+	t.Helper()
+	assert.Equal(t, http.StatusGone, w.Code, w.Body.String())
+	assert.Equal(t, apierror.CodeSessionExpired, errorCode(t, w))
+	assert.Empty(t, w.Header().Get(server.SessionPinnedHeader))
+}
+
+func errorCode(t *testing.T, w *httptest.ResponseRecorder) apierror.Code {
+	var resp struct {
+		Error apierror.Error `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp), w.Body.String())
+	return resp.Error.Code
+}
+
+func TestPinnedConnectionKeepsState(t *testing.T) {
+	f := setup(t, 4, 2, time.Minute)
+	alice := f.pinned(t, aliceAddr)
+	other := f.pinned(t, aliceAddr)
+	assert.NotEqual(t, alice, other, "every pin starts a new session")
+
+	w := f.exec(alice, "CLIENT", "SETNAME", "alice")
+	assert.Equal(t, "true", w.Header().Get(server.SessionPinnedHeader))
+	data(t, w)
+	for i := 0; i < 5; i++ {
+		w := f.exec(alice, "CLIENT", "GETNAME")
+		assert.Equal(t, "alice", data(t, w))
+		assert.Equal(t, "true", w.Header().Get(server.SessionPinnedHeader))
+	}
+
+	// Other sessions and requests without a session do not use the connection,
+	// sessions that were never issued are rejected
+	w = f.exec(other, "CLIENT", "GETNAME")
+	assert.NotEqual(t, "alice", data(t, w))
+	assert.Equal(t, "true", w.Header().Get(server.SessionPinnedHeader))
+	w = f.exec("", "CLIENT", "GETNAME")
+	assert.NotEqual(t, "alice", data(t, w))
+	assert.Empty(t, w.Header().Get(server.SessionPinnedHeader))
+	assertExpired(t, f.exec("bob", "CLIENT", "GETNAME"))
+}
+
+func TestAnalyticsSessionIsNotPinned(t *testing.T) {
+	f := setup(t, 4, 2, time.Minute)
+	alice := f.pinned(t, aliceAddr)
+	data(t, f.exec(alice, "CLIENT", "SETNAME", "alice"))
+
+	// The analytics session ID sent by every client neither selects the pinned
+	// connection nor is rejected as an unknown pinned session
+	req := httptest.NewRequest(http.MethodPost, "/shell/exec/CLIENT", bytes.NewReader([]byte(`["GETNAME"]`)))
+	req.Header.Set(analytics.SessionIDHeader, alice)
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+	assert.NotEqual(t, "alice", data(t, w))
+	assert.Empty(t, w.Header().Get(server.SessionPinnedHeader))
+}
+
+func TestTransactionRunsOnPinnedConnection(t *testing.T) {
+	f := setup(t, 4, 2, time.Minute)
+	alice := f.pinned(t, aliceAddr)
+	data(t, f.exec(alice, "CLIENT", "SETNAME", "alice"))
+
+	body, _ := json.Marshal(map[string]interface{}{"commands": [][]string{{"CLIENT", "GETNAME"}}})
+	w := f.do(http.MethodPost, "/shell/tx", alice, body)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "true", w.Header().Get(server.SessionPinnedHeader))
+	var resp struct {
+		Data server.TransactionResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Data.Results, 1)
+	assert.Equal(t, "alice", resp.Data.Results[0].Data)
+}
+
+func TestConcurrentRequestsOfSession(t *testing.T) {
+	f := setup(t, 4, 2, time.Minute)
+	alice := f.pinned(t, aliceAddr)
+	data(t, f.exec(alice, "CLIENT", "SETNAME", "alice"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := f.exec(alice, "CLIENT", "GETNAME")
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Contains(t, w.Body.String(), "alice")
+		}()
+	}
+	wg.Wait()
+}
+
+func TestUnpinEndsSession(t *testing.T) {
+	f := setup(t, 4, 2, time.Minute)
+	alice := f.pinned(t, aliceAddr)
+	data(t, f.exec(alice, "CLIENT", "SETNAME", "alice"))
+
+	assert.Equal(t, http.StatusNoContent, f.unpin(alice).Code)
+	assert.Equal(t, 0, f.sessions.Len())
+	assertExpired(t, f.exec(alice, "CLIENT", "GETNAME"))
+
+	for _, sessionID := range []string{alice, "bob"} {
+		w := f.unpin(sessionID)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, apierror.CodeNotFound, errorCode(t, w))
+	}
+}
+
+func TestPinnedConnectionLimit(t *testing.T) {
+	f := setup(t, 1, 2, time.Minute)
+	alice := f.pinned(t, aliceAddr)
+
+	w := f.pin(bobAddr)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, apierror.CodeSessionLimit, errorCode(t, w))
+
+	// Requests without a session still execute commands
+	assert.Equal(t, "\"PONG\"", data(t, f.exec("", "PING")))
+
+	require.Equal(t, http.StatusNoContent, f.unpin(alice).Code)
+	f.pinned(t, bobAddr)
+}
+
+func TestPinnedConnectionLimitPerClient(t *testing.T) {
+	f := setup(t, 4, 2, time.Minute)
+	alice := f.pinned(t, aliceAddr)
+	f.pinned(t, aliceAddr)
+
+	w := f.pin(aliceAddr)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, apierror.CodeConflict, errorCode(t, w))
+
+	// Other clients still get a connection, and alice once a session ends
+	f.pinned(t, bobAddr)
+	require.Equal(t, http.StatusNoContent, f.unpin(alice).Code)
+	f.pinned(t, aliceAddr)
+}
+
+func TestForwardedForDoesNotBypassLimitPerClient(t *testing.T) {
+	f := setup(t, 4, 2, time.Minute)
+	f.pinned(t, aliceAddr)
+	f.pinned(t, aliceAddr)
+
+	// Without trusted proxies the X-Forwarded-For header is ignored, a client
+	// cannot pose as another one to pin more connections
+	w := f.pinForwarded(aliceAddr, "10.0.0.3")
+	assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	assert.Equal(t, apierror.CodeConflict, errorCode(t, w))
+}
+
+func TestTrustedProxyForwardsClient(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.9")
+	f := setup(t, 4, 1, time.Minute)
+	const proxyAddr = "10.0.0.9:1234"
+
+	// The clients behind the trusted proxy are told apart by X-Forwarded-For
+	require.Equal(t, http.StatusCreated, f.pinForwarded(proxyAddr, "10.0.0.1").Code)
+	require.Equal(t, http.StatusCreated, f.pinForwarded(proxyAddr, "10.0.0.2").Code)
+	assert.Equal(t, http.StatusConflict, f.pinForwarded(proxyAddr, "10.0.0.1").Code)
+
+	// The header of other peers is still ignored
+	const peerAddr = "10.0.0.5:1234"
+	require.Equal(t, http.StatusCreated, f.pinForwarded(peerAddr, "10.0.0.3").Code)
+	assert.Equal(t, http.StatusConflict, f.pinForwarded(peerAddr, "10.0.0.4").Code)
+}
+
+func TestIdleConnectionsAreEvicted(t *testing.T) {
+	f := setup(t, 1, 1, 50*time.Millisecond)
+	alice := f.pinned(t, aliceAddr)
+	time.Sleep(100 * time.Millisecond)
+
+	// The idle connection of alice makes room for bob, her session is gone
+	f.pinned(t, bobAddr)
+	assertExpired(t, f.exec(alice, "CLIENT", "GETNAME"))
+	assertExpired(t, f.do(http.MethodGet, "/keys", alice, nil))
+	assert.Equal(t, http.StatusNotFound, f.unpin(alice).Code)
+	assert.Equal(t, 1, f.sessions.Len())
+}
+
+func TestBrokenConnectionEndsSession(t *testing.T) {
+	f := setup(t, 4, 2, time.Minute)
+	alice := f.pinned(t, aliceAddr)
+	data(t, f.exec(alice, "CLIENT", "SETNAME", "alice"))
+
+	// The connection state is lost, the command is not retried on another connection
+	f.userFake.DropConnections("CLIENT", 1)
+	w := f.exec(alice, "CLIENT", "GETNAME")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code, w.Body.String())
+	assert.Equal(t, 0, f.sessions.Len())
+	assertExpired(t, f.exec(alice, "CLIENT", "GETNAME"))
+}
+
+func TestShutdownClosesConnections(t *testing.T) {
+	f := setup(t, 4, 2, time.Minute)
+	f.pinned(t, aliceAddr)
+	f.pinned(t, bobAddr)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go f.sessions.Run(ctx, &wg)
+	cancel()
+	wg.Wait()
+
+	assert.Equal(t, 0, f.sessions.Len())
+}
+
+func TestSessionRequests(t *testing.T) {
+	f := setup(t, 4, 2, time.Minute)
+	w := f.unpin("")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, apierror.CodeInvalidRequest, errorCode(t, w))
+
+	// Pinning counts towards the rate limit
+	incrs := f.adminFake.Calls("INCRBY") + f.adminFake.Calls("INCR")
+	f.pinned(t, aliceAddr)
+	assert.Greater(t, f.adminFake.Calls("INCRBY")+f.adminFake.Calls("INCR"), incrs)
+
+	disabled := setup(t, 0, 2, time.Minute)
+	w = disabled.pin(aliceAddr)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, apierror.CodeNotFound, errorCode(t, w))
+	assert.Equal(t, "\"PONG\"", data(t, disabled.exec("alice", "PING")))
+}
@@ -0,0 +1,189 @@
+package shutdown
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os/signal"
+	"server/config"
+	"server/internal/audit"
+	"server/internal/server"
+	"server/internal/server/utils"
+	"server/internal/slowlog"
+	"server/internal/tests/fakedice"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// freeAddr reserves a free local port and releases it so the server can bind to it
+func freeAddr(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+	return addr
+}
+
+func waitForServer(t *testing.T, addr string) {
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}, 5*time.Second, 10*time.Millisecond, "server should start listening")
+}
+
+func TestSignalDrainsInFlightRequests(t *testing.T) {
+	addr := freeAddr(t)
+	t.Setenv("PORT", addr)
+	t.Setenv("SHUTDOWN_TIMEOUT_SEC", "5")
+
+	requestStarted := make(chan struct{})
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/slow", func(c *gin.Context) {
+		close(requestStarted)
+		time.Sleep(500 * time.Millisecond)
+		c.String(http.StatusOK, "done")
+	})
+
+	httpServer := server.NewHTTPServer(router, config.LoadConfig(), server.Options{})
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM)
+	defer stop()
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- httpServer.Run(ctx)
+	}()
+	waitForServer(t, addr)
+
+	type result struct {
+		status int
+		body   string
+		err    error
+	}
+	responseCh := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/slow")
+		if err != nil {
+			responseCh <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		responseCh <- result{status: resp.StatusCode, body: string(body), err: err}
+	}()
+
+	<-requestStarted
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGTERM))
+
+	res := <-responseCh
+	require.NoError(t, res.err, "in-flight request should complete")
+	assert.Equal(t, http.StatusOK, res.status)
+	assert.Equal(t, "done", res.body)
+
+	select {
+	case err := <-runErr:
+		assert.NoError(t, err, "server should shut down gracefully")
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not shut down after draining requests")
+	}
+
+	_, err := net.Dial("tcp", addr)
+	assert.Error(t, err, "server should not accept new connections after shutdown")
+}
+
+func TestShutdownDeadlineExceeded(t *testing.T) {
+	addr := freeAddr(t)
+	t.Setenv("PORT", addr)
+	t.Setenv("SHUTDOWN_TIMEOUT_SEC", "1")
+
+	requestStarted := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/stuck", func(c *gin.Context) {
+		close(requestStarted)
+		<-release
+		c.Status(http.StatusOK)
+	})
+
+	httpServer := server.NewHTTPServer(router, config.LoadConfig(), server.Options{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- httpServer.Run(ctx)
+	}()
+	waitForServer(t, addr)
+
+	go func() {
+		resp, err := http.Get("http://" + addr + "/stuck")
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+
+	<-requestStarted
+	cancel()
+
+	select {
+	case err := <-runErr:
+		assert.ErrorIs(t, err, context.DeadlineExceeded, "should report requests that did not drain in time")
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdown did not respect the configured deadline")
+	}
+}
+
+func TestCloseFlushesLogsBeforeClosingClients(t *testing.T) {
+	clients := fakedice.NewClients(t)
+	// Keep the slow log write in flight when the server closes
+	clients.AdminFake.SetDelay("LPUSH", 200*time.Millisecond)
+
+	auditor := audit.New([]audit.Sink{audit.NewDiceDBSink(clients.Admin, utils.AuditLogKey, 10)},
+		audit.Options{SampleRate: 1, QueueSize: 16, BatchSize: 16, FlushInterval: time.Hour})
+	slowLog := slowlog.New(clients.Admin, utils.SlowLogKey, time.Millisecond, 10)
+	httpServer := server.NewHTTPServer(gin.New(), clients.Config, server.Options{
+		DiceClient: clients.User,
+		Auditor:    auditor,
+		SlowLog:    slowLog,
+	})
+
+	auditor.Record(audit.Event{Timestamp: time.Now(), Command: "SET", Outcome: audit.OutcomeSuccess})
+	slowLog.Record(context.Background(), time.Second, slowlog.Entry{Timestamp: time.Now(), Command: "SET"})
+
+	// A background job finishing a round trip once it is stopped
+	backgroundCtx, cancelBackground := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-backgroundCtx.Done()
+		assert.Len(t, clients.AdminFake.List(utils.AuditLogKey), 1, "audit events are written before background jobs stop")
+		assert.Len(t, clients.AdminFake.List(utils.SlowLogKey), 1, "slow log entries are written before background jobs stop")
+		assert.NoError(t, clients.Admin.Client.Set(context.Background(), "background", "stopped", 0).Err(),
+			"the admin client is open until background jobs stopped")
+	}()
+
+	httpServer.Close(func() {
+		cancelBackground()
+		wg.Wait()
+	}, clients.User, clients.Admin)
+
+	value, _ := clients.AdminFake.Value("background")
+	assert.Equal(t, "stopped", value)
+	assert.Error(t, clients.Admin.Client.Ping(context.Background()).Err(), "the admin client is closed")
+	assert.Error(t, clients.User.Client.Ping(context.Background()).Err(), "the user client is closed")
+}
@@ -0,0 +1,130 @@
+package textoutput
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"server/internal/middleware"
+	"server/internal/server"
+	"server/internal/tests/fakedice"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRouter(t *testing.T) (*gin.Engine, *fakedice.Server) {
+	clients := fakedice.NewClients(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.RequestLoggerMiddleware)
+	router.POST("/shell/exec/:cmd", gin.WrapF((&server.HTTPServer{DiceClient: clients.User}).CliHandler))
+	return router, clients.UserFake
+}
+
+func exec(router *gin.Engine, accept, cmd string, args ...string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(args)
+	r := httptest.NewRequest(http.MethodPost, "/shell/exec/"+cmd, bytes.NewReader(body))
+	if accept != "" {
+		r.Header.Set("Accept", accept)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+// golden returns the output captured from redis-cli for the same reply
+func golden(t *testing.T, name string) string {
+	data, err := os.ReadFile(filepath.Join("testdata", name+".golden"))
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestPlainTextReplies(t *testing.T) {
+	tests := []struct {
+		name   string
+		reply  fakedice.Reply
+		status int
+	}{
+		{"bulk_escaped", fakedice.BulkString("line1\nline2 \"quoted\" \\ tab\t bell\a \xff\x00"), http.StatusOK},
+		{"integer", fakedice.Integer(42), http.StatusOK},
+		{"nil", fakedice.Nil(), http.StatusOK},
+		{"error", fakedice.Error("WRONGTYPE Operation against a key holding the wrong kind of value"), http.StatusConflict},
+		{"empty_array", fakedice.Array(), http.StatusOK},
+		{"flat_array", fakedice.Array(
+			fakedice.BulkString("a"), fakedice.BulkString("b"), fakedice.BulkString("c"), fakedice.BulkString("d"),
+			fakedice.BulkString("e"), fakedice.BulkString("f"), fakedice.BulkString("g"), fakedice.BulkString("h"),
+			fakedice.BulkString("i"), fakedice.BulkString("j"),
+		), http.StatusOK},
+		{"array_with_nils", fakedice.Array(fakedice.BulkString("v1"), fakedice.Nil(), fakedice.BulkString("v3")), http.StatusOK},
+		{"nested_array", fakedice.Array(
+			fakedice.BulkString("0"),
+			fakedice.Array(
+				fakedice.BulkString("k1"),
+				fakedice.Array(fakedice.Integer(1), fakedice.Array()),
+				fakedice.BulkString("k3"),
+			),
+			fakedice.Integer(7),
+		), http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			router, fake := newRouter(t)
+			fake.Handle("GET", func(args []string) fakedice.Reply { return tc.reply })
+
+			w := exec(router, "text/plain", "get", "k1")
+			assert.Equal(t, tc.status, w.Code)
+			assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+			assert.Equal(t, golden(t, tc.name), w.Body.String())
+		})
+	}
+}
+
+func TestStatusReply(t *testing.T) {
+	router, _ := newRouter(t)
+
+	w := exec(router, "text/plain", "set", "k1", "v1")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, golden(t, "status"), w.Body.String())
+}
+
+func TestContentNegotiation(t *testing.T) {
+	router, _ := newRouter(t)
+	require.Equal(t, http.StatusOK, exec(router, "", "set", "k1", "v1").Code)
+
+	for _, accept := range []string{"", "*/*", "application/json", "text/plain;q=0.5, application/json"} {
+		w := exec(router, accept, "get", "k1")
+		require.Equal(t, http.StatusOK, w.Code, accept)
+		assert.JSONEq(t, `{"data":"\"v1\""}`, w.Body.String(), accept)
+	}
+	for _, accept := range []string{"text/plain", "text/*", "application/json;q=0.5, text/plain", "text/plain, */*"} {
+		w := exec(router, accept, "get", "k1")
+		require.Equal(t, http.StatusOK, w.Code, accept)
+		assert.Equal(t, "\"v1\"\n", w.Body.String(), accept)
+	}
+}
+
+func TestPlainTextErrors(t *testing.T) {
+	t.Setenv("COMMAND_TIMEOUT_OVERRIDES_MS", "GET=100")
+	router, fake := newRouter(t)
+
+	r := httptest.NewRequest(http.MethodPost, "/shell/exec/get", strings.NewReader("not json"))
+	r.Header.Set("Accept", "text/plain")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.True(t, strings.HasPrefix(w.Body.String(), "(error) "), w.Body.String())
+
+	fake.SetDelay("GET", time.Second)
+	w = exec(router, "text/plain", "get", "k1")
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.True(t, strings.HasPrefix(w.Body.String(), "(error) "), w.Body.String())
+}
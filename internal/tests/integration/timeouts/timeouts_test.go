@@ -0,0 +1,78 @@
+package timeouts
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"server/internal/apierror"
+	"server/internal/server"
+	"server/internal/tests/fakedice"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSlowBackend starts a fake DiceDB and returns an HTTP server executing commands against it
+func newSlowBackend(t *testing.T, overrides string) (*fakedice.Server, *server.HTTPServer) {
+	t.Setenv("COMMAND_TIMEOUT_MS", "300")
+	t.Setenv("COMMAND_TIMEOUT_OVERRIDES_MS", overrides)
+
+	clients := fakedice.NewClients(t)
+	return clients.UserFake, &server.HTTPServer{DiceClient: clients.User}
+}
+
+func exec(ctx context.Context, s *server.HTTPServer, cmd string, args ...string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(args)
+	r := httptest.NewRequest(http.MethodPost, "/shell/exec/"+cmd, strings.NewReader(string(body))).WithContext(ctx)
+	w := httptest.NewRecorder()
+	s.CliHandler(w, r)
+	return w
+}
+
+func TestCommandTimeoutReturnsGatewayTimeout(t *testing.T) {
+	fake, httpServer := newSlowBackend(t, "GET=100,PFMERGE=2000")
+	fake.SetDelay("GET", time.Second)
+
+	start := time.Now()
+	w := exec(context.Background(), httpServer, "GET", "k")
+	elapsed := time.Since(start)
+
+	require.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Less(t, elapsed, 900*time.Millisecond, "should not wait for the slow backend")
+
+	var resp apierror.Response
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, apierror.CodeTimeout, resp.Error.Code)
+	assert.Equal(t, "GET", resp.Error.Details["command"])
+	assert.Equal(t, float64(100), resp.Error.Details["timeout_ms"], "GET should use its per-command override")
+}
+
+func TestDefaultAndOverriddenTimeouts(t *testing.T) {
+	fake, httpServer := newSlowBackend(t, "GET=100,PFMERGE=2000")
+	fake.SetDelay("SET", 500*time.Millisecond)
+	fake.Handle("PFMERGE", func(args []string) fakedice.Reply { return fakedice.SimpleString("OK") })
+	fake.SetDelay("PFMERGE", 500*time.Millisecond)
+
+	w := exec(context.Background(), httpServer, "SET", "k", "v")
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code, "SET should use the default timeout")
+
+	w = exec(context.Background(), httpServer, "PFMERGE", "dest", "src")
+	require.Equal(t, http.StatusOK, w.Code, "PFMERGE should use its longer override: %s", w.Body.String())
+	assert.JSONEq(t, `{"data":"OK"}`, w.Body.String())
+}
+
+func TestClientDisconnectCancelsCommand(t *testing.T) {
+	fake, httpServer := newSlowBackend(t, "INCR=5000")
+	fake.SetDelay("INCR", 5*time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	exec(ctx, httpServer, "INCR", "counter")
+	assert.Less(t, time.Since(start), 2*time.Second, "command should stop once the client goes away")
+}
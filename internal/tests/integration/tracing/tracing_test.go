@@ -0,0 +1,215 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"server/config"
+	"server/internal/db"
+	"server/internal/middleware"
+	"server/internal/server"
+	"server/internal/tests/fakedice"
+	"server/internal/tracing"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+const (
+	incomingTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	incomingSpanID  = "00f067aa0ba902b7"
+)
+
+// newExporter installs a tracer provider recording spans in memory
+func newExporter(t *testing.T) *tracetest.InMemoryExporter {
+	_, err := tracing.Init(context.Background(), &config.TracingConfig{})
+	require.NoError(t, err)
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+	return exporter
+}
+
+// newClients connects admin and user clients to fake DiceDB instances
+func newClients(t *testing.T) (admin, user *db.DiceDB) {
+	clients := fakedice.NewClients(t)
+	return clients.Admin, clients.User
+}
+
+func spanByName(t *testing.T, spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	for _, span := range spans {
+		if span.Name == name {
+			return span
+		}
+	}
+	require.Failf(t, "span not found", "no span named %q in %v", name, spanNames(spans))
+	return tracetest.SpanStub{}
+}
+
+func childrenOf(spans tracetest.SpanStubs, parent tracetest.SpanStub) []string {
+	var names []string
+	for _, span := range spans {
+		if span.Parent.SpanID() == parent.SpanContext.SpanID() {
+			names = append(names, span.Name)
+		}
+	}
+	return names
+}
+
+func spanNames(spans tracetest.SpanStubs) []string {
+	names := make([]string, 0, len(spans))
+	for _, span := range spans {
+		names = append(names, span.Name)
+	}
+	return names
+}
+
+func TestRequestSpanStructure(t *testing.T) {
+	exporter := newExporter(t)
+	admin, user := newClients(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(tracing.RequestMiddleware)
+	router.Use(tracing.Middleware("rate_limiter", middleware.NewRateLimiterMiddleware(admin, 1000, 60).Exec)...)
+	router.POST("/shell/exec/:cmd", gin.WrapF((&server.HTTPServer{DiceClient: user}).CliHandler))
+
+	r := httptest.NewRequest(http.MethodPost, "/shell/exec/set", strings.NewReader(`["secret-key","secret-value"]`))
+	r.Header.Set("traceparent", "00-"+incomingTraceID+"-"+incomingSpanID+"-01")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	spans := exporter.GetSpans()
+
+	// The request continues the caller's trace
+	request := spanByName(t, spans, "POST /shell/exec/:cmd")
+	assert.Equal(t, incomingTraceID, request.SpanContext.TraceID().String())
+	assert.Equal(t, incomingSpanID, request.Parent.SpanID().String())
+	for _, span := range spans {
+		assert.Equal(t, incomingTraceID, span.SpanContext.TraceID().String(), "span %s", span.Name)
+	}
+
+	// Admin round trips of the rate limiter are attributed to it
+	rateLimiter := spanByName(t, spans, "middleware rate_limiter")
+	assert.Equal(t, request.SpanContext.SpanID(), rateLimiter.Parent.SpanID())
+	assert.ElementsMatch(t, []string{"dicedb GET", "dicedb INCR", "dicedb EXPIRE", "rate_limiter.next_cleanup_time"},
+		childrenOf(spans, rateLimiter))
+	nextCleanup := spanByName(t, spans, "rate_limiter.next_cleanup_time")
+	assert.Equal(t, []string{"dicedb GET"}, childrenOf(spans, nextCleanup))
+
+	// The user command is not attributed to the rate limiter
+	assert.Equal(t, []string{"middleware rate_limiter", "dicedb SET"}, childrenOf(spans, request))
+
+	// Command spans carry the command name but never keys or values
+	set := spanByName(t, spans, "dicedb SET")
+	for _, attr := range set.Attributes {
+		assert.NotContains(t, attr.Value.Emit(), "secret", "attribute %s", attr.Key)
+	}
+}
+
+func TestAbortingMiddlewareSpan(t *testing.T) {
+	exporter := newExporter(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(tracing.RequestMiddleware)
+	router.Use(tracing.Middleware("deny", func(c *gin.Context) {
+		c.AbortWithStatus(http.StatusForbidden)
+	})...)
+	router.GET("/ping", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ping", http.NoBody))
+	require.Equal(t, http.StatusForbidden, w.Code)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	deny := spanByName(t, spans, "middleware deny")
+	assert.Contains(t, deny.Attributes, attribute.Bool("middleware.aborted", true))
+	assert.Equal(t, spanByName(t, spans, "GET /ping").SpanContext.SpanID(), deny.Parent.SpanID())
+}
+
+func TestCleanupRunSpan(t *testing.T) {
+	exporter := newExporter(t)
+	admin, user := newClients(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go server.NewCleanupManager(admin, user, 20*time.Millisecond).Run(ctx, &wg)
+
+	require.Eventually(t, func() bool {
+		for _, span := range exporter.GetSpans() {
+			if span.Name == "cleanup.run" {
+				return true
+			}
+		}
+		return false
+	}, 2*time.Second, 10*time.Millisecond)
+	cancel()
+	wg.Wait()
+
+	spans := exporter.GetSpans()
+	run := spanByName(t, spans, "cleanup.run")
+	assert.False(t, run.Parent.IsValid(), "cleanup runs start their own trace")
+	assert.ElementsMatch(t, []string{"dicedb FLUSHDB", "dicedb SET"}, childrenOf(spans, run))
+}
+
+func TestTransactionSpan(t *testing.T) {
+	exporter := newExporter(t)
+	_, user := newClients(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(tracing.RequestMiddleware)
+	router.POST("/shell/tx", gin.WrapF((&server.HTTPServer{DiceClient: user}).TransactionHandler))
+
+	body := `{"watch":["secret-key"],"commands":[["SET","secret-key","secret-value"],["GET","secret-key"]]}`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/shell/tx", strings.NewReader(body)))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// The pipeline span lists the command names, never keys or values
+	spans := exporter.GetSpans()
+	pipeline := spanByName(t, spans, "dicedb pipeline")
+	assert.Equal(t, spanByName(t, spans, "POST /shell/tx").SpanContext.SpanID(), pipeline.Parent.SpanID())
+	assert.Contains(t, pipeline.Attributes,
+		attribute.StringSlice("dicedb.commands", []string{"MULTI", "SET", "GET", "EXEC"}))
+	for _, span := range spans {
+		for _, attr := range span.Attributes {
+			assert.NotContains(t, attr.Value.Emit(), "secret", "attribute %s of span %s", attr.Key, span.Name)
+		}
+	}
+}
+
+func TestRequestSpanRecordsRouteTemplate(t *testing.T) {
+	exporter := newExporter(t)
+	_, user := newClients(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(tracing.RequestMiddleware)
+	router.GET("/keys/*key", gin.WrapF((&server.HTTPServer{DiceClient: user}).KeyHandler))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/keys/secret-key", http.NoBody))
+
+	// The path holds the key name, only the route template is recorded
+	request := spanByName(t, exporter.GetSpans(), "GET /keys/*key")
+	assert.Contains(t, request.Attributes, attribute.String("http.route", "/keys/*key"))
+	for _, attr := range request.Attributes {
+		assert.NotContains(t, attr.Value.Emit(), "secret", "attribute %s", attr.Key)
+	}
+}
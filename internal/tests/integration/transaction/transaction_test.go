@@ -0,0 +1,189 @@
+package transaction
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"server/internal/apierror"
+	"server/internal/middleware"
+	"server/internal/server"
+	"server/internal/tests/fakedice"
+	"server/util/cmds"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fixture struct {
+	router   *gin.Engine
+	userFake *fakedice.Server
+}
+
+type response struct {
+	Data server.TransactionResponse `json:"data"`
+}
+
+func setup(t *testing.T, limit int64) *fixture {
+	clients := fakedice.NewClients(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	httpServer := server.NewHTTPServer(router, clients.Config, server.Options{DiceClient: clients.User})
+	router.Use(middleware.RequestLoggerMiddleware)
+	router.Use(middleware.NewRateLimiterMiddleware(clients.Admin, limit, 60).Exec)
+	router.POST("/shell/exec/:cmd", gin.WrapF(httpServer.CliHandler))
+	router.POST("/shell/tx", gin.WrapF(httpServer.TransactionHandler))
+	return &fixture{router: router, userFake: clients.UserFake}
+}
+
+func (f *fixture) tx(watch []string, commands ...[]string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(map[string]interface{}{"watch": watch, "commands": commands})
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/shell/tx", bytes.NewReader(body)))
+	return w
+}
+
+func (f *fixture) exec(cmd string, args ...string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(args)
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/shell/exec/"+cmd, bytes.NewReader(body)))
+	return w
+}
+
+func decode(t *testing.T, w *httptest.ResponseRecorder) server.TransactionResponse {
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp response
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp.Data
+}
+
+func decodeError(t *testing.T, w *httptest.ResponseRecorder, status int) *apierror.Error {
+	require.Equal(t, status, w.Code, w.Body.String())
+	var resp apierror.Response
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.NotNil(t, resp.Error)
+	return resp.Error
+}
+
+func TestCommit(t *testing.T) {
+	f := setup(t, 1000)
+
+	resp := decode(t, f.tx(nil, []string{"set", "k", "v"}, []string{"INCR", "n"}, []string{"GET", "k"}))
+	assert.Equal(t, server.TransactionCommitted, resp.Status)
+	require.Len(t, resp.Results, 3)
+	assert.Equal(t, server.TransactionCommandResult{Command: "SET", Data: "OK"}, resp.Results[0])
+	assert.Equal(t, server.TransactionCommandResult{Command: "INCR", Data: "(integer) 1"}, resp.Results[1])
+	assert.Equal(t, server.TransactionCommandResult{Command: "GET", Data: "\"v\""}, resp.Results[2])
+	assert.Equal(t, 1, f.userFake.Calls("MULTI"))
+	assert.Equal(t, 1, f.userFake.Calls("EXEC"))
+}
+
+func TestFailedCommandsDoNotRollBack(t *testing.T) {
+	f := setup(t, 1000)
+
+	resp := decode(t, f.tx(nil, []string{"SET", "k", "v"}, []string{"INCR", "k"}, []string{"SET", "other", "v"}))
+	assert.Equal(t, server.TransactionCommitted, resp.Status)
+	require.Len(t, resp.Results, 3)
+	assert.Nil(t, resp.Results[0].Error)
+	require.NotNil(t, resp.Results[1].Error)
+	assert.Equal(t, apierror.CodeCommandError, resp.Results[1].Error.Code)
+	assert.Nil(t, resp.Results[1].Data)
+	assert.Nil(t, resp.Results[2].Error)
+	assert.True(t, f.userFake.Exists("other"))
+}
+
+func TestWatchedKeyChanged(t *testing.T) {
+	f := setup(t, 1000)
+	require.Equal(t, http.StatusOK, f.exec("SET", "balance", "10").Code)
+
+	// Hold the transaction between WATCH and MULTI while another client
+	// changes the watched key
+	f.userFake.SetDelay("MULTI", 300*time.Millisecond)
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		done <- f.tx([]string{"balance"}, []string{"INCRBY", "balance", "5"})
+	}()
+	time.Sleep(100 * time.Millisecond)
+	require.Equal(t, http.StatusOK, f.exec("SET", "balance", "100").Code)
+
+	resp := decode(t, <-done)
+	assert.Equal(t, server.TransactionAborted, resp.Status)
+	assert.Contains(t, resp.Reason, "watched key changed")
+	assert.Empty(t, resp.Results)
+	value, _ := f.userFake.Value("balance")
+	assert.Equal(t, "100", value, "queued commands are not executed")
+}
+
+func TestWatchedKeyUnchanged(t *testing.T) {
+	f := setup(t, 1000)
+	require.Equal(t, http.StatusOK, f.exec("SET", "balance", "10").Code)
+
+	resp := decode(t, f.tx([]string{"balance"}, []string{"INCRBY", "balance", "5"}))
+	assert.Equal(t, server.TransactionCommitted, resp.Status)
+	value, _ := f.userFake.Value("balance")
+	assert.Equal(t, "15", value)
+}
+
+func TestInvalidTransactions(t *testing.T) {
+	f := setup(t, 1000)
+
+	err := decodeError(t, f.tx(nil), http.StatusBadRequest)
+	assert.Equal(t, apierror.CodeInvalidRequest, err.Code)
+
+	err = decodeError(t, f.tx(nil, []string{"MULTI"}), http.StatusBadRequest)
+	assert.Equal(t, apierror.CodeInvalidRequest, err.Code)
+	assert.Contains(t, err.Message, "MULTI")
+
+	err = decodeError(t, f.tx(nil, []string{}), http.StatusBadRequest)
+	assert.Equal(t, apierror.CodeInvalidRequest, err.Code)
+	assert.Zero(t, f.userFake.Calls("MULTI"))
+}
+
+func TestWatchErrorFailsTransaction(t *testing.T) {
+	f := setup(t, 1000)
+	f.userFake.Handle("WATCH", func([]string) fakedice.Reply { return fakedice.Error("ERR watch failed") })
+
+	err := decodeError(t, f.tx([]string{"k"}, []string{"INCR", "k"}), http.StatusBadRequest)
+	assert.Equal(t, apierror.CodeCommandError, err.Code)
+	assert.Contains(t, err.Message, "watch failed")
+	assert.Zero(t, f.userFake.Calls("MULTI"))
+}
+
+func TestOversizedTransactionIsRejected(t *testing.T) {
+	f := setup(t, 1000)
+
+	value := strings.Repeat("v", cmds.MaxTransactionBodyBytes)
+	err := decodeError(t, f.tx(nil, []string{"SET", "k", value}), http.StatusBadRequest)
+	assert.Equal(t, apierror.CodeInvalidRequest, err.Code)
+	assert.Contains(t, err.Message, "at most")
+	assert.Zero(t, f.userFake.Calls("MULTI"))
+}
+
+func TestBlockedQueuedCommand(t *testing.T) {
+	t.Setenv("ENVIRONMENT", "production")
+	f := setup(t, 1000)
+
+	err := decodeError(t, f.tx(nil, []string{"SET", "k", "v"}, []string{"FLUSHALL"}), http.StatusForbidden)
+	assert.Equal(t, apierror.CodeBlockedCommand, err.Code)
+	assert.Zero(t, f.userFake.Calls("MULTI"))
+	assert.False(t, f.userFake.Exists("k"))
+}
+
+func TestEveryQueuedCommandIsRateLimited(t *testing.T) {
+	f := setup(t, 3)
+
+	err := decodeError(t, f.tx(nil, []string{"INCR", "a"}, []string{"INCR", "b"}, []string{"INCR", "c"}, []string{"INCR", "d"}),
+		http.StatusTooManyRequests)
+	assert.Equal(t, apierror.CodeRateLimited, err.Code)
+	assert.Zero(t, f.userFake.Calls("MULTI"))
+
+	resp := decode(t, f.tx(nil, []string{"INCR", "a"}, []string{"INCR", "b"}))
+	assert.Equal(t, server.TransactionCommitted, resp.Status)
+	require.Equal(t, http.StatusOK, f.exec("GET", "a").Code)
+	decodeError(t, f.exec("GET", "a"), http.StatusTooManyRequests)
+}
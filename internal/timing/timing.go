@@ -0,0 +1,115 @@
+// Package timing collects the duration of each phase of a request so that it
+// can be reported to the client through the Server-Timing header.
+package timing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Phases recorded while executing a command
+const (
+	Parse     = "parse"
+	RateLimit = "ratelimit"
+	DiceDB    = "dicedb"
+	Diff      = "diff"
+	Serialize = "serialize"
+	Total     = "total"
+)
+
+// Metric is the duration of a single phase
+type Metric struct {
+	Name     string
+	Duration time.Duration
+}
+
+// Timings holds the phases recorded for a request, in the order they were
+// recorded. A nil Timings is valid and records nothing.
+type Timings struct {
+	start time.Time
+
+	mu      sync.Mutex
+	metrics []Metric
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying a new Timings started now
+func NewContext(ctx context.Context) (context.Context, *Timings) {
+	t := &Timings{start: time.Now()}
+	return context.WithValue(ctx, contextKey{}, t), t
+}
+
+// FromContext returns the Timings carried by ctx, or nil outside of requests
+// that went through the Server-Timing middleware
+func FromContext(ctx context.Context) *Timings {
+	t, _ := ctx.Value(contextKey{}).(*Timings)
+	return t
+}
+
+// Add records the duration of a phase
+func (t *Timings) Add(name string, duration time.Duration) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.metrics = append(t.metrics, Metric{Name: name, Duration: duration})
+}
+
+// Elapsed returns the time since the request started
+func (t *Timings) Elapsed() time.Duration {
+	if t == nil {
+		return 0
+	}
+	return time.Since(t.start)
+}
+
+// Metrics returns a copy of the recorded phases
+func (t *Timings) Metrics() []Metric {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Metric(nil), t.metrics...)
+}
+
+// Milliseconds returns the recorded phases keyed by name, in milliseconds
+func (t *Timings) Milliseconds() map[string]float64 {
+	metrics := t.Metrics()
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	durations := make(map[string]float64, len(metrics))
+	for _, metric := range metrics {
+		durations[metric.Name] += Milliseconds(metric.Duration)
+	}
+	return durations
+}
+
+// Header formats the recorded phases followed by the total time elapsed so far
+// as a Server-Timing header value, e.g. "parse;dur=0.05, dicedb;dur=1.2, total;dur=1.8"
+func (t *Timings) Header() string {
+	if t == nil {
+		return ""
+	}
+
+	metrics := append(t.Metrics(), Metric{Name: Total, Duration: t.Elapsed()})
+	parts := make([]string, len(metrics))
+	for i, metric := range metrics {
+		parts[i] = fmt.Sprintf("%s;dur=%.3f", metric.Name, Milliseconds(metric.Duration))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Milliseconds converts a duration to fractional milliseconds
+func Milliseconds(duration time.Duration) float64 {
+	return float64(duration.Microseconds()) / 1000
+}
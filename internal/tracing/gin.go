@@ -0,0 +1,87 @@
+package tracing
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const middlewareSpanKey = "tracing.middleware_span"
+
+// RequestMiddleware starts the server span of a request, continuing the trace
+// of the caller when the request carries W3C trace-context headers. Only the
+// route template is recorded, the path may hold key names.
+func RequestMiddleware(c *gin.Context) {
+	ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+	route := c.FullPath()
+	spanName := c.Request.Method + " " + route
+	if route == "" {
+		spanName = c.Request.Method
+	}
+
+	ctx, span := Tracer().Start(ctx, spanName,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(c.Request.Method),
+			semconv.HTTPRoute(route),
+		))
+	defer span.End()
+
+	c.Request = c.Request.WithContext(ctx)
+	c.Next()
+
+	status := c.Writer.Status()
+	span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+}
+
+// middlewareSpan is the span of the middleware currently running and the span
+// to restore once it hands over to the rest of the chain
+type middlewareSpan struct {
+	span   trace.Span
+	parent trace.Span
+}
+
+// Middleware wraps a middleware so that the time it spends before handing over
+// to the rest of the chain is recorded in its own span. The span ends when the
+// middleware calls c.Next or stops the chain, so downstream handlers are not
+// attributed to it. Register the result with router.Use(...).
+func Middleware(name string, handler gin.HandlerFunc) []gin.HandlerFunc {
+	start := func(c *gin.Context) {
+		parent := trace.SpanFromContext(c.Request.Context())
+		ctx, span := Tracer().Start(c.Request.Context(), "middleware "+name,
+			trace.WithAttributes(attribute.String("middleware.name", name)))
+		c.Request = c.Request.WithContext(ctx)
+		c.Set(middlewareSpanKey, middlewareSpan{span: span, parent: parent})
+
+		c.Next()
+
+		// The middleware stopped the chain without calling c.Next
+		if span.IsRecording() {
+			span.SetAttributes(attribute.Bool("middleware.aborted", true),
+				semconv.HTTPResponseStatusCode(c.Writer.Status()))
+			span.End()
+		}
+	}
+
+	end := func(c *gin.Context) {
+		value, ok := c.Get(middlewareSpanKey)
+		if !ok {
+			return
+		}
+		current := value.(middlewareSpan)
+		current.span.End()
+		c.Request = c.Request.WithContext(trace.ContextWithSpan(c.Request.Context(), current.parent))
+	}
+
+	return []gin.HandlerFunc{start, handler, end}
+}
@@ -2,14 +2,29 @@ package main
 
 import (
 	"context"
+	"io"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"runtime/debug"
 	"server/config"
+	"server/internal/analytics"
+	"server/internal/apierror"
+	"server/internal/audit"
 	"server/internal/db"
+	"server/internal/logging"
+	"server/internal/metrics"
 	"server/internal/middleware"
+	"server/internal/pagination"
+	"server/internal/presence"
 	"server/internal/server"
+	"server/internal/server/utils"
+	"server/internal/session"
+	"server/internal/slowlog"
+	"server/internal/tracing"
 	"sync"
+	"syscall"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/joho/godotenv/autoload"
@@ -21,71 +36,227 @@ func main() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	configValue := config.LoadConfig()
-	diceDBAdminClient, err := db.InitDiceClient(configValue, true)
+	configValue, err := config.Load()
 	if err != nil {
-		slog.Error("Failed to initialize DiceDB Admin client: %v", slog.Any("err", err))
+		slog.Error("Failed to load configuration", slog.Any("err", err))
 		os.Exit(1)
 	}
 
-	diceDBClient, err := db.InitDiceClient(configValue, false)
+	// Structured logging replaces the default logger before anything else logs
+	if err := logging.Init(&configValue.Logging, os.Stdout); err != nil {
+		slog.Error("Failed to initialize logging", slog.Any("err", err))
+		os.Exit(1)
+	}
+
+	// Tracing is set up first so that DiceDB clients and middlewares record spans
+	shutdownTracing, err := tracing.Init(context.Background(), &configValue.Tracing)
+	if err != nil {
+		slog.Error("Failed to initialize tracing", slog.Any("err", err))
+		os.Exit(1)
+	}
+
+	diceDBAdminClient, err := db.NewDiceClient(configValue, true)
 	if err != nil {
-		slog.Error("Failed to initialize DiceDB client: %v", slog.Any("err", err))
+		slog.Error("Failed to initialize DiceDB Admin client", slog.Any("err", err))
 		os.Exit(1)
 	}
 
-	// Graceful shutdown context
-	ctx, cancel := context.WithCancel(context.Background())
+	diceDBClient, err := db.NewDiceClient(configValue, false)
+	if err != nil {
+		slog.Error("Failed to initialize DiceDB client", slog.Any("err", err))
+		os.Exit(1)
+	}
+
+	// Graceful shutdown context, cancelled on SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Background jobs get their own context so that they are only stopped once the
+	// HTTP server has drained all in-flight requests
+	backgroundCtx, cancelBackground := context.WithCancel(context.Background())
 	wg := sync.WaitGroup{}
+
+	// Monitor both DiceDB connections, reconnecting with backoff whenever they drop
+	for _, client := range []*db.DiceDB{diceDBAdminClient, diceDBClient} {
+		wg.Add(1)
+		go func(client *db.DiceDB) {
+			defer wg.Done()
+			client.Monitor(backgroundCtx, configValue.Server.ConnectionCheckInterval, configValue.Server.ReconnectMaxBackoff)
+		}(client)
+	}
+
+	// Wait for DiceDB to come up, but start serving in a degraded (not ready) state
+	// if it does not within the startup deadline
+	startupCtx, cancelStartup := context.WithTimeout(ctx, configValue.Server.StartupConnectTimeout)
+	for _, client := range []*db.DiceDB{diceDBAdminClient, diceDBClient} {
+		if err := client.WaitConnected(startupCtx); err != nil {
+			slog.Warn("DiceDB is unavailable, starting in degraded state",
+				slog.String("client", client.Role()), slog.Any("err", err))
+		}
+	}
+	cancelStartup()
+
+	// Export the connection pool statistics of both DiceDB clients
+	for _, client := range []*db.DiceDB{diceDBAdminClient, diceDBClient} {
+		if err := metrics.RegisterPool(client.Role(), client.Client); err != nil {
+			slog.Error("Failed to register DiceDB pool metrics", slog.String("client", client.Role()), slog.Any("err", err))
+		}
+	}
+
+	// Audit every executed command, events are written asynchronously to the configured sinks
+	auditor, err := audit.NewFromConfig(&configValue.Audit, diceDBAdminClient)
+	if err != nil {
+		slog.Error("Failed to initialize audit log", slog.Any("err", err))
+		os.Exit(1)
+	}
+
+	// Keep the commands exceeding the slow log threshold in the admin DiceDB
+	slowLog := slowlog.New(diceDBAdminClient, utils.SlowLogKey, configValue.SlowLog.Threshold, configValue.SlowLog.MaxEntries)
+
+	// Count command usage in the admin DiceDB, rolled up hourly into daily aggregates
+	var analyticsRecorder *analytics.Recorder
+	if configValue.Analytics.Enabled {
+		analyticsRecorder = analytics.New(diceDBAdminClient, configValue.Analytics.FlushInterval,
+			configValue.Analytics.RollupInterval, configValue.Analytics.Retention)
+		wg.Add(1)
+		go analyticsRecorder.Run(backgroundCtx, &wg)
+	}
+
+	// Track the clients active within the presence window
+	presenceTracker := presence.NewTracker(diceDBAdminClient, configValue.Presence.Window,
+		configValue.Presence.History, configValue.Presence.CacheTTL)
+
+	// Truncate replies exceeding the response budget, the rest is kept in the admin DiceDB
+	pager := pagination.New(diceDBAdminClient, configValue.Response.MaxBytes, configValue.Response.CursorTTL)
+
+	// Pin dedicated user DiceDB connections to the sessions asking for one
+	sessions := session.New(diceDBClient, configValue.Session.MaxPinnedConns,
+		configValue.Session.MaxPinnedConnsPerClient, configValue.Session.IdleTimeout)
+	if sessions != nil {
+		wg.Add(1)
+		go sessions.Run(backgroundCtx, &wg)
+	}
+
 	// Register a cleanup manager, this runs user DiceDB instance cleanup job at configured frequency
 	cleanupManager := server.NewCleanupManager(diceDBAdminClient, diceDBClient, configValue.Server.CronCleanupFrequency)
 	wg.Add(1)
-	go cleanupManager.Run(ctx, &wg)
+	go cleanupManager.Run(backgroundCtx, &wg)
+
+	// Create Gin router, access logs are written by the request logger instead of gin's logger
+	router := gin.New()
+	router.Use(gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, recovered any) {
+		logging.FromContext(c.Request.Context(), logging.ComponentHTTP).Error("Recovered from panic",
+			slog.Any("panic", recovered), slog.String("stack", string(debug.Stack())))
+		apierror.Abort(c, apierror.Internal())
+	}))
+
+	// Only trust the X-Forwarded-For header set by the configured proxies, the client IP
+	// keys the per-client limits and must not be chosen by the client
+	if err := router.SetTrustedProxies(configValue.Server.TrustedProxies); err != nil {
+		slog.Error("Invalid trusted proxies", slog.Any("err", err))
+		os.Exit(1)
+	}
 
-	// Create Gin router
-	router := gin.Default()
+	// Metrics middleware comes first so that requests rejected by other middlewares are counted
+	router.Use(middleware.MetricsMiddleware)
+
+	// Tracing middleware starts the request span, every middleware below records its own span
+	router.Use(tracing.RequestMiddleware)
+	router.Use(middleware.RequestLoggerMiddleware)
+	router.Use(middleware.ServerTimingMiddleware)
+
+	// Compression wraps the writer of every middleware below, so that replayed
+	// idempotent responses and errors are compressed as well
+	if configValue.Compression.Enabled {
+		router.Use(middleware.NewCompressionMiddleware(configValue.Compression.MinSize,
+			configValue.Compression.GzipLevel, configValue.Compression.BrotliLevel,
+			configValue.Compression.ExcludedPaths).Exec)
+	}
 
 	// CORS middleware
-	router.Use(func(c *gin.Context) {
+	router.Use(tracing.Middleware("cors", func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Idempotency-Key, X-Request-ID, X-Session-ID, X-Pinned-Session")
+		c.Writer.Header().Add("Access-Control-Expose-Headers", "X-Request-ID, Server-Timing")
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(http.StatusOK)
 			return
 		}
 		c.Next()
-	})
-	router.Use(middleware.TrailingSlashMiddleware)
-	router.Use((middleware.NewRateLimiterMiddleware(diceDBAdminClient,
+	})...)
+	router.Use(tracing.Middleware("trailing_slash", middleware.TrailingSlashMiddleware)...)
+	rateLimiter := middleware.NewRateLimiterMiddleware(diceDBAdminClient,
 		configValue.Server.RequestLimitPerMin,
 		configValue.Server.RequestWindowSec,
-	).Exec))
+	)
+	router.Use(tracing.Middleware("rate_limiter", rateLimiter.Exec)...)
+	router.Use(tracing.Middleware("presence", middleware.NewPresenceMiddleware(presenceTracker).Exec)...)
+	router.Use(tracing.Middleware("idempotency",
+		middleware.NewIdempotencyMiddleware(diceDBAdminClient, configValue.Server.IdempotencyTTL).Exec)...)
 
-	httpServer := server.NewHTTPServer(
-		router,
-		diceDBAdminClient,
-		diceDBClient,
-		configValue.Server.RequestLimitPerMin,
-		configValue.Server.RequestWindowSec,
+	httpServer := server.NewHTTPServer(router, configValue, server.Options{
+		DiceClient: diceDBClient,
+		Auditor:    auditor,
+		SlowLog:    slowLog,
+		Analytics:  analyticsRecorder,
+		Presence:   presenceTracker,
+		Pager:      pager,
+		Sessions:   sessions,
+	})
+
+	healthChecker := server.NewHealthChecker(diceDBAdminClient, diceDBClient, cleanupManager,
+		configValue.Server.HealthCheckTimeout,
+		configValue.Server.HealthCacheTTL,
 	)
 
 	// Register routes
 	router.GET("/health", gin.WrapF(httpServer.HealthCheck))
+	router.GET("/health/live", gin.WrapF(healthChecker.Live))
+	router.GET("/health/ready", gin.WrapF(healthChecker.Ready))
 	router.POST("/shell/exec/:cmd", gin.WrapF(httpServer.CliHandler))
+	router.POST("/shell/tx", gin.WrapF(httpServer.TransactionHandler))
+	router.POST("/shell/session", gin.WrapF(httpServer.PinSessionHandler))
+	router.DELETE("/shell/session", gin.WrapF(httpServer.UnpinSessionHandler))
+	router.GET("/shell/cursor/:cursor", gin.WrapF(httpServer.CursorHandler))
+	router.GET("/keys", gin.WrapF(httpServer.KeysHandler))
+	router.GET("/keys/*key", gin.WrapF(httpServer.KeyHandler))
 	router.GET("/search", gin.WrapF(httpServer.SearchHandler))
+	router.GET("/presence", gin.WrapF(httpServer.PresenceHandler))
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		// Run the HTTP Server
-		if err := httpServer.Run(context.Background()); err != nil {
-			slog.Error("server failed: %v\n", slog.Any("err", err))
-			diceDBAdminClient.CloseDiceDB()
-			cancel()
-		}
-	}()
+	// Admin routes, disabled unless ADMIN_TOKEN is set
+	adminAuth := middleware.NewAdminAuthMiddleware(configValue.Admin.Token)
+	admin := router.Group("/admin", adminAuth)
+	admin.GET("/slowlog", gin.WrapF(httpServer.SlowLogHandler))
+	admin.GET("/analytics", gin.WrapF(httpServer.AnalyticsHandler))
+
+	// Profiling and debug routes, only mounted when enabled and also requiring ADMIN_TOKEN
+	if configValue.Debug.Enabled {
+		server.RegisterDebugRoutes(router.Group("/debug", adminAuth), configValue, rateLimiter)
+	}
+
+	// Run the HTTP Server, this blocks until a shutdown signal is received
+	// and in-flight requests have been drained
+	if err := httpServer.Run(ctx); err != nil {
+		slog.Error("server failed", slog.Any("err", err))
+	}
+	// Restore default signal handling so that a second signal terminates immediately
+	stop()
+
+	// Write the queued audit events and slow log entries, stop the cleanup manager and
+	// connection monitors and only then close the DiceDB clients
+	httpServer.Close(func() {
+		cancelBackground()
+		wg.Wait()
+	}, diceDBClient, diceDBAdminClient)
+
+	// Flush the spans recorded while shutting down
+	tracingCtx, cancelTracing := context.WithTimeout(context.Background(), configValue.Server.ShutdownTimeout)
+	if err := shutdownTracing(tracingCtx); err != nil {
+		slog.Error("Failed to flush traces", slog.Any("err", err))
+	}
+	cancelTracing()
 
-	wg.Wait()
 	slog.Info("Server has shut down gracefully")
 }
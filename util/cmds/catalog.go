@@ -0,0 +1,73 @@
+package cmds
+
+import (
+	"sort"
+	"strings"
+)
+
+// knownCommands is the catalog of command names supported by DiceDB. It bounds
+// anything keyed by user supplied command names, e.g. metric labels.
+var knownCommands = map[string]struct{}{
+	// Strings
+	"APPEND": {}, "DECR": {}, "DECRBY": {}, "GET": {}, "GETDEL": {}, "GETEX": {}, "GETRANGE": {},
+	"GETSET": {}, "INCR": {}, "INCRBY": {}, "INCRBYFLOAT": {}, "MGET": {}, "MSET": {}, "PSETEX": {},
+	"SET": {}, "SETEX": {}, "SETNX": {}, "SETRANGE": {}, "STRLEN": {},
+	// Bitmaps
+	"BITCOUNT": {}, "BITFIELD": {}, "BITFIELD_RO": {}, "BITOP": {}, "BITPOS": {}, "GETBIT": {}, "SETBIT": {},
+	// Generic keyspace
+	"COPY": {}, "DBSIZE": {}, "DEL": {}, "DUMP": {}, "EXISTS": {}, "EXPIRE": {}, "EXPIREAT": {},
+	"EXPIRETIME": {}, "FLUSHALL": {}, "FLUSHDB": {}, "KEYS": {}, "MOVE": {}, "OBJECT": {}, "PERSIST": {},
+	"PEXPIRE": {}, "PEXPIREAT": {}, "PEXPIRETIME": {}, "PTTL": {}, "RANDOMKEY": {}, "RENAME": {},
+	"RESTORE": {}, "SCAN": {}, "SELECT": {}, "TOUCH": {}, "TTL": {}, "TYPE": {}, "UNLINK": {},
+	// Hashes
+	"HDEL": {}, "HEXISTS": {}, "HGET": {}, "HGETALL": {}, "HINCRBY": {}, "HINCRBYFLOAT": {}, "HKEYS": {},
+	"HLEN": {}, "HMGET": {}, "HMSET": {}, "HRANDFIELD": {}, "HSCAN": {}, "HSET": {}, "HSETNX": {},
+	"HSTRLEN": {}, "HVALS": {},
+	// Lists
+	"BLMOVE": {}, "BLPOP": {}, "BRPOP": {}, "BRPOPLPUSH": {}, "LINDEX": {}, "LINSERT": {}, "LLEN": {},
+	"LMOVE": {}, "LPOP": {}, "LPOS": {}, "LPUSH": {}, "LPUSHX": {}, "LRANGE": {}, "LREM": {}, "LSET": {},
+	"LTRIM": {}, "RPOP": {}, "RPOPLPUSH": {}, "RPUSH": {}, "RPUSHX": {},
+	// Sets
+	"SADD": {}, "SCARD": {}, "SDIFF": {}, "SDIFFSTORE": {}, "SINTER": {}, "SINTERSTORE": {},
+	"SISMEMBER": {}, "SMEMBERS": {}, "SMOVE": {}, "SPOP": {}, "SRANDMEMBER": {}, "SREM": {}, "SSCAN": {},
+	"SUNION": {}, "SUNIONSTORE": {},
+	// Sorted sets
+	"BZPOPMAX": {}, "BZPOPMIN": {}, "ZADD": {}, "ZCARD": {}, "ZCOUNT": {}, "ZINCRBY": {}, "ZLEXCOUNT": {},
+	"ZPOPMAX": {}, "ZPOPMIN": {}, "ZRANGE": {}, "ZRANGEBYLEX": {}, "ZRANGEBYSCORE": {}, "ZRANK": {},
+	"ZREM": {}, "ZREMRANGEBYLEX": {}, "ZREMRANGEBYRANK": {}, "ZREMRANGEBYSCORE": {}, "ZREVRANGE": {},
+	"ZREVRANGEBYLEX": {}, "ZREVRANGEBYSCORE": {}, "ZREVRANK": {}, "ZSCAN": {}, "ZSCORE": {},
+	// HyperLogLog
+	"PFADD": {}, "PFCOUNT": {}, "PFMERGE": {},
+	// Geo
+	"GEOADD": {}, "GEODIST": {}, "GEOHASH": {}, "GEOPOS": {}, "GEORADIUS": {}, "GEORADIUSBYMEMBER": {},
+	"GEOSEARCH": {}, "GEOSEARCHSTORE": {},
+	// JSON
+	"JSON.ARRAPPEND": {}, "JSON.ARRINSERT": {}, "JSON.ARRLEN": {}, "JSON.ARRPOP": {}, "JSON.ARRTRIM": {},
+	"JSON.CLEAR": {}, "JSON.DEBUG": {}, "JSON.DEL": {}, "JSON.FORGET": {}, "JSON.GET": {}, "JSON.INGEST": {},
+	"JSON.MGET": {}, "JSON.MSET": {}, "JSON.NUMINCRBY": {}, "JSON.NUMMULTBY": {}, "JSON.OBJKEYS": {},
+	"JSON.OBJLEN": {}, "JSON.RESP": {}, "JSON.SET": {}, "JSON.STRAPPEND": {}, "JSON.STRLEN": {},
+	"JSON.TOGGLE": {}, "JSON.TYPE": {},
+	// Connection, server and transactions
+	"ABORT": {}, "AUTH": {}, "BGREWRITEAOF": {}, "BGSAVE": {}, "CLIENT": {}, "COMMAND": {}, "CONFIG": {},
+	"DISCARD": {}, "ECHO": {}, "EXEC": {}, "HELLO": {}, "INFO": {}, "LATENCY": {}, "MULTI": {}, "PING": {},
+	"SAVE": {}, "SLEEP": {}, "UNWATCH": {}, "WATCH": {},
+	// Pub/Sub and reactivity
+	"PSUBSCRIBE": {}, "PUBLISH": {}, "PUNSUBSCRIBE": {}, "QUNWATCH": {}, "QWATCH": {}, "SUBSCRIBE": {},
+	"UNSUBSCRIBE": {},
+}
+
+// IsKnown reports whether cmd is a DiceDB command, the lookup is case-insensitive
+func IsKnown(cmd string) bool {
+	_, ok := knownCommands[strings.ToUpper(cmd)]
+	return ok
+}
+
+// Known returns the sorted list of known DiceDB command names
+func Known() []string {
+	names := make([]string, 0, len(knownCommands))
+	for name := range knownCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
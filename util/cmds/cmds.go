@@ -4,3 +4,18 @@ type CommandRequest struct {
 	Cmd  string   `json:"cmd"`
 	Args []string `json:"args"`
 }
+
+// TransactionRequest is the body of a transaction: the keys to WATCH and the
+// commands queued between MULTI and EXEC, each a command name followed by its
+// arguments
+type TransactionRequest struct {
+	Watch    []string   `json:"watch,omitempty"`
+	Commands [][]string `json:"commands"`
+}
+
+// MaxTransactionCommands is the maximum number of commands queued in a transaction
+const MaxTransactionCommands = 100
+
+// MaxTransactionBodyBytes is the maximum size of a transaction body, allowing
+// for up to 64 KiB per queued command
+const MaxTransactionBodyBytes = MaxTransactionCommands * 64 * 1024
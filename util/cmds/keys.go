@@ -0,0 +1,95 @@
+package cmds
+
+import "strings"
+
+// keySpec locates the key arguments of a command. Positions are indexes into
+// the arguments, excluding the command name. A negative last position counts
+// from the end, -1 being the last argument.
+type keySpec struct {
+	first int
+	last  int
+	step  int
+}
+
+var (
+	firstKey     = keySpec{first: 0, last: 0, step: 1}
+	allKeys      = keySpec{first: 0, last: -1, step: 1}
+	twoKeys      = keySpec{first: 0, last: 1, step: 1}
+	allButLast   = keySpec{first: 0, last: -2, step: 1}
+	keyValuePair = keySpec{first: 0, last: -1, step: 2}
+)
+
+// keySpecs holds the key positions of the known commands that take keys,
+// commands missing from it are considered to take none
+var keySpecs = map[string]keySpec{
+	"APPEND": firstKey, "DECR": firstKey, "DECRBY": firstKey, "GET": firstKey, "GETDEL": firstKey,
+	"GETEX": firstKey, "GETRANGE": firstKey, "GETSET": firstKey, "INCR": firstKey, "INCRBY": firstKey,
+	"INCRBYFLOAT": firstKey, "MGET": allKeys, "MSET": keyValuePair, "PSETEX": firstKey, "SET": firstKey,
+	"SETEX": firstKey, "SETNX": firstKey, "SETRANGE": firstKey, "STRLEN": firstKey,
+
+	"BITCOUNT": firstKey, "BITFIELD": firstKey, "BITFIELD_RO": firstKey, "BITPOS": firstKey,
+	"GETBIT": firstKey, "SETBIT": firstKey, "BITOP": {first: 1, last: -1, step: 1},
+
+	"COPY": twoKeys, "DEL": allKeys, "DUMP": firstKey, "EXISTS": allKeys, "EXPIRE": firstKey,
+	"EXPIREAT": firstKey, "EXPIRETIME": firstKey, "MOVE": firstKey, "OBJECT": {first: 1, last: 1, step: 1},
+	"PERSIST": firstKey, "PEXPIRE": firstKey, "PEXPIREAT": firstKey, "PEXPIRETIME": firstKey,
+	"PTTL": firstKey, "RENAME": twoKeys, "RESTORE": firstKey, "TOUCH": allKeys, "TTL": firstKey,
+	"TYPE": firstKey, "UNLINK": allKeys, "WATCH": allKeys,
+
+	"HDEL": firstKey, "HEXISTS": firstKey, "HGET": firstKey, "HGETALL": firstKey, "HINCRBY": firstKey,
+	"HINCRBYFLOAT": firstKey, "HKEYS": firstKey, "HLEN": firstKey, "HMGET": firstKey, "HMSET": firstKey,
+	"HRANDFIELD": firstKey, "HSCAN": firstKey, "HSET": firstKey, "HSETNX": firstKey, "HSTRLEN": firstKey,
+	"HVALS": firstKey,
+
+	"BLMOVE": twoKeys, "BLPOP": allButLast, "BRPOP": allButLast, "BRPOPLPUSH": twoKeys,
+	"LINDEX": firstKey, "LINSERT": firstKey, "LLEN": firstKey, "LMOVE": twoKeys, "LPOP": firstKey,
+	"LPOS": firstKey, "LPUSH": firstKey, "LPUSHX": firstKey, "LRANGE": firstKey, "LREM": firstKey,
+	"LSET": firstKey, "LTRIM": firstKey, "RPOP": firstKey, "RPOPLPUSH": twoKeys, "RPUSH": firstKey,
+	"RPUSHX": firstKey,
+
+	"SADD": firstKey, "SCARD": firstKey, "SDIFF": allKeys, "SDIFFSTORE": allKeys, "SINTER": allKeys,
+	"SINTERSTORE": allKeys, "SISMEMBER": firstKey, "SMEMBERS": firstKey, "SMOVE": twoKeys, "SPOP": firstKey,
+	"SRANDMEMBER": firstKey, "SREM": firstKey, "SSCAN": firstKey, "SUNION": allKeys, "SUNIONSTORE": allKeys,
+
+	"BZPOPMAX": allButLast, "BZPOPMIN": allButLast, "ZADD": firstKey, "ZCARD": firstKey, "ZCOUNT": firstKey,
+	"ZINCRBY": firstKey, "ZLEXCOUNT": firstKey, "ZPOPMAX": firstKey, "ZPOPMIN": firstKey, "ZRANGE": firstKey,
+	"ZRANGEBYLEX": firstKey, "ZRANGEBYSCORE": firstKey, "ZRANK": firstKey, "ZREM": firstKey,
+	"ZREMRANGEBYLEX": firstKey, "ZREMRANGEBYRANK": firstKey, "ZREMRANGEBYSCORE": firstKey,
+	"ZREVRANGE": firstKey, "ZREVRANGEBYLEX": firstKey, "ZREVRANGEBYSCORE": firstKey, "ZREVRANK": firstKey,
+	"ZSCAN": firstKey, "ZSCORE": firstKey,
+
+	"PFADD": firstKey, "PFCOUNT": allKeys, "PFMERGE": allKeys,
+
+	"GEOADD": firstKey, "GEODIST": firstKey, "GEOHASH": firstKey, "GEOPOS": firstKey, "GEORADIUS": firstKey,
+	"GEORADIUSBYMEMBER": firstKey, "GEOSEARCH": firstKey, "GEOSEARCHSTORE": twoKeys,
+
+	"JSON.ARRAPPEND": firstKey, "JSON.ARRINSERT": firstKey, "JSON.ARRLEN": firstKey, "JSON.ARRPOP": firstKey,
+	"JSON.ARRTRIM": firstKey, "JSON.CLEAR": firstKey, "JSON.DEBUG": {first: 1, last: 1, step: 1},
+	"JSON.DEL": firstKey, "JSON.FORGET": firstKey, "JSON.GET": firstKey, "JSON.INGEST": firstKey,
+	"JSON.MGET": allButLast, "JSON.MSET": {first: 0, last: -1, step: 3}, "JSON.NUMINCRBY": firstKey,
+	"JSON.NUMMULTBY": firstKey, "JSON.OBJKEYS": firstKey, "JSON.OBJLEN": firstKey, "JSON.RESP": firstKey,
+	"JSON.SET": firstKey, "JSON.STRAPPEND": firstKey, "JSON.STRLEN": firstKey, "JSON.TOGGLE": firstKey,
+	"JSON.TYPE": firstKey,
+}
+
+// KeyNames returns the key arguments of a command, e.g. ["k1", "k2"] for
+// MSET k1 v1 k2 v2. Commands with unknown key positions return no keys, so
+// the result never contains values.
+func KeyNames(cmd string, args []string) []string {
+	spec, ok := keySpecs[strings.ToUpper(cmd)]
+	if !ok || len(args) == 0 {
+		return nil
+	}
+
+	last := spec.last
+	if last < 0 {
+		last += len(args)
+	}
+	last = min(last, len(args)-1)
+
+	var keys []string
+	for i := spec.first; i <= last; i += spec.step {
+		keys = append(keys, args[i])
+	}
+	return keys
+}
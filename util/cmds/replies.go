@@ -0,0 +1,71 @@
+package cmds
+
+import "strings"
+
+// statusCommands reply with a status (simple string) such as OK instead of a
+// bulk string. The client returns both as plain strings, so the reply type is
+// inferred from the command.
+var statusCommands = map[string]struct{}{
+	"SET": {}, "MSET": {}, "SETEX": {}, "PSETEX": {}, "RENAME": {}, "RESTORE": {}, "TYPE": {},
+	"FLUSHALL": {}, "FLUSHDB": {}, "SELECT": {}, "LSET": {}, "LTRIM": {}, "HMSET": {}, "PFMERGE": {},
+	"JSON.SET": {}, "JSON.MSET": {}, "PING": {}, "AUTH": {}, "SAVE": {}, "BGSAVE": {}, "BGREWRITEAOF": {},
+	"MULTI": {}, "DISCARD": {}, "WATCH": {}, "UNWATCH": {}, "SLEEP": {}, "ABORT": {},
+}
+
+// RepliesWithStatus reports whether the command replies with a status rather
+// than a bulk string, given its arguments
+func RepliesWithStatus(cmd string, args []string) bool {
+	cmd = strings.ToUpper(cmd)
+	if _, ok := statusCommands[cmd]; !ok {
+		return false
+	}
+
+	switch cmd {
+	case "PING":
+		// PING echoes its argument back as a bulk string
+		return len(args) == 0
+	case "SET":
+		// SET ... GET replies with the previous value
+		for _, arg := range args[min(2, len(args)):] {
+			if strings.EqualFold(arg, "GET") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// pairCommands reply with a flat array of alternating pairs, mapped to the
+// names of both columns. Commands listed in pairOptions only do so when given
+// the option.
+var pairCommands = map[string][2]string{
+	"HGETALL": {"field", "value"}, "HRANDFIELD": {"field", "value"},
+	"ZRANGE": {"member", "score"}, "ZREVRANGE": {"member", "score"}, "ZRANGEBYSCORE": {"member", "score"},
+	"ZREVRANGEBYSCORE": {"member", "score"}, "ZRANDMEMBER": {"member", "score"},
+	"ZPOPMIN": {"member", "score"}, "ZPOPMAX": {"member", "score"},
+}
+
+var pairOptions = map[string]string{
+	"HRANDFIELD": "WITHVALUES", "ZRANGE": "WITHSCORES", "ZREVRANGE": "WITHSCORES", "ZRANGEBYSCORE": "WITHSCORES",
+	"ZREVRANGEBYSCORE": "WITHSCORES", "ZRANDMEMBER": "WITHSCORES",
+}
+
+// PairColumns returns the names of the columns of a command replying with
+// alternating pairs, e.g. field and value for HGETALL, given its arguments
+func PairColumns(cmd string, args []string) ([2]string, bool) {
+	cmd = strings.ToUpper(cmd)
+	columns, ok := pairCommands[cmd]
+	if !ok {
+		return columns, false
+	}
+
+	if option, ok := pairOptions[cmd]; ok {
+		for _, arg := range args {
+			if strings.EqualFold(arg, option) {
+				return columns, true
+			}
+		}
+		return columns, false
+	}
+	return columns, true
+}
@@ -0,0 +1,79 @@
+package cmds
+
+import (
+	"sort"
+	"strings"
+)
+
+// maxSuggestions bounds the number of command names returned by Suggest
+const maxSuggestions = 3
+
+// Suggest returns up to three known commands closest to cmd by edit distance,
+// closest first. Names further away than a third of their length, or two
+// edits for short names, are not considered similar.
+func Suggest(cmd string) []string {
+	cmd = strings.ToUpper(cmd)
+	if cmd == "" {
+		return nil
+	}
+
+	type candidate struct {
+		name     string
+		distance int
+	}
+	var candidates []candidate
+	for name := range knownCommands {
+		if name == cmd {
+			continue
+		}
+		threshold := max(2, len(name)/3)
+		if abs(len(name)-len(cmd)) > threshold {
+			continue
+		}
+		if d := editDistance(cmd, name); d <= threshold {
+			candidates = append(candidates, candidate{name: name, distance: d})
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].name < candidates[j].name
+	})
+
+	suggestions := make([]string, 0, min(len(candidates), maxSuggestions))
+	for _, c := range candidates[:min(len(candidates), maxSuggestions)] {
+		suggestions = append(suggestions, c.name)
+	}
+	return suggestions
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,144 @@
+package cmds
+
+import "strings"
+
+// DocsBaseURL is the base URL of the DiceDB command reference
+const DocsBaseURL = "https://dicedb.io/commands/"
+
+// syntaxes holds the usage of the most common commands, in the notation of
+// the command reference
+var syntaxes = map[string]string{
+	// Strings
+	"APPEND":      "APPEND key value",
+	"DECR":        "DECR key",
+	"DECRBY":      "DECRBY key decrement",
+	"GET":         "GET key",
+	"GETDEL":      "GETDEL key",
+	"GETEX":       "GETEX key [EX seconds | PX milliseconds | EXAT unix-time-seconds | PXAT unix-time-milliseconds | PERSIST]",
+	"GETRANGE":    "GETRANGE key start end",
+	"GETSET":      "GETSET key value",
+	"INCR":        "INCR key",
+	"INCRBY":      "INCRBY key increment",
+	"INCRBYFLOAT": "INCRBYFLOAT key increment",
+	"MGET":        "MGET key [key ...]",
+	"MSET":        "MSET key value [key value ...]",
+	"SET":         "SET key value [NX | XX] [GET] [EX seconds | PX milliseconds | EXAT unix-time-seconds | PXAT unix-time-milliseconds | KEEPTTL]",
+	"SETEX":       "SETEX key seconds value",
+	"SETNX":       "SETNX key value",
+	"STRLEN":      "STRLEN key",
+	// Bitmaps
+	"BITCOUNT": "BITCOUNT key [start end [BYTE | BIT]]",
+	"BITOP":    "BITOP AND | OR | XOR | NOT destkey key [key ...]",
+	"BITPOS":   "BITPOS key bit [start [end [BYTE | BIT]]]",
+	"GETBIT":   "GETBIT key offset",
+	"SETBIT":   "SETBIT key offset value",
+	// Generic keyspace
+	"COPY":       "COPY source destination [REPLACE]",
+	"DBSIZE":     "DBSIZE",
+	"DEL":        "DEL key [key ...]",
+	"EXISTS":     "EXISTS key [key ...]",
+	"EXPIRE":     "EXPIRE key seconds [NX | XX | GT | LT]",
+	"EXPIREAT":   "EXPIREAT key unix-time-seconds [NX | XX | GT | LT]",
+	"EXPIRETIME": "EXPIRETIME key",
+	"KEYS":       "KEYS pattern",
+	"PERSIST":    "PERSIST key",
+	"PEXPIRE":    "PEXPIRE key milliseconds [NX | XX | GT | LT]",
+	"PTTL":       "PTTL key",
+	"RENAME":     "RENAME key newkey",
+	"SCAN":       "SCAN cursor [MATCH pattern] [COUNT count] [TYPE type]",
+	"TOUCH":      "TOUCH key [key ...]",
+	"TTL":        "TTL key",
+	"TYPE":       "TYPE key",
+	"UNLINK":     "UNLINK key [key ...]",
+	// Hashes
+	"HDEL":         "HDEL key field [field ...]",
+	"HEXISTS":      "HEXISTS key field",
+	"HGET":         "HGET key field",
+	"HGETALL":      "HGETALL key",
+	"HINCRBY":      "HINCRBY key field increment",
+	"HINCRBYFLOAT": "HINCRBYFLOAT key field increment",
+	"HKEYS":        "HKEYS key",
+	"HLEN":         "HLEN key",
+	"HMGET":        "HMGET key field [field ...]",
+	"HMSET":        "HMSET key field value [field value ...]",
+	"HRANDFIELD":   "HRANDFIELD key [count [WITHVALUES]]",
+	"HSCAN":        "HSCAN key cursor [MATCH pattern] [COUNT count]",
+	"HSET":         "HSET key field value [field value ...]",
+	"HSETNX":       "HSETNX key field value",
+	"HSTRLEN":      "HSTRLEN key field",
+	"HVALS":        "HVALS key",
+	// Lists
+	"BLPOP":   "BLPOP key [key ...] timeout",
+	"BRPOP":   "BRPOP key [key ...] timeout",
+	"LINDEX":  "LINDEX key index",
+	"LINSERT": "LINSERT key BEFORE | AFTER pivot element",
+	"LLEN":    "LLEN key",
+	"LMOVE":   "LMOVE source destination LEFT | RIGHT LEFT | RIGHT",
+	"LPOP":    "LPOP key [count]",
+	"LPUSH":   "LPUSH key element [element ...]",
+	"LRANGE":  "LRANGE key start stop",
+	"LREM":    "LREM key count element",
+	"LSET":    "LSET key index element",
+	"LTRIM":   "LTRIM key start stop",
+	"RPOP":    "RPOP key [count]",
+	"RPUSH":   "RPUSH key element [element ...]",
+	// Sets
+	"SADD":        "SADD key member [member ...]",
+	"SCARD":       "SCARD key",
+	"SDIFF":       "SDIFF key [key ...]",
+	"SINTER":      "SINTER key [key ...]",
+	"SISMEMBER":   "SISMEMBER key member",
+	"SMEMBERS":    "SMEMBERS key",
+	"SPOP":        "SPOP key [count]",
+	"SRANDMEMBER": "SRANDMEMBER key [count]",
+	"SREM":        "SREM key member [member ...]",
+	"SUNION":      "SUNION key [key ...]",
+	// Sorted sets
+	"ZADD":          "ZADD key [NX | XX] [GT | LT] [CH] [INCR] score member [score member ...]",
+	"ZCARD":         "ZCARD key",
+	"ZCOUNT":        "ZCOUNT key min max",
+	"ZINCRBY":       "ZINCRBY key increment member",
+	"ZPOPMAX":       "ZPOPMAX key [count]",
+	"ZPOPMIN":       "ZPOPMIN key [count]",
+	"ZRANGE":        "ZRANGE key start stop [BYSCORE | BYLEX] [REV] [LIMIT offset count] [WITHSCORES]",
+	"ZRANGEBYSCORE": "ZRANGEBYSCORE key min max [WITHSCORES] [LIMIT offset count]",
+	"ZRANK":         "ZRANK key member [WITHSCORE]",
+	"ZREM":          "ZREM key member [member ...]",
+	"ZREVRANGE":     "ZREVRANGE key start stop [WITHSCORES]",
+	"ZREVRANK":      "ZREVRANK key member [WITHSCORE]",
+	"ZSCORE":        "ZSCORE key member",
+	// HyperLogLog
+	"PFADD":   "PFADD key [element [element ...]]",
+	"PFCOUNT": "PFCOUNT key [key ...]",
+	"PFMERGE": "PFMERGE destkey [sourcekey [sourcekey ...]]",
+	// Geo
+	"GEOADD":  "GEOADD key [NX | XX] [CH] longitude latitude member [longitude latitude member ...]",
+	"GEODIST": "GEODIST key member1 member2 [M | KM | FT | MI]",
+	"GEOPOS":  "GEOPOS key [member [member ...]]",
+	// JSON
+	"JSON.ARRAPPEND": "JSON.ARRAPPEND key [path] value [value ...]",
+	"JSON.ARRLEN":    "JSON.ARRLEN key [path]",
+	"JSON.DEL":       "JSON.DEL key [path]",
+	"JSON.GET":       "JSON.GET key [path [path ...]]",
+	"JSON.MGET":      "JSON.MGET key [key ...] path",
+	"JSON.SET":       "JSON.SET key path value [NX | XX]",
+	"JSON.TYPE":      "JSON.TYPE key [path]",
+	// Connection
+	"ECHO": "ECHO message",
+	"PING": "PING [message]",
+}
+
+// Syntax returns the usage of cmd, e.g. "HGET key field"
+func Syntax(cmd string) (string, bool) {
+	syntax, ok := syntaxes[strings.ToUpper(cmd)]
+	return syntax, ok
+}
+
+// DocsURL returns the link to the reference of a known command, or an empty
+// string for unknown commands
+func DocsURL(cmd string) string {
+	if !IsKnown(cmd) {
+		return ""
+	}
+	return DocsBaseURL + strings.ToLower(cmd) + "/"
+}
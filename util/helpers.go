@@ -8,8 +8,11 @@ import (
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
-	"server/config"
+	"server/internal/apierror"
+	"server/internal/metrics"
 	"server/internal/middleware"
+	"server/internal/msgpack"
+	"server/internal/resp"
 	db "server/internal/tests/dbmocks"
 	"server/util/cmds"
 	"strings"
@@ -41,27 +44,30 @@ var blocklistedCommands = map[string]bool{
 // BlockListedCommand checks if a command is blocklisted
 func BlockListedCommand(cmd string) error {
 	if _, exists := blocklistedCommands[strings.ToUpper(cmd)]; exists {
-		return errors.New("ERR unknown command '" + cmd + "'")
+		return apierror.New(apierror.CodeBlockedCommand, "command '"+cmd+"' is not allowed")
 	}
 	return nil
 }
 
-// ParseHTTPRequest parses an incoming HTTP request and converts it into a CommandRequest for Redis commands
-func ParseHTTPRequest(r *http.Request) (*cmds.CommandRequest, error) {
+// ParseHTTPRequest parses an incoming HTTP request and converts it into a CommandRequest for Redis commands.
+// Blocklisted commands are rejected if blockCommands is set. The returned error is an *apierror.Error.
+func ParseHTTPRequest(r *http.Request, blockCommands bool) (*cmds.CommandRequest, error) {
 	command := extractCommand(r.URL.Path)
 	if command == "" {
-		return nil, errors.New("invalid command")
+		return nil, apierror.New(apierror.CodeInvalidRequest, "invalid command")
 	}
 
-	configValue := config.LoadConfig()
 	// Check if the command is blocklisted
-	if err := BlockListedCommand(command); err != nil && configValue.Server.Environment == "production" {
+	if err := BlockListedCommand(command); err != nil && blockCommands {
+		metrics.ObserveBlockedCommand(command)
 		return nil, err
 	}
 
 	args, err := newExtractor(r)
 	if err != nil {
-		return nil, err
+		return nil, apierror.New(apierror.CodeInvalidRequest, err.Error()).
+			WithHint("Send the arguments as a JSON array of strings, as a RESP array with Content-Type " + MediaTypeRESP +
+				" or as a MessagePack array with Content-Type " + MediaTypeMsgPack + ".")
 	}
 
 	return &cmds.CommandRequest{
@@ -70,6 +76,68 @@ func ParseHTTPRequest(r *http.Request) (*cmds.CommandRequest, error) {
 	}, nil
 }
 
+// transactionCommands control the transaction itself and cannot be queued
+var transactionCommands = map[string]bool{
+	"MULTI":   true,
+	"EXEC":    true,
+	"DISCARD": true,
+	"WATCH":   true,
+	"UNWATCH": true,
+}
+
+// ParseTransactionRequest parses the body of a transaction request into the
+// keys to watch and the queued commands. Every queued command is checked
+// against the blocklist if blockCommands is set. The returned error is an
+// *apierror.Error.
+func ParseTransactionRequest(r *http.Request, blockCommands bool) ([]string, []*cmds.CommandRequest, error) {
+	var body cmds.TransactionRequest
+	if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, cmds.MaxTransactionBodyBytes)).Decode(&body); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, nil, apierror.New(apierror.CodeInvalidRequest,
+				fmt.Sprintf("a transaction body holds at most %d bytes", cmds.MaxTransactionBodyBytes))
+		}
+		return nil, nil, apierror.New(apierror.CodeInvalidRequest, err.Error()).
+			WithHint(`Send a JSON object such as {"watch": ["k"], "commands": [["GET", "k"], ["INCR", "k"]]}.`)
+	}
+	if len(body.Commands) == 0 {
+		return nil, nil, apierror.New(apierror.CodeInvalidRequest, "a transaction needs at least one command")
+	}
+	if len(body.Commands) > cmds.MaxTransactionCommands {
+		return nil, nil, apierror.New(apierror.CodeInvalidRequest,
+			fmt.Sprintf("a transaction holds at most %d commands", cmds.MaxTransactionCommands))
+	}
+	for _, key := range body.Watch {
+		if key == "" {
+			return nil, nil, apierror.New(apierror.CodeInvalidRequest, "watched keys cannot be empty")
+		}
+	}
+
+	commands := make([]*cmds.CommandRequest, 0, len(body.Commands))
+	for i, args := range body.Commands {
+		if len(args) == 0 || args[0] == "" {
+			return nil, nil, apierror.New(apierror.CodeInvalidRequest, fmt.Sprintf("command %d is empty", i+1))
+		}
+		command := strings.ToUpper(args[0])
+		if transactionCommands[command] {
+			return nil, nil, apierror.New(apierror.CodeInvalidRequest,
+				fmt.Sprintf("command '%s' cannot be queued in a transaction", command)).
+				WithHint("List the keys to watch in the watch field, MULTI and EXEC are sent by the server.")
+		}
+		if err := BlockListedCommand(command); err != nil && blockCommands {
+			metrics.ObserveBlockedCommand(command)
+			return nil, nil, err
+		}
+		commands = append(commands, &cmds.CommandRequest{Cmd: command, Args: args[1:]})
+	}
+	return body.Watch, commands, nil
+}
+
+// CommandFromPath returns the upper-cased command name of a /shell/exec/:cmd path
+func CommandFromPath(path string) string {
+	return extractCommand(path)
+}
+
 func extractCommand(path string) string {
 	command := strings.TrimPrefix(path, "/shell/exec/")
 	return strings.ToUpper(command)
@@ -86,6 +154,15 @@ func newExtractor(r *http.Request) ([]string, error) {
 		return args, nil
 	}
 
+	// Protocol-level clients can send the arguments as a RESP array and
+	// embedded clients as a MessagePack array instead
+	switch NormalizeMediaType(r.Header.Get("Content-Type")) {
+	case MediaTypeRESP:
+		return resp.DecodeArgs(bodyContent)
+	case MediaTypeMsgPack:
+		return msgpack.DecodeArgs(bodyContent)
+	}
+
 	var jsonBody []interface{}
 	if err := json.Unmarshal(bodyContent, &jsonBody); err != nil {
 		return nil, err
@@ -116,7 +193,7 @@ func JSONResponse(w http.ResponseWriter, status int, data interface{}) {
 func MockHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	if _, err := w.Write([]byte("OK")); err != nil {
-		slog.Error("Failed to write response: %v", slog.Any("err", err))
+		slog.Error("Failed to write response", slog.Any("err", err))
 	}
 }
 
@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Media types CliHandler can respond with
+const (
+	MediaTypeJSON    = "application/json"
+	MediaTypeText    = "text/plain"
+	MediaTypeRESP    = "application/x-resp"
+	MediaTypeMsgPack = "application/msgpack"
+	MediaTypeNDJSON  = "application/x-ndjson"
+	MediaTypeCSV     = "text/csv"
+)
+
+// mediaTypeAliases maps legacy names to the media types above
+var mediaTypeAliases = map[string]string{
+	"application/x-msgpack": MediaTypeMsgPack,
+}
+
+// formats maps the values of the ?format= query parameter to media types
+var formats = map[string]string{
+	"json":    MediaTypeJSON,
+	"text":    MediaTypeText,
+	"resp":    MediaTypeRESP,
+	"msgpack": MediaTypeMsgPack,
+	"ndjson":  MediaTypeNDJSON,
+	"csv":     MediaTypeCSV,
+}
+
+// NegotiateRequest picks the media type of the response. The ?format= query
+// parameter takes precedence over the Accept header, it fails for formats that
+// are not offered.
+func NegotiateRequest(r *http.Request, offers ...string) (string, error) {
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		return Negotiate(r.Header.Get("Accept"), offers...), nil
+	}
+
+	if mediaType, ok := formats[format]; ok {
+		for _, offer := range offers {
+			if offer == mediaType {
+				return mediaType, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("unsupported format '%s'", format)
+}
+
+// NormalizeMediaType returns the media type of a Content-Type or Accept value
+// without parameters, with legacy names replaced
+func NormalizeMediaType(value string) string {
+	mediaType, _, _ := mime.ParseMediaType(value)
+	if alias, ok := mediaTypeAliases[mediaType]; ok {
+		return alias
+	}
+	return mediaType
+}
+
+// Negotiate picks the offered media type the Accept header prefers, honoring
+// quality values and wildcards. The first offer is the default, it is returned
+// when the header is empty or matches none of the offers.
+func Negotiate(accept string, offers ...string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+
+	best, bestQ, bestSpecificity := offers[0], 0.0, -1
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if alias, ok := mediaTypeAliases[mediaType]; ok {
+			mediaType = alias
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+
+		for _, offer := range offers {
+			specificity := matchMediaType(mediaType, offer)
+			if specificity < 0 {
+				continue
+			}
+			// More specific ranges take precedence over wildcards with the
+			// same quality, e.g. "text/plain, */*" picks text/plain
+			if q > bestQ || (q == bestQ && specificity > bestSpecificity) {
+				best, bestQ, bestSpecificity = offer, q, specificity
+			}
+		}
+	}
+	return best
+}
+
+// matchMediaType returns how specifically the media range matches offer: 2 for
+// an exact match, 1 for type/*, 0 for */* and -1 if it does not match
+func matchMediaType(mediaRange, offer string) int {
+	switch {
+	case mediaRange == offer:
+		return 2
+	case mediaRange == "*/*":
+		return 0
+	case strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(offer, strings.TrimSuffix(mediaRange, "*")):
+		return 1
+	default:
+		return -1
+	}
+}